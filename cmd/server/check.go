@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// checkConfig implements "check-config [path]": it loads and validates a
+// config the way the server would and prints the resolved result with
+// secrets redacted. It returns the process exit code.
+func checkConfig(args []string) int {
+	path := defaultConfigPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	name := path
+	if name == "" {
+		name = "the default config"
+	}
+
+	cfg, err := config.Load(path)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid:\n%v\n", name, err)
+		return 1
+	}
+
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print config: %v\n", err)
+		return 1
+	}
+	fmt.Printf("# %s is valid\n%s", name, out)
+	return 0
+}
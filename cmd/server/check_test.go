@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(valid, []byte("server: {port: 9090}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.yaml")
+
+	tests := []struct {
+		name       string
+		configPath string
+		args       []string
+		want       int
+	}{
+		{name: "argument", args: []string{valid}, want: 0},
+		{name: "missing argument", args: []string{missing}, want: 1},
+		{name: "CONFIG_PATH", configPath: valid, want: 0},
+		{name: "missing CONFIG_PATH", configPath: missing, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_PATH", tt.configPath)
+			if got := checkConfig(tt.args); got != tt.want {
+				t.Errorf("exit code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,44 +11,70 @@ import (
 	"time"
 
 	"github.com/kashifkhan/ai-gateway/internal/adapters"
-	"github.com/kashifkhan/ai-gateway/internal/adapters/opencode"
 	"github.com/kashifkhan/ai-gateway/internal/api"
+	"github.com/kashifkhan/ai-gateway/internal/audit"
 	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/batch"
+	"github.com/kashifkhan/ai-gateway/internal/budget"
 	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/limiter"
+	"github.com/kashifkhan/ai-gateway/internal/routing"
+	"github.com/kashifkhan/ai-gateway/internal/sandbox"
 )
 
 const Version = "1.0.0"
 
+const defaultConfigFile = "config/config.yaml"
+
+// defaultConfigPath is CONFIG_PATH if set, else config/config.yaml if it
+// exists. Without either, the server runs on the defaults.
+func defaultConfigPath() string {
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return defaultConfigFile
+	}
+	return ""
+}
+
 func main() {
-	configPath := os.Getenv("CONFIG_PATH")
-	if configPath == "" {
-		configPath = "config/config.yaml"
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		os.Exit(checkConfig(os.Args[2:]))
 	}
 
+	configPath := defaultConfigPath()
+
 	cfg, err := config.Load(configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
 	if err != nil {
-		log.Printf("Warning: Could not load config file: %v. Using defaults.", err)
-		cfg = config.DefaultConfig()
+		log.Fatalf("Invalid config: %v", err)
 	}
 
 	printBanner()
 
-	registry := adapters.NewRegistry(cfg.DefaultBackend)
-
-	if backendCfg, ok := cfg.Backends["opencode"]; ok && backendCfg.Enabled {
-		opencodeAdapter := opencode.New(backendCfg)
-		if err := opencodeAdapter.Initialize(nil); err != nil {
-			log.Printf("Warning: Failed to initialize OpenCode adapter: %v", err)
-		} else {
-			registry.Register(opencodeAdapter)
-			log.Printf("✓ OpenCode adapter initialized (http://%s:%d)", backendCfg.Host, backendCfg.Port)
-		}
+	auditLog, err := audit.New(cfg.Logging.AuditFile)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
 	}
+	defer auditLog.Close()
+
+	registry := adapters.NewRegistry(cfg.DefaultBackend)
 
 	authenticator := auth.NewAuthenticator(cfg.Auth.Keys, cfg.Auth.Enabled)
+	exampleKey := "$API_KEY"
 	if cfg.Auth.Enabled {
 		log.Printf("✓ Authentication enabled")
-		log.Printf("  Default API Key: %s", auth.GetDefaultKey())
+		// Configured keys are secrets and never logged; only the built-in
+		// fallback key is shown.
+		if len(cfg.Auth.Keys) == 0 {
+			log.Printf("  Default API Key: %s", auth.GetDefaultKey())
+			exampleKey = auth.GetDefaultKey()
+		} else {
+			log.Printf("  %d API keys configured", len(cfg.Auth.Keys))
+		}
 	} else {
 		log.Printf("⚠ Authentication disabled")
 	}
@@ -58,7 +84,50 @@ func main() {
 		log.Printf("✓ Rate limiting enabled (%d req/min)", cfg.RateLimit.RequestsPerMinute)
 	}
 
-	router := api.SetupRouter(registry, authenticator, rateLimiter, Version)
+	concurrencyLimiter := limiter.New(cfg.Backends, cfg.Auth.Priorities)
+	for id, b := range cfg.Backends {
+		if b.Enabled && b.Concurrency.MaxConcurrent > 0 {
+			log.Printf("✓ Concurrency limit for %s: %d concurrent requests", id, b.Concurrency.MaxConcurrent)
+		}
+	}
+
+	toolPolicies := sandbox.New(cfg.Backends, cfg.Auth.ToolPolicies)
+	for id, b := range cfg.Backends {
+		if b.Enabled && b.Tools.Mode != "" && b.Tools.Mode != config.ToolModeAllowAll {
+			log.Printf("✓ Agent tools for %s: %s", id, b.Tools.Mode)
+		}
+	}
+
+	registry.SetVirtualModels(cfg.VirtualModels)
+	for _, vm := range cfg.VirtualModels {
+		log.Printf("✓ Virtual model %s -> %s", vm.ID, vm.Model)
+	}
+
+	routes := routing.New(cfg.Routes)
+	if len(cfg.Routes) > 0 {
+		log.Printf("✓ Routing table: %d routes", len(cfg.Routes))
+	}
+
+	spending, err := budget.New(cfg.Budget)
+	if err != nil {
+		log.Fatalf("Failed to load spending: %v", err)
+	}
+	if spending.Enabled() {
+		log.Printf("✓ Budgets enabled: monthly $%.2f, per key $%.2f (0 = unlimited)", cfg.Budget.Monthly, cfg.Budget.PerKey)
+	}
+
+	reloader := newReloader(configPath, cfg, auditLog, registry, authenticator, rateLimiter, concurrencyLimiter, toolPolicies, routes, spending)
+	reloader.startBackends(cfg)
+	if conflicts := registry.Conflicts(); len(conflicts) > 0 && cfg.ModelConflicts == config.ModelConflictsError {
+		log.Fatalf("Backends offer conflicting models (model_conflicts: error): %v", conflicts)
+	}
+
+	batches, err := batch.NewManager(cfg.Batch)
+	if err != nil {
+		log.Fatalf("Failed to open batch store: %v", err)
+	}
+
+	router := api.SetupRouter(registry, authenticator, rateLimiter, concurrencyLimiter, toolPolicies, routes, spending, batches, cfg, Version)
 
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	server := &http.Server{
@@ -74,12 +143,20 @@ func main() {
 		log.Printf("Endpoints:")
 		log.Printf("  GET  /health              - Health check")
 		log.Printf("  GET  /v1/models           - List available models")
+		log.Printf("  GET  /v1/models/{id}      - Describe a model")
 		log.Printf("  GET  /v1/backends         - List available backends")
+		log.Printf("  GET  /v1/routes           - Routing table (/v1/routes/resolve?model= for a dry run)")
 		log.Printf("  POST /v1/chat/completions - Chat completion")
+		log.Printf("  POST /v1/completions      - Legacy text completion")
+		log.Printf("  POST /v1/embeddings       - Embeddings")
+		log.Printf("  POST /v1/responses        - Responses API")
+		log.Printf("  POST /v1/messages         - Anthropic Messages API")
+		log.Printf("  POST /v1/files            - Upload a batch input file")
+		log.Printf("  POST /v1/batches          - Batch API")
 		log.Printf("")
 		log.Printf("Example usage:")
 		log.Printf("  curl -X POST http://%s/v1/chat/completions \\", addr)
-		log.Printf("    -H 'Authorization: Bearer %s' \\", auth.GetDefaultKey())
+		log.Printf("    -H 'Authorization: Bearer %s' \\", exampleKey)
 		log.Printf("    -H 'Content-Type: application/json' \\")
 		log.Printf("    -d '{\"model\": \"big-pickle\", \"messages\": [{\"role\": \"user\", \"content\": \"Hello!\"}]}'")
 		log.Printf("")
@@ -89,9 +166,24 @@ func main() {
 		}
 	}()
 
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	go reloader.watch(watchCtx, cfg.Reload.PollInterval)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for waiting := true; waiting; {
+		select {
+		case <-hup:
+			log.Printf("SIGHUP received, reloading config")
+			reloader.reload()
+		case <-quit:
+			waiting = false
+		}
+	}
+	stopWatching()
 
 	log.Println("\nShutting down server...")
 
@@ -104,6 +196,10 @@ func main() {
 
 	registry.Shutdown()
 
+	if err := spending.Close(); err != nil {
+		log.Printf("Failed to save spending: %v", err)
+	}
+
 	log.Println("Server exited")
 }
 
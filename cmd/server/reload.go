@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/adapters/ollama"
+	"github.com/kashifkhan/ai-gateway/internal/adapters/openai"
+	"github.com/kashifkhan/ai-gateway/internal/adapters/opencode"
+	"github.com/kashifkhan/ai-gateway/internal/audit"
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/budget"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/limiter"
+	"github.com/kashifkhan/ai-gateway/internal/routing"
+	"github.com/kashifkhan/ai-gateway/internal/sandbox"
+)
+
+func newAdapter(id string, cfg config.BackendConfig, auditLog *audit.Logger) (adapters.Adapter, error) {
+	switch cfg.Type {
+	case "opencode":
+		return opencode.New(id, cfg, auditLog), nil
+	case "openai":
+		return openai.New(id, cfg), nil
+	case "ollama":
+		return ollama.New(id, cfg), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", cfg.Type)
+}
+
+// reloader applies a changed config file to the running gateway. Keys, rate
+// limits, concurrency limits, tool policies, routes, virtual models, budgets
+// and backends are swapped in place; backends whose config changed are
+// started fresh while requests on the old adapter finish.
+type reloader struct {
+	path     string
+	auditLog *audit.Logger
+
+	registry      *adapters.Registry
+	authenticator *auth.Authenticator
+	rateLimiter   *auth.RateLimiter
+	limiter       *limiter.Limiter
+	policies      *sandbox.Policies
+	routes        *routing.Table
+	budget        *budget.Tracker
+
+	mu     sync.Mutex
+	cfg    *config.Config
+	active map[string]string // config backend ID -> registry ID
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newReloader(path string, cfg *config.Config, auditLog *audit.Logger, registry *adapters.Registry, authenticator *auth.Authenticator, rateLimiter *auth.RateLimiter, concurrencyLimiter *limiter.Limiter, policies *sandbox.Policies, routes *routing.Table, spending *budget.Tracker) *reloader {
+	r := &reloader{
+		path:          path,
+		auditLog:      auditLog,
+		registry:      registry,
+		authenticator: authenticator,
+		rateLimiter:   rateLimiter,
+		limiter:       concurrencyLimiter,
+		policies:      policies,
+		routes:        routes,
+		budget:        spending,
+		cfg:           cfg,
+		active:        make(map[string]string),
+	}
+	r.stamps = r.stat(cfg)
+	return r
+}
+
+// startBackends initializes every enabled backend in cfg and registers it.
+func (r *reloader) startBackends(cfg *config.Config) {
+	for id, backendCfg := range cfg.Backends {
+		if backendCfg.Enabled {
+			r.startBackend(id, backendCfg)
+		}
+	}
+}
+
+func (r *reloader) startBackend(id string, backendCfg config.BackendConfig) {
+	adapter, err := newAdapter(id, backendCfg, r.auditLog)
+	if err != nil {
+		log.Printf("Warning: Backend %s: %v", id, err)
+		return
+	}
+
+	if err := adapter.Initialize(nil); err != nil {
+		log.Printf("Warning: Failed to initialize %s adapter: %v", adapter.Name(), err)
+		return
+	}
+	r.registry.SetPriority(adapter.ID(), backendCfg.Priority)
+	r.registry.Replace(adapter.ID(), adapter)
+	r.active[id] = adapter.ID()
+	log.Printf("✓ %s adapter initialized for %s", adapter.Name(), id)
+}
+
+func (r *reloader) stopBackend(id string) {
+	if registryID, ok := r.active[id]; ok {
+		r.registry.Replace(registryID, nil)
+		delete(r.active, id)
+		log.Printf("✓ Backend %s removed", id)
+	}
+}
+
+// watch reloads whenever the config file, the keys file or a file that a
+// secret was read from changes on disk. It also keeps the registry's model
+// index up to date.
+func (r *reloader) watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Pick up models that backends discovered since the last tick.
+			r.registry.Reindex()
+
+			r.mu.Lock()
+			changed := !reflect.DeepEqual(r.stat(r.cfg), r.stamps)
+			r.mu.Unlock()
+			if changed {
+				r.reload()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *reloader) stat(cfg *config.Config) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	paths := append([]string{r.path, cfg.Auth.KeysFile}, cfg.SecretFiles...)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+// reload loads and validates the config and applies it. An invalid config
+// is logged and the running one is kept.
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load(r.path)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	// Remember the files either way, so a broken edit is reported once.
+	r.stamps = r.stat(r.cfg)
+	if err != nil {
+		log.Printf("Config reload failed, keeping the running config: %v", err)
+		return
+	}
+	r.stamps = r.stat(cfg)
+
+	old := r.cfg
+	r.cfg = cfg
+
+	r.authenticator.Update(cfg.Auth.Keys, cfg.Auth.Enabled)
+	r.rateLimiter.Update(cfg.RateLimit.Enabled, cfg.RateLimit.RequestsPerMinute)
+	r.limiter.Update(cfg.Backends, cfg.Auth.Priorities)
+	r.policies.Update(cfg.Backends, cfg.Auth.ToolPolicies)
+
+	for id, backendCfg := range cfg.Backends {
+		oldCfg, existed := old.Backends[id]
+		switch {
+		case !backendCfg.Enabled:
+			r.stopBackend(id)
+		case !existed || !oldCfg.Enabled || !reflect.DeepEqual(oldCfg, backendCfg):
+			r.startBackend(id, backendCfg)
+		}
+	}
+	for id := range old.Backends {
+		if _, ok := cfg.Backends[id]; !ok {
+			r.stopBackend(id)
+		}
+	}
+	r.registry.SetDefault(cfg.DefaultBackend)
+	r.registry.SetVirtualModels(cfg.VirtualModels)
+	r.routes.Update(cfg.Routes)
+	r.budget.Update(cfg.Budget)
+
+	for _, section := range restartOnly(old, cfg) {
+		log.Printf("Warning: Changes to %q take effect after a restart", section)
+	}
+	log.Printf("✓ Config reloaded from %s", r.path)
+}
+
+// restartOnly lists the changed config sections that are read only at
+// startup.
+func restartOnly(old, cfg *config.Config) []string {
+	var sections []string
+	if !reflect.DeepEqual(old.Server, cfg.Server) {
+		sections = append(sections, "server")
+	}
+	if !reflect.DeepEqual(old.Logging, cfg.Logging) {
+		sections = append(sections, "logging")
+	}
+	if !reflect.DeepEqual(old.Responses, cfg.Responses) {
+		sections = append(sections, "responses")
+	}
+	if !reflect.DeepEqual(old.Batch, cfg.Batch) {
+		sections = append(sections, "batch")
+	}
+	if !reflect.DeepEqual(old.Structured, cfg.Structured) {
+		sections = append(sections, "structured_output")
+	}
+	if !reflect.DeepEqual(old.Sampling, cfg.Sampling) {
+		sections = append(sections, "sampling")
+	}
+	if !reflect.DeepEqual(old.Reload, cfg.Reload) {
+		sections = append(sections, "reload")
+	}
+	if old.Budget.File != cfg.Budget.File {
+		sections = append(sections, "budget.file")
+	}
+	return sections
+}
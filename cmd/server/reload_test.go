@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/budget"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/limiter"
+	"github.com/kashifkhan/ai-gateway/internal/routing"
+	"github.com/kashifkhan/ai-gateway/internal/sandbox"
+)
+
+// writeConfig writes a config with the given API key and openai backends,
+// each a backend ID mapped to its timeout.
+func writeConfig(t *testing.T, path, upstream, key string, backends map[string]string) {
+	t.Helper()
+	data := fmt.Sprintf("default_backend: up\nauth:\n  enabled: true\n  keys: [%s]\nbackends:\n  opencode:\n    enabled: false\n", key)
+	for id, timeout := range backends {
+		data += fmt.Sprintf("  %s:\n    enabled: true\n    type: openai\n    base_url: %s\n    timeout: %s\n", id, upstream, timeout)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestReloader loads path and starts its backends as main does.
+func newTestReloader(t *testing.T, path string) *reloader {
+	t.Helper()
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := adapters.NewRegistry(cfg.DefaultBackend)
+	spending, err := budget.New(cfg.Budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		registry.Shutdown()
+		spending.Close()
+	})
+
+	r := newReloader(path, cfg, nil, registry,
+		auth.NewAuthenticator(cfg.Auth.Keys, cfg.Auth.Enabled),
+		auth.NewRateLimiter(cfg.RateLimit.Enabled, cfg.RateLimit.RequestsPerMinute),
+		limiter.New(cfg.Backends, cfg.Auth.Priorities),
+		sandbox.New(cfg.Backends, cfg.Auth.ToolPolicies),
+		routing.New(cfg.Routes),
+		spending,
+	)
+	r.startBackends(cfg)
+	return r
+}
+
+func newUpstream(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"id":"gpt-4o"}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestReload(t *testing.T) {
+	upstream := newUpstream(t)
+
+	tests := []struct {
+		name         string
+		key          string
+		backends     map[string]string
+		wantKey      string
+		wantBackends []string
+		wantRestart  bool
+	}{
+		{
+			name:         "new key",
+			key:          "key-2",
+			backends:     map[string]string{"up": "5s"},
+			wantKey:      "key-2",
+			wantBackends: []string{"up"},
+		},
+		{
+			name:         "changed backend",
+			key:          "key-1",
+			backends:     map[string]string{"up": "9s"},
+			wantKey:      "key-1",
+			wantBackends: []string{"up"},
+			wantRestart:  true,
+		},
+		{
+			name:         "added backend",
+			key:          "key-1",
+			backends:     map[string]string{"up": "5s", "more": "5s"},
+			wantKey:      "key-1",
+			wantBackends: []string{"more", "up"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			writeConfig(t, path, upstream, "key-1", map[string]string{"up": "5s", "gone": "5s"})
+			r := newTestReloader(t, path)
+			before, _ := r.registry.Get("up")
+
+			writeConfig(t, path, upstream, tt.key, tt.backends)
+			r.reload()
+
+			for _, key := range []string{"key-1", "key-2"} {
+				if got := r.authenticator.ValidateKey(key); got != (key == tt.wantKey) {
+					t.Errorf("key %s valid = %v, want %v", key, got, key == tt.wantKey)
+				}
+			}
+			var ids []string
+			for _, a := range r.registry.List() {
+				ids = append(ids, a.ID())
+			}
+			sort.Strings(ids)
+			if !reflect.DeepEqual(ids, tt.wantBackends) {
+				t.Errorf("backends = %v, want %v", ids, tt.wantBackends)
+			}
+			if after, _ := r.registry.Get("up"); (after != before) != tt.wantRestart {
+				t.Errorf("backend up restarted = %v, want %v", after != before, tt.wantRestart)
+			}
+		})
+	}
+}
+
+func TestReloadInvalidConfig(t *testing.T) {
+	upstream := newUpstream(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, upstream, "key-1", map[string]string{"up": "5s"})
+	r := newTestReloader(t, path)
+
+	// A backend without a timeout fails validation.
+	writeConfig(t, path, upstream, "key-2", map[string]string{"up": "0s"})
+	r.reload()
+
+	if !r.authenticator.ValidateKey("key-1") || r.authenticator.ValidateKey("key-2") {
+		t.Error("the running keys were replaced by an invalid config")
+	}
+	if _, ok := r.registry.Get("up"); !ok {
+		t.Error("backend up was stopped by an invalid config")
+	}
+	if !reflect.DeepEqual(r.stat(r.cfg), r.stamps) {
+		t.Error("the invalid config would be reloaded again on the next tick")
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	upstream := newUpstream(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, upstream, "key-1", map[string]string{"up": "5s"})
+	r := newTestReloader(t, path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx, 10*time.Millisecond)
+
+	writeConfig(t, path, upstream, "key-22", map[string]string{"up": "5s"})
+	deadline := time.Now().Add(2 * time.Second)
+	for !r.authenticator.ValidateKey("key-22") {
+		if time.Now().After(deadline) {
+			t.Fatal("the changed config was not picked up")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRestartOnly(t *testing.T) {
+	old := config.DefaultConfig()
+	cfg := config.DefaultConfig()
+	cfg.Server.Port++
+	cfg.Batch.Concurrency++
+	cfg.Budget.File = "spend.json"
+	cfg.Budget.Monthly = 10
+	cfg.Auth.Keys = []string{"key"}
+
+	want := []string{"server", "batch", "budget.file"}
+	if got := restartOnly(old, cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("restartOnly = %v, want %v", got, want)
+	}
+}
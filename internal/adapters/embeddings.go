@@ -0,0 +1,50 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const DefaultEmbeddingBatchSize = 256
+
+// EmbedBatches splits inputs into batches of at most size and calls embed for
+// each in turn, merging the vectors and usage into a single response.
+func EmbedBatches(ctx context.Context, inputs []string, size int, embed func(ctx context.Context, batch []string) ([][]float64, int, error)) (*models.EmbeddingResponse, error) {
+	if size <= 0 {
+		size = DefaultEmbeddingBatchSize
+	}
+
+	resp := &models.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]models.EmbeddingData, 0, len(inputs)),
+	}
+
+	for start := 0; start < len(inputs); start += size {
+		end := start + size
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+
+		vectors, tokens, err := embed(ctx, inputs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) != end-start {
+			return nil, fmt.Errorf("upstream returned %d embeddings for %d inputs", len(vectors), end-start)
+		}
+
+		for i, v := range vectors {
+			resp.Data = append(resp.Data, models.EmbeddingData{
+				Object:    "embedding",
+				Index:     start + i,
+				Embedding: v,
+			})
+		}
+		resp.Usage.PromptTokens += tokens
+	}
+
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+	return resp, nil
+}
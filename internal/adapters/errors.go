@@ -0,0 +1,209 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Error kinds that adapters attach to upstream failures. The API layer maps
+// each kind to an OpenAI-style error type, code and HTTP status.
+var (
+	ErrInvalidRequest        = errors.New("invalid request")
+	ErrBackendTimeout        = errors.New("backend timeout")
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	ErrContentFiltered       = errors.New("content filtered")
+	ErrUpstreamRateLimit     = errors.New("upstream rate limit")
+	ErrUpstreamAuth          = errors.New("upstream authentication failed")
+	ErrModelNotFound         = errors.New("model not found")
+	ErrToolNotAllowed        = errors.New("tool not allowed")
+	ErrStructuredOutput      = errors.New("invalid structured output")
+)
+
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func NewError(kind error, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// UpstreamError is a failure the upstream reported in its own words, outside
+// of an HTTP error status: an error field in a response or a stream event.
+// StatusCode and Code are set when the upstream gave them.
+type UpstreamError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Message
+}
+
+// Classify attaches an error kind to err. Structured fields decide first: the
+// HTTP status, then the provider's error code; the wording of the upstream
+// error message is only consulted when neither is conclusive. Errors that
+// already carry a kind, or that match nothing, are returned unchanged.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var typed *Error
+	if errors.As(err, &typed) {
+		return err
+	}
+
+	if kind := classifyKind(err); kind != nil {
+		return &Error{Kind: kind, Message: err.Error()}
+	}
+	return err
+}
+
+func classifyKind(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrBackendTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrBackendTimeout
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		code, message := providerError(statusErr.Body)
+		return classifyFields(statusErr.StatusCode, code, message)
+	}
+
+	var upstream *UpstreamError
+	if errors.As(err, &upstream) {
+		return classifyFields(upstream.StatusCode, upstream.Code, upstream.Message)
+	}
+
+	return nil
+}
+
+func classifyFields(status int, code, message string) error {
+	if kind := classifyStatus(status); kind != nil {
+		return kind
+	}
+	if kind := classifyCode(code); kind != nil {
+		return kind
+	}
+	return ClassifyMessage(message)
+}
+
+func classifyStatus(status int) error {
+	switch status {
+	case 401, 403:
+		return ErrUpstreamAuth
+	case 408, 504:
+		return ErrBackendTimeout
+	case 413:
+		return ErrContextLengthExceeded
+	case 429:
+		return ErrUpstreamRateLimit
+	}
+	return nil
+}
+
+// classifyCode maps the machine-readable error codes and types of the
+// providers the gateway talks to.
+func classifyCode(code string) error {
+	switch strings.ToLower(code) {
+	case "context_length_exceeded", "string_above_max_length":
+		return ErrContextLengthExceeded
+	case "content_filter", "content_policy_violation":
+		return ErrContentFiltered
+	case "rate_limit_exceeded", "rate_limit_error", "insufficient_quota", "resource_exhausted":
+		return ErrUpstreamRateLimit
+	case "invalid_api_key", "authentication_error", "permission_error", "unauthenticated", "permission_denied", "providerautherror":
+		return ErrUpstreamAuth
+	case "model_not_found", "not_found_error", "modelnotfounderror", "providermodelnotfounderror":
+		return ErrModelNotFound
+	}
+	return nil
+}
+
+// providerError extracts the error code and message from an error response
+// body in the shapes OpenAI, Anthropic, Google and Ollama use. A body that is
+// not such an error yields nothing, so its text is never matched.
+func providerError(body string) (code, message string) {
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || len(envelope.Error) == 0 {
+		return "", ""
+	}
+
+	if err := json.Unmarshal(envelope.Error, &message); err == nil {
+		return "", message
+	}
+
+	var detail struct {
+		Code    json.RawMessage `json:"code"`
+		Type    string          `json:"type"`
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(envelope.Error, &detail); err != nil {
+		return "", ""
+	}
+
+	// OpenAI gives a string code, Google a numeric one next to a status name.
+	if err := json.Unmarshal(detail.Code, &code); err != nil || code == "" {
+		code = detail.Status
+	}
+	if code == "" {
+		code = detail.Type
+	}
+	return code, detail.Message
+}
+
+// ClassifyMessage recognises the wording upstream providers commonly use for
+// well-known failures. Only pass it error messages, never response content.
+func ClassifyMessage(msg string) error {
+	msg = strings.ToLower(msg)
+
+	switch {
+	case containsAny(msg, "context length", "context_length", "context window", "maximum context", "prompt is too long"):
+		return ErrContextLengthExceeded
+	case containsAny(msg, "content filter", "content_filter", "content management policy", "safety filter", "safety settings"):
+		return ErrContentFiltered
+	case containsAny(msg, "rate limit", "rate_limit", "too many requests", "quota exceeded", "exceeded your current quota", "resource_exhausted"):
+		return ErrUpstreamRateLimit
+	case containsAny(msg, "invalid api key", "invalid_api_key", "incorrect api key", "authentication failed"):
+		return ErrUpstreamAuth
+	case containsAny(msg, "model not found", "model_not_found", "unknown model", "not found, try pulling it"):
+		return ErrModelNotFound
+	case containsAny(msg, "timed out", "deadline exceeded"):
+		return ErrBackendTimeout
+	}
+	return nil
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
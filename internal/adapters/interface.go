@@ -26,6 +26,22 @@ type Adapter interface {
 	SupportsStreaming() bool
 	SupportsTools() bool
 	SupportsSessions() bool
+
+	// SupportsResponseFormat reports whether the upstream honours
+	// response_format itself; otherwise the gateway emulates it.
+	SupportsResponseFormat() bool
+
+	// SupportsParameter reports whether the upstream honours the named
+	// sampling parameter (see the models.Param constants).
+	SupportsParameter(name string) bool
+}
+
+// EmbeddingAdapter is implemented by adapters whose upstream can compute
+// embeddings. Embed receives the decoded inputs in req.Inputs and returns
+// one []float64 vector per input, in order.
+type EmbeddingAdapter interface {
+	Adapter
+	Embed(ctx context.Context, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error)
 }
 
 type BaseAdapter struct {
@@ -57,3 +73,11 @@ func (a *BaseAdapter) SupportsTools() bool {
 func (a *BaseAdapter) SupportsSessions() bool {
 	return false
 }
+
+func (a *BaseAdapter) SupportsResponseFormat() bool {
+	return false
+}
+
+func (a *BaseAdapter) SupportsParameter(name string) bool {
+	return false
+}
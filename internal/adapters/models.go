@@ -0,0 +1,92 @@
+package adapters
+
+import (
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// started stands in for the creation time of models whose upstream does not
+// report one, so that Created does not change from one listing to the next.
+var started = time.Now().Unix()
+
+// DescribeModel builds a /v1/models entry. base holds what the upstream
+// reported about the model; fields set in cfg win. cfg is nil for models
+// that were only discovered.
+func DescribeModel(base models.Model, cfg *config.ModelConfig) models.Model {
+	m := base
+	m.Object = "model"
+	if m.Created == 0 {
+		m.Created = started
+	}
+	if cfg == nil {
+		return m
+	}
+
+	m.Free = cfg.Free
+	if len(cfg.Aliases) > 0 {
+		m.Aliases = cfg.Aliases
+	}
+	if cfg.Created > 0 {
+		m.Created = cfg.Created
+	}
+	if cfg.ContextWindow > 0 {
+		m.ContextWindow = cfg.ContextWindow
+	}
+	if cfg.MaxOutputTokens > 0 {
+		m.MaxOutputTokens = cfg.MaxOutputTokens
+	}
+
+	caps := models.ModelCapabilities{}
+	if m.Capabilities != nil {
+		caps = *m.Capabilities
+	}
+	setCapability(&caps.Tools, cfg.Capabilities.Tools)
+	setCapability(&caps.Vision, cfg.Capabilities.Vision)
+	setCapability(&caps.Streaming, cfg.Capabilities.Streaming)
+	setCapability(&caps.Reasoning, cfg.Capabilities.Reasoning)
+	m.Capabilities = &caps
+
+	if cfg.Pricing != nil {
+		m.Pricing = &models.ModelPricing{Input: cfg.Pricing.Input, Output: cfg.Pricing.Output}
+	}
+	if cfg.Deprecated {
+		m.Deprecated = true
+	}
+	if cfg.DeprecationDate != "" {
+		m.DeprecationDate = cfg.DeprecationDate
+	}
+	if cfg.ReplacedBy != "" {
+		m.ReplacedBy = cfg.ReplacedBy
+	}
+	return m
+}
+
+func setCapability(dst *bool, value *bool) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+// FindModel returns the entry the adapter lists for a model ID or one of its
+// aliases.
+func FindModel(adapter Adapter, modelID string) (models.Model, bool) {
+	list, err := adapter.ListModels()
+	if err != nil {
+		return models.Model{}, false
+	}
+	for _, m := range list {
+		if m.ID == modelID {
+			return m, true
+		}
+	}
+	for _, m := range list {
+		for _, alias := range m.Aliases {
+			if alias == modelID {
+				return m, true
+			}
+		}
+	}
+	return models.Model{}, false
+}
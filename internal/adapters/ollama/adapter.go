@@ -0,0 +1,536 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const (
+	DefaultPort              = 11434
+	DefaultFirstTokenTimeout = 120 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+)
+
+// Adapter talks to an Ollama server through its native /api endpoints.
+type Adapter struct {
+	adapters.BaseAdapter
+	id                string
+	config            config.BackendConfig
+	httpClient        *http.Client
+	streamClient      *http.Client
+	retry             adapters.RetryPolicy
+	firstTokenTimeout time.Duration
+	idleTimeout       time.Duration
+	baseURL           string
+	models            map[string]config.ModelConfig
+	aliases           map[string]string
+	discovered        []models.Model
+}
+
+func New(id string, cfg config.BackendConfig) *Adapter {
+	return &Adapter{
+		BaseAdapter: adapters.BaseAdapter{},
+		id:          id,
+		config:      cfg,
+		models:      make(map[string]config.ModelConfig),
+		aliases:     make(map[string]string),
+	}
+}
+
+func (a *Adapter) ID() string {
+	return a.id
+}
+
+func (a *Adapter) Name() string {
+	return "Ollama"
+}
+
+func (a *Adapter) Initialize(cfg map[string]interface{}) error {
+	a.baseURL = strings.TrimSuffix(a.config.BaseURL, "/")
+	if a.baseURL == "" {
+		port := a.config.Port
+		if port == 0 {
+			port = DefaultPort
+		}
+		a.baseURL = fmt.Sprintf("http://%s:%d", a.config.Host, port)
+	}
+
+	a.httpClient = &http.Client{
+		Timeout: a.config.Timeout,
+	}
+	a.streamClient = &http.Client{}
+	a.retry = adapters.NewRetryPolicy(a.config.Retry)
+
+	a.firstTokenTimeout = a.config.Streaming.FirstTokenTimeout
+	if a.firstTokenTimeout <= 0 {
+		a.firstTokenTimeout = DefaultFirstTokenTimeout
+	}
+	a.idleTimeout = a.config.Streaming.IdleTimeout
+	if a.idleTimeout <= 0 {
+		a.idleTimeout = DefaultIdleTimeout
+	}
+
+	for _, m := range a.config.Models {
+		a.models[m.ID] = m
+		for _, alias := range m.Aliases {
+			a.aliases[alias] = m.ID
+		}
+	}
+
+	if err := a.HealthCheck(); err != nil {
+		a.SetHealthy(false)
+		return nil
+	}
+
+	// Without a configured model list, serve every locally pulled model.
+	if len(a.models) == 0 {
+		a.discovered, _ = a.fetchModels()
+	}
+
+	a.SetHealthy(true)
+	return nil
+}
+
+func (a *Adapter) Shutdown() error {
+	a.httpClient.CloseIdleConnections()
+	a.streamClient.CloseIdleConnections()
+	return nil
+}
+
+func (a *Adapter) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := a.send(ctx, a.httpClient, "GET", "/api/tags", nil)
+	if err != nil {
+		a.SetHealthy(false)
+		return err
+	}
+	resp.Body.Close()
+
+	a.SetHealthy(true)
+	return nil
+}
+
+type tagsResponse struct {
+	Models []struct {
+		Name       string    `json:"name"`
+		ModifiedAt time.Time `json:"modified_at"`
+	} `json:"models"`
+}
+
+func (a *Adapter) fetchModels() ([]models.Model, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := a.send(ctx, a.httpClient, "GET", "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tags tagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+
+	result := make([]models.Model, 0, len(tags.Models))
+	for _, t := range tags.Models {
+		m := a.baseModel(t.Name)
+		if !t.ModifiedAt.IsZero() {
+			m.Created = t.ModifiedAt.Unix()
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+func (a *Adapter) ListModels() ([]models.Model, error) {
+	result := make([]models.Model, 0, len(a.config.Models)+len(a.discovered))
+	for i := range a.config.Models {
+		m := &a.config.Models[i]
+		result = append(result, adapters.DescribeModel(a.baseModel(m.ID), m))
+	}
+	for _, m := range a.discovered {
+		result = append(result, adapters.DescribeModel(m, nil))
+	}
+	return result, nil
+}
+
+func (a *Adapter) baseModel(id string) models.Model {
+	return models.Model{
+		ID:           id,
+		OwnedBy:      "ollama",
+		Backend:      a.id,
+		Free:         true,
+		Capabilities: &models.ModelCapabilities{Streaming: true},
+	}
+}
+
+func (a *Adapter) SupportsModel(modelID string) bool {
+	if _, ok := a.models[modelID]; ok {
+		return true
+	}
+	if _, ok := a.aliases[modelID]; ok {
+		return true
+	}
+	for _, m := range a.discovered {
+		if m.ID == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Adapter) ResolveModel(modelID string) string {
+	if actual, ok := a.aliases[modelID]; ok {
+		return actual
+	}
+	return modelID
+}
+
+func (a *Adapter) SupportsStreaming() bool {
+	return true
+}
+
+func (a *Adapter) SupportsResponseFormat() bool {
+	return true
+}
+
+func (a *Adapter) SupportsParameter(name string) bool {
+	switch name {
+	case models.ParamTemperature, models.ParamTopP, models.ParamMaxTokens, models.ParamStop,
+		models.ParamSeed, models.ParamPresencePenalty, models.ParamFrequencyPenalty:
+		return true
+	}
+	return false
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []chatMessage          `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Format   json.RawMessage        `json:"format,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+// chatResponse is both the full /api/chat response and one line of its
+// NDJSON stream.
+type chatResponse struct {
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           string      `json:"error"`
+}
+
+func newChatRequest(req *models.ChatRequest, stream bool) chatRequest {
+	body := chatRequest{
+		Model:   req.Model,
+		Stream:  stream,
+		Options: make(map[string]interface{}),
+	}
+	for _, msg := range req.Messages {
+		body.Messages = append(body.Messages, chatMessage{Role: msg.Role, Content: msg.Content})
+	}
+	if req.Temperature != nil {
+		body.Options["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		body.Options["top_p"] = *req.TopP
+	}
+	if req.MaxTokens != nil {
+		body.Options["num_predict"] = *req.MaxTokens
+	}
+	if len(req.Stop) > 0 {
+		body.Options["stop"] = req.Stop
+	}
+	if req.Seed != nil {
+		body.Options["seed"] = *req.Seed
+	}
+	if req.PresencePenalty != nil {
+		body.Options["presence_penalty"] = *req.PresencePenalty
+	}
+	if req.FrequencyPenalty != nil {
+		body.Options["frequency_penalty"] = *req.FrequencyPenalty
+	}
+
+	// Ollama takes "json" for free-form JSON or the schema itself.
+	switch {
+	case req.ResponseFormat == nil:
+	case req.ResponseFormat.Type == models.ResponseFormatJSONObject:
+		body.Format = json.RawMessage(`"json"`)
+	case req.ResponseFormat.Type == models.ResponseFormatJSONSchema && req.ResponseFormat.JSONSchema != nil:
+		body.Format = req.ResponseFormat.JSONSchema.Schema
+	}
+	return body
+}
+
+func finishReason(doneReason string) string {
+	if doneReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+func (a *Adapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.ChatResponse, error) {
+	body, _ := json.Marshal(newChatRequest(req, false))
+
+	// A request the upstream took in may already have been generated and
+	// billed, so only one it never received is retried.
+	var chatResp chatResponse
+	err := a.retry.Do(ctx, func() error {
+		resp, err := a.send(ctx, a.httpClient, "POST", "/api/chat", body)
+		if err != nil {
+			if adapters.Unsent(err) {
+				return err
+			}
+			return adapters.Permanent(err)
+		}
+		defer resp.Body.Close()
+		return adapters.Permanent(json.NewDecoder(resp.Body).Decode(&chatResp))
+	})
+	if err != nil {
+		return nil, adapters.Classify(fmt.Errorf("chat failed: %w", err))
+	}
+	if chatResp.Error != "" {
+		return nil, adapters.Classify(fmt.Errorf("chat failed: %w", &adapters.UpstreamError{Message: chatResp.Error}))
+	}
+
+	return &models.ChatResponse{
+		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []models.Choice{
+			{
+				Index:        0,
+				Message:      models.Message{Role: "assistant", Content: chatResp.Message.Content},
+				FinishReason: finishReason(chatResp.DoneReason),
+			},
+		},
+		Usage: &models.Usage{
+			PromptTokens:     chatResp.PromptEvalCount,
+			CompletionTokens: chatResp.EvalCount,
+			TotalTokens:      chatResp.PromptEvalCount + chatResp.EvalCount,
+		},
+	}, nil
+}
+
+func (a *Adapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamChunk, <-chan error) {
+	chunks := make(chan models.StreamChunk, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+
+		if err := a.stream(ctx, req, chunks); err != nil {
+			errs <- adapters.Classify(err)
+		}
+	}()
+
+	return chunks, errs
+}
+
+func (a *Adapter) stream(ctx context.Context, req *models.ChatRequest, chunks chan<- models.StreamChunk) error {
+	body, _ := json.Marshal(newChatRequest(req, true))
+
+	// The watchdog cancels the request when the upstream goes quiet for
+	// longer than the first-token or idle timeout. Ollama may need to load
+	// the model first, hence the longer default for the first token.
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stalled atomic.Bool
+	watchdog := time.AfterFunc(a.firstTokenTimeout, func() {
+		stalled.Store(true)
+		cancel()
+	})
+	defer watchdog.Stop()
+
+	// As in Chat, only a request the upstream never received is retried.
+	var resp *http.Response
+	err := a.retry.Do(streamCtx, func() error {
+		var err error
+		resp, err = a.send(streamCtx, a.streamClient, "POST", "/api/chat", body)
+		if err != nil && !adapters.Unsent(err) {
+			return adapters.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		if stalled.Load() {
+			return adapters.NewError(adapters.ErrBackendTimeout, "no response from upstream within %s", a.firstTokenTimeout)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	id := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	created := time.Now().Unix()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	first := true
+	for scanner.Scan() {
+		watchdog.Reset(a.idleTimeout)
+
+		var line chatResponse
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		if line.Error != "" {
+			return fmt.Errorf("stream failed: %w", &adapters.UpstreamError{Message: line.Error})
+		}
+
+		choice := models.ChunkChoice{Delta: models.Delta{Content: line.Message.Content}}
+		if first {
+			choice.Delta.Role = "assistant"
+			first = false
+		}
+		if line.Done {
+			choice.FinishReason = finishReason(line.DoneReason)
+		}
+
+		chunk := models.StreamChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   req.Model,
+			Choices: []models.ChunkChoice{choice},
+		}
+		select {
+		case chunks <- chunk:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if line.Done {
+			if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+				usage := models.StreamChunk{
+					ID:      id,
+					Object:  "chat.completion.chunk",
+					Created: created,
+					Model:   req.Model,
+					Choices: []models.ChunkChoice{},
+					Usage: &models.Usage{
+						PromptTokens:     line.PromptEvalCount,
+						CompletionTokens: line.EvalCount,
+						TotalTokens:      line.PromptEvalCount + line.EvalCount,
+					},
+				}
+				select {
+				case chunks <- usage:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
+	}
+
+	if stalled.Load() {
+		return adapters.NewError(adapters.ErrBackendTimeout, "upstream stream stalled for more than %s", a.idleTimeout)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type embedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions *int     `json:"dimensions,omitempty"`
+}
+
+type embedResponse struct {
+	Embeddings      [][]float64 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+func (a *Adapter) Embed(ctx context.Context, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	resp, err := adapters.EmbedBatches(ctx, req.Inputs, a.config.Embeddings.BatchSize, func(ctx context.Context, batch []string) ([][]float64, int, error) {
+		body, _ := json.Marshal(embedRequest{
+			Model:      req.Model,
+			Input:      batch,
+			Dimensions: req.Dimensions,
+		})
+
+		var embResp embedResponse
+		err := a.retry.Do(ctx, func() error {
+			resp, err := a.send(ctx, a.httpClient, "POST", "/api/embed", body)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return json.NewDecoder(resp.Body).Decode(&embResp)
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return embResp.Embeddings, embResp.PromptEvalCount, nil
+	})
+	if err != nil {
+		return nil, adapters.Classify(fmt.Errorf("embedding failed: %w", err))
+	}
+
+	resp.Model = req.Model
+	return resp, nil
+}
+
+// send performs a request against the Ollama API and returns the response if
+// it succeeded. Failed responses are read and returned as StatusError.
+func (a *Adapter) send(ctx context.Context, client *http.Client, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if a.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.config.APIKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, adapters.NewError(adapters.ErrModelNotFound, "%s", strings.TrimSpace(string(bodyBytes)))
+		}
+		return nil, &adapters.StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: adapters.RetryAfter(resp.Header)}
+	}
+
+	return resp, nil
+}
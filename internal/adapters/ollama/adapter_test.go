@@ -0,0 +1,282 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// newTestAdapter points an adapter at handler. The health check and model
+// list requests are answered with empty lists.
+func newTestAdapter(t *testing.T, cfg config.BackendConfig, handler http.HandlerFunc) *Adapter {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/api/tags" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg.BaseURL = srv.URL
+	a := New("test", cfg)
+	if err := a.Initialize(nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Shutdown() })
+	return a
+}
+
+func TestSendRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "seconds", header: "7", want: 7 * time.Second},
+		{name: "missing", header: "", want: 0},
+		{name: "not a number", header: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAdapter(t, config.BackendConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})
+
+			_, err := a.send(context.Background(), a.httpClient, "POST", "/anything", []byte(`{}`))
+			var statusErr *adapters.StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("err = %v, want a StatusError", err)
+			}
+			if statusErr.RetryAfter != tt.want {
+				t.Errorf("RetryAfter = %s, want %s", statusErr.RetryAfter, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewChatRequest(t *testing.T) {
+	temperature, maxTokens, seed := 0.2, 100, 7
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+
+	tests := []struct {
+		name string
+		req  models.ChatRequest
+		want string
+	}{
+		{
+			name: "plain",
+			req:  models.ChatRequest{Model: "llama3", Messages: messages},
+			want: `{"model":"llama3","messages":[{"role":"user","content":"hi"}],"stream":false}`,
+		},
+		{
+			name: "sampling options",
+			req:  models.ChatRequest{Model: "llama3", Messages: messages, Temperature: &temperature, MaxTokens: &maxTokens, Seed: &seed, Stop: []string{"END"}},
+			want: `{"model":"llama3","messages":[{"role":"user","content":"hi"}],"stream":false,"options":{"num_predict":100,"seed":7,"stop":["END"],"temperature":0.2}}`,
+		},
+		{
+			name: "json object",
+			req:  models.ChatRequest{Model: "llama3", Messages: messages, ResponseFormat: &models.ResponseFormat{Type: models.ResponseFormatJSONObject}},
+			want: `{"model":"llama3","messages":[{"role":"user","content":"hi"}],"stream":false,"format":"json"}`,
+		},
+		{
+			name: "json schema",
+			req: models.ChatRequest{Model: "llama3", Messages: messages, ResponseFormat: &models.ResponseFormat{
+				Type:       models.ResponseFormatJSONSchema,
+				JSONSchema: &models.JSONSchemaFormat{Name: "answer", Schema: json.RawMessage(`{"type":"object"}`)},
+			}},
+			want: `{"model":"llama3","messages":[{"role":"user","content":"hi"}],"stream":false,"format":{"type":"object"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(newChatRequest(&tt.req, false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		retryAfter   string
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{name: "turned away", status: http.StatusServiceUnavailable, retryAfter: "1", wantAttempts: 2},
+		{name: "bad gateway", status: http.StatusBadGateway, wantAttempts: 1, wantErr: true},
+		{name: "unavailable without Retry-After", status: http.StatusServiceUnavailable, wantAttempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			a := newTestAdapter(t, config.BackendConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) == 1 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte(`{"message":{"role":"assistant","content":"hi"},"done":true,"done_reason":"stop"}`))
+			})
+
+			_, err := a.Chat(context.Background(), &models.ChatRequest{Model: "m"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	lines := []string{
+		`{"message":{"role":"assistant","content":"Hel"},"done":false}`,
+		`not json`,
+		`{"message":{"role":"assistant","content":"lo"},"done":false}`,
+		`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":3,"eval_count":2}`,
+	}
+
+	tests := []struct {
+		name         string
+		lines        []string
+		includeUsage bool
+		wantUsage    *models.Usage
+		wantErr      bool
+	}{
+		{name: "done", lines: lines},
+		{name: "usage", lines: lines, includeUsage: true, wantUsage: &models.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}},
+		{name: "error line", lines: append(lines[:1:1], `{"error":"model crashed"}`), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAdapter(t, config.BackendConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				var body chatRequest
+				data, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(data, &body); err != nil || !body.Stream {
+					t.Errorf("request body = %s, want a stream", data)
+				}
+				for _, line := range tt.lines {
+					fmt.Fprintln(w, line)
+				}
+			})
+
+			req := &models.ChatRequest{Model: "alias", StreamOptions: &models.StreamOptions{IncludeUsage: tt.includeUsage}}
+			chunks, errs := a.ChatStream(context.Background(), req)
+			var roles, text, finishReason string
+			var usage *models.Usage
+			for chunk := range chunks {
+				if chunk.Model != "alias" {
+					t.Errorf("chunk model = %q, want the requested alias", chunk.Model)
+				}
+				for _, choice := range chunk.Choices {
+					roles += choice.Delta.Role
+					text += choice.Delta.Content
+					if choice.FinishReason != "" {
+						finishReason = choice.FinishReason
+					}
+				}
+				if chunk.Usage != nil {
+					usage = chunk.Usage
+				}
+			}
+			err := <-errs
+
+			if tt.wantErr {
+				var upstreamErr *adapters.UpstreamError
+				if !errors.As(err, &upstreamErr) {
+					t.Errorf("err = %v, want an UpstreamError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if roles != "assistant" || text != "Hello" || finishReason != "length" {
+				t.Errorf("roles = %q, text = %q, finish reason = %q, want one assistant role, Hello and length", roles, text, finishReason)
+			}
+			if !reflect.DeepEqual(usage, tt.wantUsage) {
+				t.Errorf("usage = %+v, want %+v", usage, tt.wantUsage)
+			}
+		})
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	var bodies []embedRequest
+	a := newTestAdapter(t, config.BackendConfig{Embeddings: config.EmbeddingsConfig{BatchSize: 2}}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("path = %s, want /api/embed", r.URL.Path)
+		}
+		var body embedRequest
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		bodies = append(bodies, body)
+
+		var resp embedResponse
+		for _, input := range body.Input {
+			resp.Embeddings = append(resp.Embeddings, []float64{float64(len(input))})
+		}
+		resp.PromptEvalCount = len(body.Input)
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	dimensions := 8
+	resp, err := a.Embed(context.Background(), &models.EmbeddingRequest{
+		Model:      "nomic-embed-text",
+		Dimensions: &dimensions,
+		Inputs:     []string{"a", "bb", "ccc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bodies) != 2 || !reflect.DeepEqual(bodies[0].Input, []string{"a", "bb"}) || !reflect.DeepEqual(bodies[1].Input, []string{"ccc"}) {
+		t.Fatalf("batches = %+v, want [a bb] and [ccc]", bodies)
+	}
+	for _, body := range bodies {
+		if body.Dimensions == nil || *body.Dimensions != 8 {
+			t.Errorf("dimensions = %v, want 8 sent upstream", body.Dimensions)
+		}
+	}
+
+	for i, d := range resp.Data {
+		if v, ok := d.Embedding.([]float64); d.Index != i || !ok || len(v) != 1 || v[0] != float64(i+1) {
+			t.Errorf("data[%d] = %+v, want the embedding of input %d", i, d, i)
+		}
+	}
+	if resp.Model != "nomic-embed-text" || resp.Usage != (models.EmbeddingUsage{PromptTokens: 3, TotalTokens: 3}) {
+		t.Errorf("response = %+v", resp)
+	}
+}
@@ -0,0 +1,450 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const (
+	DefaultFirstTokenTimeout = 60 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+)
+
+// Adapter talks to any upstream that implements the OpenAI REST API, such
+// as OpenAI itself, vLLM, LiteLLM or LM Studio.
+type Adapter struct {
+	adapters.BaseAdapter
+	id                string
+	config            config.BackendConfig
+	httpClient        *http.Client
+	streamClient      *http.Client
+	retry             adapters.RetryPolicy
+	firstTokenTimeout time.Duration
+	idleTimeout       time.Duration
+	baseURL           string
+	models            map[string]config.ModelConfig
+	aliases           map[string]string
+	discovered        []models.Model
+}
+
+func New(id string, cfg config.BackendConfig) *Adapter {
+	return &Adapter{
+		BaseAdapter: adapters.BaseAdapter{},
+		id:          id,
+		config:      cfg,
+		models:      make(map[string]config.ModelConfig),
+		aliases:     make(map[string]string),
+	}
+}
+
+func (a *Adapter) ID() string {
+	return a.id
+}
+
+func (a *Adapter) Name() string {
+	return "OpenAI-compatible"
+}
+
+func (a *Adapter) Initialize(cfg map[string]interface{}) error {
+	a.baseURL = strings.TrimSuffix(a.config.BaseURL, "/")
+	if a.baseURL == "" {
+		a.baseURL = fmt.Sprintf("http://%s:%d/v1", a.config.Host, a.config.Port)
+	}
+
+	a.httpClient = &http.Client{
+		Timeout: a.config.Timeout,
+	}
+	a.streamClient = &http.Client{}
+	a.retry = adapters.NewRetryPolicy(a.config.Retry)
+
+	a.firstTokenTimeout = a.config.Streaming.FirstTokenTimeout
+	if a.firstTokenTimeout <= 0 {
+		a.firstTokenTimeout = DefaultFirstTokenTimeout
+	}
+	a.idleTimeout = a.config.Streaming.IdleTimeout
+	if a.idleTimeout <= 0 {
+		a.idleTimeout = DefaultIdleTimeout
+	}
+
+	for _, m := range a.config.Models {
+		a.models[m.ID] = m
+		for _, alias := range m.Aliases {
+			a.aliases[alias] = m.ID
+		}
+	}
+
+	if err := a.HealthCheck(); err != nil {
+		a.SetHealthy(false)
+		return nil
+	}
+
+	// Without a configured model list, serve whatever the upstream offers.
+	if len(a.models) == 0 {
+		a.discovered, _ = a.fetchModels()
+	}
+
+	a.SetHealthy(true)
+	return nil
+}
+
+func (a *Adapter) Shutdown() error {
+	a.httpClient.CloseIdleConnections()
+	a.streamClient.CloseIdleConnections()
+	return nil
+}
+
+func (a *Adapter) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := a.send(ctx, a.httpClient, "GET", "/models", nil)
+	if err != nil {
+		a.SetHealthy(false)
+		return err
+	}
+	resp.Body.Close()
+
+	a.SetHealthy(true)
+	return nil
+}
+
+func (a *Adapter) fetchModels() ([]models.Model, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := a.send(ctx, a.httpClient, "GET", "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list models.ModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+	return list.Data, nil
+}
+
+func (a *Adapter) ListModels() ([]models.Model, error) {
+	result := make([]models.Model, 0, len(a.config.Models)+len(a.discovered))
+	for i := range a.config.Models {
+		m := &a.config.Models[i]
+		result = append(result, adapters.DescribeModel(a.baseModel(m.ID), m))
+	}
+	for _, upstream := range a.discovered {
+		m := a.baseModel(upstream.ID)
+		m.Created = upstream.Created
+		if upstream.OwnedBy != "" {
+			m.OwnedBy = upstream.OwnedBy
+		}
+		result = append(result, adapters.DescribeModel(m, nil))
+	}
+	return result, nil
+}
+
+func (a *Adapter) baseModel(id string) models.Model {
+	return models.Model{
+		ID:           id,
+		OwnedBy:      a.id,
+		Backend:      a.id,
+		Capabilities: &models.ModelCapabilities{Tools: true, Streaming: true},
+	}
+}
+
+func (a *Adapter) SupportsModel(modelID string) bool {
+	if _, ok := a.models[modelID]; ok {
+		return true
+	}
+	if _, ok := a.aliases[modelID]; ok {
+		return true
+	}
+	for _, m := range a.discovered {
+		if m.ID == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Adapter) ResolveModel(modelID string) string {
+	if actual, ok := a.aliases[modelID]; ok {
+		return actual
+	}
+	return modelID
+}
+
+func (a *Adapter) SupportsStreaming() bool {
+	return true
+}
+
+func (a *Adapter) SupportsResponseFormat() bool {
+	return true
+}
+
+// SupportsParameter is true for every sampling parameter; the upstream is
+// left to reject the ones it does not know.
+func (a *Adapter) SupportsParameter(name string) bool {
+	return true
+}
+
+type chatRequest struct {
+	Model       string           `json:"model"`
+	Messages    []models.Message `json:"messages"`
+	Stream      bool             `json:"stream"`
+	Temperature *float64         `json:"temperature,omitempty"`
+	MaxTokens   *int             `json:"max_tokens,omitempty"`
+	TopP        *float64         `json:"top_p,omitempty"`
+
+	Stop             []string           `json:"stop,omitempty"`
+	N                *int               `json:"n,omitempty"`
+	Seed             *int               `json:"seed,omitempty"`
+	PresencePenalty  *float64           `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64           `json:"frequency_penalty,omitempty"`
+	LogitBias        map[string]float64 `json:"logit_bias,omitempty"`
+	Logprobs         *bool              `json:"logprobs,omitempty"`
+	TopLogprobs      *int               `json:"top_logprobs,omitempty"`
+	User             string             `json:"user,omitempty"`
+
+	ResponseFormat *models.ResponseFormat `json:"response_format,omitempty"`
+	StreamOptions  *models.StreamOptions  `json:"stream_options,omitempty"`
+}
+
+func newChatRequest(req *models.ChatRequest, stream bool) chatRequest {
+	r := chatRequest{
+		Model:       req.Model,
+		Messages:    req.Messages,
+		Stream:      stream,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+
+		Stop:             req.Stop,
+		N:                req.N,
+		Seed:             req.Seed,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		LogitBias:        req.LogitBias,
+		Logprobs:         req.Logprobs,
+		TopLogprobs:      req.TopLogprobs,
+		User:             req.User,
+
+		ResponseFormat: req.ResponseFormat,
+	}
+	if stream {
+		r.StreamOptions = req.StreamOptions
+	}
+	return r
+}
+
+func (a *Adapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.ChatResponse, error) {
+	body, _ := json.Marshal(newChatRequest(req, false))
+
+	// A request the upstream took in may already have been generated and
+	// billed, so only one it never received is retried.
+	var chatResp models.ChatResponse
+	err := a.retry.Do(ctx, func() error {
+		resp, err := a.send(ctx, a.httpClient, "POST", "/chat/completions", body)
+		if err != nil {
+			if adapters.Unsent(err) {
+				return err
+			}
+			return adapters.Permanent(err)
+		}
+		defer resp.Body.Close()
+		return adapters.Permanent(json.NewDecoder(resp.Body).Decode(&chatResp))
+	})
+	if err != nil {
+		return nil, adapters.Classify(fmt.Errorf("chat completion failed: %w", err))
+	}
+
+	if len(chatResp.Choices) > 0 && chatResp.Choices[0].FinishReason == "content_filter" {
+		return nil, adapters.NewError(adapters.ErrContentFiltered, "response blocked by the upstream content filter")
+	}
+
+	chatResp.Model = req.Model
+	return &chatResp, nil
+}
+
+func (a *Adapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamChunk, <-chan error) {
+	chunks := make(chan models.StreamChunk, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+
+		if err := a.stream(ctx, req, chunks); err != nil {
+			errs <- adapters.Classify(err)
+		}
+	}()
+
+	return chunks, errs
+}
+
+func (a *Adapter) stream(ctx context.Context, req *models.ChatRequest, chunks chan<- models.StreamChunk) error {
+	body, _ := json.Marshal(newChatRequest(req, true))
+
+	// The watchdog cancels the request when the upstream goes quiet for
+	// longer than the first-token or idle timeout.
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stalled atomic.Bool
+	watchdog := time.AfterFunc(a.firstTokenTimeout, func() {
+		stalled.Store(true)
+		cancel()
+	})
+	defer watchdog.Stop()
+
+	// As in Chat, only a request the upstream never received is retried.
+	var resp *http.Response
+	err := a.retry.Do(streamCtx, func() error {
+		var err error
+		resp, err = a.send(streamCtx, a.streamClient, "POST", "/chat/completions", body)
+		if err != nil && !adapters.Unsent(err) {
+			return adapters.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		if stalled.Load() {
+			return adapters.NewError(adapters.ErrBackendTimeout, "no response from upstream within %s", a.firstTokenTimeout)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		watchdog.Reset(a.idleTimeout)
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk models.StreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		chunk.Model = req.Model
+
+		select {
+		case chunks <- chunk:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if stalled.Load() {
+		return adapters.NewError(adapters.ErrBackendTimeout, "upstream stream stalled for more than %s", a.idleTimeout)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type embeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions *int     `json:"dimensions,omitempty"`
+	User       string   `json:"user,omitempty"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Usage models.EmbeddingUsage `json:"usage"`
+}
+
+func (a *Adapter) Embed(ctx context.Context, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	resp, err := adapters.EmbedBatches(ctx, req.Inputs, a.config.Embeddings.BatchSize, func(ctx context.Context, batch []string) ([][]float64, int, error) {
+		body, _ := json.Marshal(embeddingRequest{
+			Model:      req.Model,
+			Input:      batch,
+			Dimensions: req.Dimensions,
+			User:       req.User,
+		})
+
+		var embResp embeddingResponse
+		err := a.retry.Do(ctx, func() error {
+			resp, err := a.send(ctx, a.httpClient, "POST", "/embeddings", body)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return json.NewDecoder(resp.Body).Decode(&embResp)
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		vectors := make([][]float64, len(batch))
+		for _, d := range embResp.Data {
+			if d.Index >= 0 && d.Index < len(vectors) {
+				vectors[d.Index] = d.Embedding
+			}
+		}
+		return vectors, embResp.Usage.PromptTokens, nil
+	})
+	if err != nil {
+		return nil, adapters.Classify(fmt.Errorf("embedding failed: %w", err))
+	}
+
+	resp.Model = req.Model
+	return resp, nil
+}
+
+// send performs a request against the upstream API and returns the response
+// if it succeeded. Failed responses are read and returned as StatusError.
+func (a *Adapter) send(ctx context.Context, client *http.Client, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if a.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.config.APIKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &adapters.StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: adapters.RetryAfter(resp.Header)}
+	}
+
+	return resp, nil
+}
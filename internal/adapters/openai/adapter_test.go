@@ -0,0 +1,241 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// newTestAdapter points an adapter at handler. The health check and model
+// list requests are answered with empty lists.
+func newTestAdapter(t *testing.T, cfg config.BackendConfig, handler http.HandlerFunc) *Adapter {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/models" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg.BaseURL = srv.URL
+	a := New("test", cfg)
+	if err := a.Initialize(nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Shutdown() })
+	return a
+}
+
+func TestSendRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "seconds", header: "7", want: 7 * time.Second},
+		{name: "missing", header: "", want: 0},
+		{name: "not a number", header: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAdapter(t, config.BackendConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})
+
+			_, err := a.send(context.Background(), a.httpClient, "POST", "/anything", []byte(`{}`))
+			var statusErr *adapters.StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("err = %v, want a StatusError", err)
+			}
+			if statusErr.RetryAfter != tt.want {
+				t.Errorf("RetryAfter = %s, want %s", statusErr.RetryAfter, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewChatRequest(t *testing.T) {
+	temperature, n := 0.2, 2
+	req := &models.ChatRequest{
+		Model:         "gpt-4o",
+		Messages:      []models.Message{{Role: "user", Content: "hi"}},
+		Temperature:   &temperature,
+		N:             &n,
+		Stop:          []string{"END"},
+		User:          "alice",
+		StreamOptions: &models.StreamOptions{IncludeUsage: true},
+	}
+
+	tests := []struct {
+		name   string
+		stream bool
+		want   string
+	}{
+		{
+			name: "chat",
+			want: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"stream":false,"temperature":0.2,"stop":["END"],"n":2,"user":"alice"}`,
+		},
+		{
+			name:   "stream",
+			stream: true,
+			want:   `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"stream":true,"temperature":0.2,"stop":["END"],"n":2,"user":"alice","stream_options":{"include_usage":true}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(newChatRequest(req, tt.stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		retryAfter   string
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{name: "turned away", status: http.StatusServiceUnavailable, retryAfter: "1", wantAttempts: 2},
+		{name: "bad gateway", status: http.StatusBadGateway, wantAttempts: 1, wantErr: true},
+		{name: "unavailable without Retry-After", status: http.StatusServiceUnavailable, wantAttempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			a := newTestAdapter(t, config.BackendConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) == 1 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+			})
+
+			_, err := a.Chat(context.Background(), &models.ChatRequest{Model: "m"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	a := newTestAdapter(t, config.BackendConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`+"\n\n")
+		fmt.Fprint(w, "data: not json\n\n")
+		fmt.Fprint(w, `data:{"choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":"after done"}}]}`+"\n\n")
+	})
+
+	chunks, errs := a.ChatStream(context.Background(), &models.ChatRequest{Model: "alias"})
+	var text, finishReason string
+	var usage *models.Usage
+	for chunk := range chunks {
+		if chunk.Model != "alias" {
+			t.Errorf("chunk model = %q, want the requested alias", chunk.Model)
+		}
+		for _, choice := range chunk.Choices {
+			text += choice.Delta.Content
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if text != "Hello" || finishReason != "stop" {
+		t.Errorf("text = %q, finish reason = %q, want Hello and stop", text, finishReason)
+	}
+	if usage == nil || *usage != (models.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	var bodies []embeddingRequest
+	a := newTestAdapter(t, config.BackendConfig{Embeddings: config.EmbeddingsConfig{BatchSize: 2}}, func(w http.ResponseWriter, r *http.Request) {
+		var body embeddingRequest
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		bodies = append(bodies, body)
+
+		// The upstream may list embeddings out of order.
+		var items []string
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			items = append(items, fmt.Sprintf(`{"index":%d,"embedding":[%d]}`, i, len(body.Input[i])))
+		}
+		fmt.Fprintf(w, `{"data":[%s],"usage":{"prompt_tokens":%d}}`, strings.Join(items, ","), len(body.Input))
+	})
+
+	dimensions := 8
+	resp, err := a.Embed(context.Background(), &models.EmbeddingRequest{
+		Model:      "text-embedding-3-small",
+		Dimensions: &dimensions,
+		Inputs:     []string{"a", "bb", "ccc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bodies) != 2 || !reflect.DeepEqual(bodies[0].Input, []string{"a", "bb"}) || !reflect.DeepEqual(bodies[1].Input, []string{"ccc"}) {
+		t.Fatalf("batches = %+v, want [a bb] and [ccc]", bodies)
+	}
+	for _, body := range bodies {
+		if body.Dimensions == nil || *body.Dimensions != 8 {
+			t.Errorf("dimensions = %v, want 8 sent upstream", body.Dimensions)
+		}
+	}
+
+	for i, d := range resp.Data {
+		if v, ok := d.Embedding.([]float64); d.Index != i || !ok || len(v) != 1 || v[0] != float64(i+1) {
+			t.Errorf("data[%d] = %+v, want the embedding of input %d", i, d, i)
+		}
+	}
+	if resp.Model != "text-embedding-3-small" || resp.Usage != (models.EmbeddingUsage{PromptTokens: 3, TotalTokens: 3}) {
+		t.Errorf("response = %+v", resp)
+	}
+}
@@ -5,38 +5,64 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/audit"
 	"github.com/kashifkhan/ai-gateway/internal/config"
 	"github.com/kashifkhan/ai-gateway/internal/models"
 )
 
+const (
+	DefaultFirstTokenTimeout = 60 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+
+	// drainTimeout is how long a stream keeps reading events that were
+	// still in flight when OpenCode answered the prompt.
+	drainTimeout = 500 * time.Millisecond
+)
+
 type Adapter struct {
 	adapters.BaseAdapter
-	config     config.BackendConfig
-	httpClient *http.Client
-	baseURL    string
-	models     map[string]config.ModelConfig
-	aliases    map[string]string
+	id                string
+	config            config.BackendConfig
+	httpClient        *http.Client
+	streamClient      *http.Client
+	retry             adapters.RetryPolicy
+	firstTokenTimeout time.Duration
+	idleTimeout       time.Duration
+	baseURL           string
+	models            map[string]config.ModelConfig
+	aliases           map[string]string
+	auditLog          *audit.Logger
+
+	mu       sync.RWMutex
+	catalog  *catalog
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
-func New(cfg config.BackendConfig) *Adapter {
+func New(id string, cfg config.BackendConfig, auditLog *audit.Logger) *Adapter {
 	return &Adapter{
 		BaseAdapter: adapters.BaseAdapter{},
+		id:          id,
 		config:      cfg,
 		models:      make(map[string]config.ModelConfig),
 		aliases:     make(map[string]string),
+		auditLog:    auditLog,
+		stop:        make(chan struct{}),
 	}
 }
 
 func (a *Adapter) ID() string {
-	return "opencode"
+	return a.id
 }
 
 func (a *Adapter) Name() string {
@@ -49,6 +75,19 @@ func (a *Adapter) Initialize(cfg map[string]interface{}) error {
 	a.httpClient = &http.Client{
 		Timeout: a.config.Timeout,
 	}
+	// Streams are bounded by the first-token and idle timeouts instead of a
+	// total deadline, so long generations are not cut off.
+	a.streamClient = &http.Client{}
+	a.retry = adapters.NewRetryPolicy(a.config.Retry)
+
+	a.firstTokenTimeout = a.config.Streaming.FirstTokenTimeout
+	if a.firstTokenTimeout <= 0 {
+		a.firstTokenTimeout = DefaultFirstTokenTimeout
+	}
+	a.idleTimeout = a.config.Streaming.IdleTimeout
+	if a.idleTimeout <= 0 {
+		a.idleTimeout = DefaultIdleTimeout
+	}
 
 	for _, m := range a.config.Models {
 		a.models[m.ID] = m
@@ -57,17 +96,35 @@ func (a *Adapter) Initialize(cfg map[string]interface{}) error {
 		}
 	}
 
+	if !a.config.Discovery.Disabled {
+		interval := a.config.Discovery.Interval
+		if interval <= 0 {
+			interval = DefaultDiscoveryInterval
+		}
+		go a.refreshModels(interval)
+	}
+
 	if err := a.HealthCheck(); err != nil {
 		a.SetHealthy(false)
 		return nil
 	}
 
+	if !a.config.Discovery.Disabled {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := a.discover(ctx); err != nil {
+			log.Printf("Warning: OpenCode model discovery failed: %v", err)
+		}
+		cancel()
+	}
+
 	a.SetHealthy(true)
 	return nil
 }
 
 func (a *Adapter) Shutdown() error {
+	a.stopOnce.Do(func() { close(a.stop) })
 	a.httpClient.CloseIdleConnections()
+	a.streamClient.CloseIdleConnections()
 	return nil
 }
 
@@ -97,17 +154,39 @@ func (a *Adapter) HealthCheck() error {
 	return nil
 }
 
+// ListModels returns the configured models followed by any others that
+// OpenCode's providers offer. A discovered model is listed by its bare ID
+// unless another provider already claims it.
 func (a *Adapter) ListModels() ([]models.Model, error) {
+	c := a.currentCatalog()
+
 	result := make([]models.Model, 0, len(a.config.Models))
-	for _, m := range a.config.Models {
-		result = append(result, models.Model{
-			ID:      m.ID,
-			Object:  "model",
-			Created: time.Now().Unix(),
-			OwnedBy: "opencode",
-			Backend: "opencode",
-			Free:    m.Free,
-		})
+	for i := range a.config.Models {
+		m := &a.config.Models[i]
+		base := models.Model{
+			ID:           m.ID,
+			OwnedBy:      a.id,
+			Backend:      a.id,
+			Capabilities: &models.ModelCapabilities{Streaming: true},
+		}
+		if discovered, ok := c.lookup(m.ID); ok {
+			base = discovered.describe(a.id, m.ID)
+		}
+		result = append(result, adapters.DescribeModel(base, m))
+	}
+
+	if c == nil {
+		return result, nil
+	}
+	for _, m := range c.models {
+		id := m.ID
+		if owner, _ := c.lookup(m.ID); owner.ProviderID != m.ProviderID {
+			id = m.ProviderID + "/" + m.ID
+		}
+		if _, configured := a.models[id]; configured {
+			continue
+		}
+		result = append(result, adapters.DescribeModel(m.describe(a.id, id), nil))
 	}
 	return result, nil
 }
@@ -119,7 +198,8 @@ func (a *Adapter) SupportsModel(modelID string) bool {
 	if _, ok := a.aliases[modelID]; ok {
 		return true
 	}
-	return false
+	_, ok := a.currentCatalog().lookup(modelID)
+	return ok
 }
 
 func (a *Adapter) ResolveModel(modelID string) string {
@@ -136,9 +216,9 @@ func (a *Adapter) SupportsStreaming() bool {
 func (a *Adapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.ChatResponse, error) {
 	sessionID, err := a.createSession(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, adapters.Classify(fmt.Errorf("failed to create session: %w", err))
 	}
-	defer a.deleteSession(sessionID)
+	defer a.closeSession(ctx, sessionID)
 
 	// Build conversation context by replaying all messages
 	var systemPrompt string
@@ -154,7 +234,7 @@ func (a *Adapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.Ch
 	}
 
 	if len(conversationHistory) == 0 {
-		return nil, fmt.Errorf("no messages found")
+		return nil, adapters.NewError(adapters.ErrInvalidRequest, "no messages found")
 	}
 
 	// Send all messages except the last one to build context
@@ -162,7 +242,13 @@ func (a *Adapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.Ch
 		msg := conversationHistory[i]
 		if msg.Role == "user" {
 			// Send previous user messages without waiting for response
-			_, err := a.sendMessageNonStreaming(ctx, sessionID, msg.Content, req.Model, "")
+			_, err := a.sendMessageNonStreaming(ctx, sessionID, msg.Content, "", req)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if errors.Is(err, adapters.ErrToolNotAllowed) {
+				return nil, err
+			}
 			if err != nil {
 				log.Printf("Warning: failed to send context message: %v", err)
 			}
@@ -174,12 +260,27 @@ func (a *Adapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.Ch
 	// Send the final user message with system prompt
 	lastMsg := conversationHistory[len(conversationHistory)-1]
 	if lastMsg.Role != "user" {
-		return nil, fmt.Errorf("last message must be from user")
+		return nil, adapters.NewError(adapters.ErrInvalidRequest, "last message must be from user")
 	}
 
-	fullContent, err := a.sendMessageNonStreaming(ctx, sessionID, lastMsg.Content, req.Model, systemPrompt)
+	ocResp, err := a.sendMessageNonStreaming(ctx, sessionID, lastMsg.Content, systemPrompt, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %w", err)
+		return nil, adapters.Classify(fmt.Errorf("failed to send message: %w", err))
+	}
+
+	message := models.Message{
+		Role:    "assistant",
+		Content: ocResp.partText("text"),
+	}
+	if req.IncludeReasoning {
+		message.ReasoningContent = ocResp.partText("reasoning")
+	}
+	if req.IncludeToolEvents {
+		for i := range ocResp.Parts {
+			if ocResp.Parts[i].Type == "tool" {
+				message.ToolCalls = append(message.ToolCalls, ocResp.Parts[i].toolCall())
+			}
+		}
 	}
 
 	return &models.ChatResponse{
@@ -189,11 +290,8 @@ func (a *Adapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.Ch
 		Model:   req.Model,
 		Choices: []models.Choice{
 			{
-				Index: 0,
-				Message: models.Message{
-					Role:    "assistant",
-					Content: fullContent,
-				},
+				Index:        0,
+				Message:      message,
 				FinishReason: "stop",
 			},
 		},
@@ -210,10 +308,10 @@ func (a *Adapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-ch
 
 		sessionID, err := a.createSession(ctx)
 		if err != nil {
-			errs <- fmt.Errorf("failed to create session: %w", err)
+			errs <- adapters.Classify(fmt.Errorf("failed to create session: %w", err))
 			return
 		}
-		defer a.deleteSession(sessionID)
+		defer a.closeSession(ctx, sessionID)
 
 		// Build conversation context by replaying all messages
 		var systemPrompt string
@@ -229,7 +327,7 @@ func (a *Adapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-ch
 		}
 
 		if len(conversationHistory) == 0 {
-			errs <- fmt.Errorf("no messages found")
+			errs <- adapters.NewError(adapters.ErrInvalidRequest, "no messages found")
 			return
 		}
 
@@ -238,7 +336,15 @@ func (a *Adapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-ch
 			msg := conversationHistory[i]
 			if msg.Role == "user" {
 				// Send previous user messages without waiting for response
-				_, err := a.sendMessageNonStreaming(ctx, sessionID, msg.Content, req.Model, "")
+				_, err := a.sendMessageNonStreaming(ctx, sessionID, msg.Content, "", req)
+				if ctx.Err() != nil {
+					errs <- ctx.Err()
+					return
+				}
+				if errors.Is(err, adapters.ErrToolNotAllowed) {
+					errs <- err
+					return
+				}
 				if err != nil {
 					log.Printf("Warning: failed to send context message: %v", err)
 				}
@@ -248,13 +354,19 @@ func (a *Adapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-ch
 		// Send the final user message with system prompt (streaming)
 		lastMsg := conversationHistory[len(conversationHistory)-1]
 		if lastMsg.Role != "user" {
-			errs <- fmt.Errorf("last message must be from user")
+			errs <- adapters.NewError(adapters.ErrInvalidRequest, "last message must be from user")
 			return
 		}
 
-		err = a.sendMessageStreaming(ctx, sessionID, lastMsg.Content, req.Model, systemPrompt, chunks)
+		opts := streamOptions{
+			includeReasoning:  req.IncludeReasoning,
+			includeToolEvents: req.IncludeToolEvents,
+			toolPolicy:        req.ToolPolicy,
+			caller:            req.Caller,
+		}
+		err = a.sendMessageStreaming(ctx, sessionID, lastMsg.Content, req.Model, systemPrompt, opts, chunks)
 		if err != nil {
-			errs <- err
+			errs <- adapters.Classify(err)
 		}
 	}()
 
@@ -265,31 +377,74 @@ func (a *Adapter) createSession(ctx context.Context) (string, error) {
 	reqBody := map[string]interface{}{}
 	body, _ := json.Marshal(reqBody)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/session", bytes.NewReader(body))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
+	var sessionID string
+	err := a.retry.Do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/session", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
 
-	resp, err := a.httpClient.Do(req)
+		resp, err := a.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			return &adapters.StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+		}
+
+		var result struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return err
+		}
+
+		sessionID = result.ID
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to create session: %s", string(bodyBytes))
-	}
+	return sessionID, nil
+}
 
-	var result struct {
-		ID string `json:"id"`
+// closeSession tears the session down once a request is finished. If the
+// client went away first, OpenCode is told to stop generating before the
+// session is deleted.
+func (a *Adapter) closeSession(ctx context.Context, sessionID string) {
+	if ctx.Err() != nil {
+		a.abortSession(sessionID)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	a.deleteSession(sessionID)
+}
+
+func (a *Adapter) abortSession(sessionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/session/"+sessionID+"/abort", nil)
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		log.Printf("Warning: failed to abort session %s: %v", sessionID, err)
+		return
 	}
+	resp.Body.Close()
+}
 
-	return result.ID, nil
+// sendChunk delivers a chunk unless ctx is cancelled first, so a consumer
+// that stopped reading can never block the producer.
+func sendChunk(ctx context.Context, chunks chan<- models.StreamChunk, chunk models.StreamChunk) bool {
+	select {
+	case chunks <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 func (a *Adapter) deleteSession(sessionID string) {
@@ -305,34 +460,148 @@ func (a *Adapter) deleteSession(sessionID string) {
 
 type OpenCodeResponse struct {
 	Info struct {
-		ID string `json:"id"`
+		ID    string         `json:"id"`
+		Error *OpenCodeError `json:"error,omitempty"`
 	} `json:"info"`
-	Parts []struct {
-		Type string `json:"type"`
-		Text string `json:"text,omitempty"`
-	} `json:"parts"`
-	Error   interface{} `json:"error,omitempty"`
-	Success bool        `json:"success"`
+	Parts   []OpenCodePart `json:"parts"`
+	Error   interface{}    `json:"error,omitempty"`
+	Success bool           `json:"success"`
 }
 
-func parseModelID(modelID string) (providerID, model string) {
-	parts := strings.Split(modelID, "/")
-	if len(parts) == 2 {
-		return parts[0], parts[1]
+type OpenCodePart struct {
+	MessageID string `json:"messageID"`
+	SessionID string `json:"sessionID"`
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+
+	// Set on tool parts
+	CallID string            `json:"callID,omitempty"`
+	Tool   string            `json:"tool,omitempty"`
+	State  OpenCodeToolState `json:"state"`
+}
+
+type OpenCodeToolState struct {
+	Status string          `json:"status"`
+	Input  json.RawMessage `json:"input,omitempty"`
+	Output string          `json:"output,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Title  string          `json:"title,omitempty"`
+}
+
+// toolCall reports a tool part as a tool call the gateway already ran.
+func (p *OpenCodePart) toolCall() models.ToolCall {
+	args := "{}"
+	if len(p.State.Input) > 0 {
+		args = string(p.State.Input)
 	}
 
-	if strings.HasPrefix(modelID, "gemini-") || strings.HasPrefix(modelID, "gemini") {
-		return "google", modelID
+	return models.ToolCall{
+		ID:   p.CallID,
+		Type: "function",
+		Function: models.FunctionCall{
+			Name:      p.Tool,
+			Arguments: args,
+		},
+		GatewayExecuted: true,
+		Status:          p.State.Status,
+		Output:          p.State.Output,
+		Error:           p.State.Error,
 	}
+}
 
-	if strings.HasSuffix(modelID, "-free") {
-		return "opencode", modelID
+// toolCallDelta reports a tool part in a stream. The first delta of a call
+// carries its ID, name and arguments; later ones only what changed, since
+// clients concatenate the fields of deltas with the same index.
+func (p *OpenCodePart) toolCallDelta(index int, first bool) models.ToolCall {
+	call := models.ToolCall{
+		Index:           &index,
+		GatewayExecuted: true,
+		Status:          p.State.Status,
+		Output:          p.State.Output,
+		Error:           p.State.Error,
 	}
+	if first {
+		full := p.toolCall()
+		call.ID, call.Type, call.Function = full.ID, full.Type, full.Function
+	}
+	return call
+}
 
-	return "opencode", modelID
+// OpenCodeError is the named error OpenCode attaches to failed assistant
+// messages and session.error events.
+type OpenCodeError struct {
+	Name string `json:"name"`
+	Data struct {
+		Message    string `json:"message"`
+		StatusCode int    `json:"statusCode"`
+		ProviderID string `json:"providerID"`
+	} `json:"data"`
 }
 
-func (a *Adapter) sendMessageNonStreaming(ctx context.Context, sessionID, message, modelID, systemPrompt string) (string, error) {
+func (e *OpenCodeError) toError() error {
+	msg := e.Data.Message
+	if msg == "" {
+		msg = e.Name
+	}
+
+	switch e.Name {
+	case "ProviderAuthError":
+		return adapters.NewError(adapters.ErrUpstreamAuth, "opencode error: %s", msg)
+	case "ProviderModelNotFoundError", "ModelNotFoundError":
+		return adapters.NewError(adapters.ErrModelNotFound, "opencode error: %s", msg)
+	}
+
+	return adapters.Classify(fmt.Errorf("opencode error: %w", &adapters.UpstreamError{
+		StatusCode: e.Data.StatusCode,
+		Code:       e.Name,
+		Message:    msg,
+	}))
+}
+
+// partText joins the text of every part of the given type.
+func (r *OpenCodeResponse) partText(partType string) string {
+	var b strings.Builder
+	for _, part := range r.Parts {
+		if part.Type == partType && part.Text != "" {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
+
+func (r *OpenCodeResponse) err() error {
+	if r.Info.Error != nil {
+		return r.Info.Error.toError()
+	}
+	if r.Error != nil && !r.Success {
+		return adapters.Classify(fmt.Errorf("opencode error: %w", &adapters.UpstreamError{Message: fmt.Sprint(r.Error)}))
+	}
+	return nil
+}
+
+// builtinTools lists the tools OpenCode agents ship with, so that a policy can
+// switch off everything outside its allow-list.
+var builtinTools = []string{
+	"bash", "edit", "write", "read", "grep", "glob", "list", "patch",
+	"multiedit", "todowrite", "todoread", "webfetch", "websearch", "task",
+}
+
+// toolOverrides turns a policy into OpenCode's per-prompt tools map. Tools
+// that are not built in are still caught when their parts show up.
+func toolOverrides(policy *models.ToolPolicy) map[string]bool {
+	if policy == nil || (!policy.Disabled && policy.Allow == nil) {
+		return nil
+	}
+
+	tools := make(map[string]bool, len(builtinTools))
+	for _, tool := range builtinTools {
+		tools[tool] = policy.Allows(tool)
+	}
+	return tools
+}
+
+func (a *Adapter) buildMessageBody(message, modelID, systemPrompt string, tools map[string]bool) []byte {
 	reqBody := map[string]interface{}{
 		"parts": []map[string]interface{}{
 			{
@@ -343,7 +612,7 @@ func (a *Adapter) sendMessageNonStreaming(ctx context.Context, sessionID, messag
 	}
 
 	if modelID != "" {
-		providerID, model := parseModelID(modelID)
+		providerID, model := a.parseModelID(modelID)
 		reqBody["model"] = map[string]string{
 			"providerID": providerID,
 			"modelID":    model,
@@ -355,50 +624,116 @@ func (a *Adapter) sendMessageNonStreaming(ctx context.Context, sessionID, messag
 		reqBody["system"] = systemPrompt
 	}
 
+	if tools != nil {
+		reqBody["tools"] = tools
+	}
+
 	body, _ := json.Marshal(reqBody)
+	return body
+}
 
+// auditTool records a tool part and reports whether the policy allows it.
+func (a *Adapter) auditTool(sessionID, modelID, caller string, policy *models.ToolPolicy, part *OpenCodePart) bool {
+	allowed := policy.Allows(part.Tool)
+	a.auditLog.Record(audit.Event{
+		Backend: a.ID(),
+		Session: sessionID,
+		Caller:  caller,
+		Model:   modelID,
+		Tool:    part.Tool,
+		CallID:  part.CallID,
+		Status:  part.State.Status,
+		Allowed: allowed,
+		Input:   part.State.Input,
+	})
+	return allowed
+}
+
+// postMessage sends a prompt to a session. The prompt is only retried when
+// OpenCode never took it in, since a prompt that was accepted may already have
+// run, tools included.
+func (a *Adapter) postMessage(ctx context.Context, client *http.Client, sessionID string, body []byte) ([]byte, error) {
+	var respBody []byte
+	err := a.retry.Do(ctx, func() error {
+		var err error
+		respBody, err = a.doPostMessage(ctx, client, sessionID, body)
+		if err != nil && !adapters.Unsent(err) {
+			return adapters.Permanent(err)
+		}
+		return err
+	})
+	return respBody, err
+}
+
+func (a *Adapter) doPostMessage(ctx context.Context, client *http.Client, sessionID string, body []byte) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/session/"+sessionID+"/message", bytes.NewReader(body))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := a.httpClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, &adapters.StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: adapters.RetryAfter(resp.Header)}
+	}
+
+	return bodyBytes, nil
+}
+
+func (a *Adapter) sendMessageNonStreaming(ctx context.Context, sessionID, message, systemPrompt string, req *models.ChatRequest) (*OpenCodeResponse, error) {
+	body := a.buildMessageBody(message, req.Model, systemPrompt, toolOverrides(req.ToolPolicy))
+	bodyBytes, err := a.postMessage(ctx, a.httpClient, sessionID, body)
+	if err != nil {
+		return nil, err
 	}
 
 	var ocResp OpenCodeResponse
 	if err := json.Unmarshal(bodyBytes, &ocResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w (body: %s)", err, string(bodyBytes))
+		return nil, fmt.Errorf("failed to parse response: %w (body: %s)", err, string(bodyBytes))
 	}
 
-	if ocResp.Error != nil && !ocResp.Success {
-		return "", fmt.Errorf("opencode error: %v", ocResp.Error)
+	if err := ocResp.err(); err != nil {
+		return nil, err
 	}
 
-	var fullContent strings.Builder
-	for _, part := range ocResp.Parts {
-		if part.Type == "text" && part.Text != "" {
-			fullContent.WriteString(part.Text)
+	for i := range ocResp.Parts {
+		part := &ocResp.Parts[i]
+		if part.Type != "tool" {
+			continue
+		}
+		if !a.auditTool(sessionID, req.Model, req.Caller, req.ToolPolicy, part) {
+			return nil, adapters.NewError(adapters.ErrToolNotAllowed, "agent ran tool '%s', which is not allowed for this request", part.Tool)
 		}
 	}
 
-	return fullContent.String(), nil
+	return &ocResp, nil
+}
+
+// streamOptions carries the per-request switches for what, besides plain
+// text, is forwarded to the client.
+type streamOptions struct {
+	includeReasoning  bool
+	includeToolEvents bool
+	toolPolicy        *models.ToolPolicy
+	caller            string
 }
 
-func (a *Adapter) sendMessageStreaming(ctx context.Context, sessionID, message, modelID, systemPrompt string, chunks chan<- models.StreamChunk) error {
+func (a *Adapter) sendMessageStreaming(ctx context.Context, sessionID, message, modelID, systemPrompt string, opts streamOptions, chunks chan<- models.StreamChunk) error {
 	chunkID := fmt.Sprintf("chatcmpl-%s", sessionID)
 	created := time.Now().Unix()
 
 	// Send initial role chunk
-	chunks <- models.StreamChunk{
+	roleChunk := models.StreamChunk{
 		ID:      chunkID,
 		Object:  "chat.completion.chunk",
 		Created: created,
@@ -412,37 +747,64 @@ func (a *Adapter) sendMessageStreaming(ctx context.Context, sessionID, message,
 			},
 		},
 	}
+	if !sendChunk(ctx, chunks, roleChunk) {
+		return ctx.Err()
+	}
 
 	// Connect to SSE event stream
 	eventCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	eventReq, err := http.NewRequestWithContext(eventCtx, "GET", a.baseURL+"/event", nil)
-	if err != nil {
-		return fmt.Errorf("failed to create event request: %w", err)
-	}
-	eventReq.Header.Set("Accept", "text/event-stream")
+	var eventResp *http.Response
+	err := a.retry.Do(eventCtx, func() error {
+		eventReq, err := http.NewRequestWithContext(eventCtx, "GET", a.baseURL+"/event", nil)
+		if err != nil {
+			return err
+		}
+		eventReq.Header.Set("Accept", "text/event-stream")
 
-	eventResp, err := a.httpClient.Do(eventReq)
+		resp, err := a.streamClient.Do(eventReq)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return &adapters.StatusError{StatusCode: resp.StatusCode, Body: "event stream unavailable"}
+		}
+
+		eventResp = resp
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to event stream: %w", err)
 	}
 	defer eventResp.Body.Close()
 
-	if eventResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("event stream returned status %d", eventResp.StatusCode)
-	}
-
-	// Channel to receive message ID
-	messageIDChan := make(chan string, 1)
+	// Signalled on every part update of our assistant message, to drive
+	// the first-token and idle timeouts
+	activity := make(chan struct{}, 1)
 	errChan := make(chan error, 1)
 
-	// Start goroutine to listen for SSE events
+	// Start goroutine to listen for SSE events. It must have exited before
+	// we return, because the caller closes chunks right after.
+	listenerDone := make(chan struct{})
+	defer func() {
+		cancel()
+		<-listenerDone
+	}()
+
 	go func() {
+		defer close(listenerDone)
+
 		reader := io.Reader(eventResp.Body)
 		scanner := bufio.NewScanner(reader)
-		var currentMessageID string
-		var assistantMessageID string
+		// A turn with tool calls can span several assistant messages, one
+		// per step
+		assistantMessages := make(map[string]bool)
+		started := false
+		toolStatus := make(map[string]string)
+		toolIndex := make(map[string]int)
 
 		log.Printf("[STREAM DEBUG] Event listener started for session: %s", sessionID)
 
@@ -465,19 +827,18 @@ func (a *Adapter) sendMessageStreaming(ctx context.Context, sessionID, message,
 						ID        string `json:"id"`
 						SessionID string `json:"sessionID"`
 						Role      string `json:"role"`
+						Finish    string `json:"finish"`
+						Time      struct {
+							Completed int64 `json:"completed"`
+						} `json:"time"`
 					} `json:"info"`
-					Part struct {
-						MessageID string `json:"messageID"`
-						SessionID string `json:"sessionID"`
-						Type      string `json:"type"`
-						Text      string `json:"text"`
-						Reason    string `json:"reason"`
-					} `json:"part"`
-					Delta     string `json:"delta"`
-					SessionID string `json:"sessionID"`
+					Part      OpenCodePart `json:"part"`
+					Delta     string       `json:"delta"`
+					SessionID string       `json:"sessionID"`
 					Status    struct {
 						Type string `json:"type"`
 					} `json:"status"`
+					Error *OpenCodeError `json:"error"`
 				} `json:"properties"`
 			}
 
@@ -488,31 +849,77 @@ func (a *Adapter) sendMessageStreaming(ctx context.Context, sessionID, message,
 
 			log.Printf("[STREAM DEBUG] Event type=%s sessionID=%s", event.Type, event.Properties.Info.SessionID)
 
-			// Capture assistant message ID from message.updated event
+			// Capture assistant message IDs from message.updated events
 			if event.Type == "message.updated" && event.Properties.Info.SessionID == sessionID && event.Properties.Info.Role == "assistant" {
-				if assistantMessageID == "" {
-					assistantMessageID = event.Properties.Info.ID
-					log.Printf("[STREAM DEBUG] Found assistant message ID: %s", assistantMessageID)
+				info := &event.Properties.Info
+				if !assistantMessages[info.ID] {
+					assistantMessages[info.ID] = true
+					log.Printf("[STREAM DEBUG] Found assistant message ID: %s", info.ID)
+				}
+
+				// A message that ends in tool calls is followed by another
+				// step; any other completed message ends the turn
+				if info.Time.Completed != 0 && info.Finish != "tool-calls" {
+					log.Printf("[STREAM DEBUG] Stream completed (message %s finished: %s)", info.ID, info.Finish)
+					cancel()
+					return
 				}
 			}
 
-			// Track message ID from first part event
 			if event.Type == "message.part.updated" && event.Properties.Part.SessionID == sessionID {
 				log.Printf("[STREAM DEBUG] message.part.updated: messageID=%s type=%s deltaLen=%d",
 					event.Properties.Part.MessageID, event.Properties.Part.Type, len(event.Properties.Delta))
 
-				if currentMessageID == "" && assistantMessageID != "" && event.Properties.Part.MessageID == assistantMessageID {
-					currentMessageID = event.Properties.Part.MessageID
-					log.Printf("[STREAM DEBUG] Starting stream for message: %s", currentMessageID)
-					messageIDChan <- currentMessageID
-				}
+				// Only process events for our assistant messages
+				if assistantMessages[event.Properties.Part.MessageID] {
+					if !started {
+						started = true
+						log.Printf("[STREAM DEBUG] Starting stream for message: %s", event.Properties.Part.MessageID)
+					}
+
+					select {
+					case activity <- struct{}{}:
+					default:
+					}
+
+					// Send deltas for text parts, and for reasoning parts when asked
+					var delta models.Delta
+					switch event.Properties.Part.Type {
+					case "text":
+						delta.Content = event.Properties.Delta
+					case "reasoning":
+						if opts.includeReasoning {
+							delta.ReasoningContent = event.Properties.Delta
+						}
+					case "tool":
+						// Tool parts are resent whole on every update; only
+						// act on status transitions
+						part := &event.Properties.Part
+						if toolStatus[part.CallID] == part.State.Status {
+							break
+						}
+						toolStatus[part.CallID] = part.State.Status
+
+						if !a.auditTool(sessionID, modelID, opts.caller, opts.toolPolicy, part) {
+							log.Printf("Warning: aborting session %s, tool '%s' is not allowed", sessionID, part.Tool)
+							a.abortSession(sessionID)
+							errChan <- adapters.NewError(adapters.ErrToolNotAllowed, "agent tried to run tool '%s', which is not allowed for this request", part.Tool)
+							return
+						}
+						// A call is reported once its input is known
+						if opts.includeToolEvents && part.State.Status != "pending" {
+							index, seen := toolIndex[part.CallID]
+							if !seen {
+								index = len(toolIndex)
+								toolIndex[part.CallID] = index
+							}
+							delta.ToolCalls = []models.ToolCall{part.toolCallDelta(index, !seen)}
+						}
+					}
 
-				// Only process events for our assistant message
-				if event.Properties.Part.MessageID == currentMessageID {
-					// Send delta for text parts only
-					if event.Properties.Part.Type == "text" && event.Properties.Delta != "" {
-						log.Printf("[STREAM DEBUG] Sending delta: %q", event.Properties.Delta)
-						chunks <- models.StreamChunk{
+					if delta.Content != "" || delta.ReasoningContent != "" || len(delta.ToolCalls) > 0 {
+						log.Printf("[STREAM DEBUG] Sending %s delta: len=%d", event.Properties.Part.Type, len(event.Properties.Delta))
+						deltaChunk := models.StreamChunk{
 							ID:      chunkID,
 							Object:  "chat.completion.chunk",
 							Created: created,
@@ -520,25 +927,25 @@ func (a *Adapter) sendMessageStreaming(ctx context.Context, sessionID, message,
 							Choices: []models.ChunkChoice{
 								{
 									Index: 0,
-									Delta: models.Delta{
-										Content: event.Properties.Delta,
-									},
+									Delta: delta,
 								},
 							},
 						}
-					}
-
-					// Check for step-finish (completion)
-					if event.Properties.Part.Type == "step-finish" {
-						log.Printf("[STREAM DEBUG] Stream completed (step-finish)")
-						cancel()
-						return
+						if !sendChunk(eventCtx, chunks, deltaChunk) {
+							return
+						}
 					}
 				}
 			}
 
+			if event.Type == "session.error" && event.Properties.SessionID == sessionID && event.Properties.Error != nil {
+				log.Printf("[STREAM DEBUG] Session error: %s", event.Properties.Error.Name)
+				errChan <- event.Properties.Error.toError()
+				return
+			}
+
 			// Also check for session.idle as backup completion signal
-			if event.Type == "session.idle" && event.Properties.SessionID == sessionID && currentMessageID != "" {
+			if event.Type == "session.idle" && event.Properties.SessionID == sessionID && started {
 				log.Printf("[STREAM DEBUG] Stream completed (session.idle)")
 				cancel()
 				return
@@ -552,65 +959,84 @@ func (a *Adapter) sendMessageStreaming(ctx context.Context, sessionID, message,
 		log.Printf("[STREAM DEBUG] Event listener ended")
 	}()
 
-	// Send the message
-	reqBody := map[string]interface{}{
-		"parts": []map[string]interface{}{
-			{
-				"type": "text",
-				"text": message,
-			},
-		},
-	}
+	// Send the message. OpenCode only answers once generation is over, so
+	// the request runs alongside the event listener.
+	postCtx, cancelPost := context.WithCancel(ctx)
+	defer cancelPost()
 
-	if modelID != "" {
-		providerID, model := parseModelID(modelID)
-		reqBody["model"] = map[string]string{
-			"providerID": providerID,
-			"modelID":    model,
+	postErr := make(chan error, 1)
+	go func() {
+		body := a.buildMessageBody(message, modelID, systemPrompt, toolOverrides(opts.toolPolicy))
+		respBody, err := a.postMessage(postCtx, a.streamClient, sessionID, body)
+		if err != nil {
+			postErr <- fmt.Errorf("failed to send message: %w", err)
+			return
 		}
-	}
 
-	// Add system prompt if provided
-	if systemPrompt != "" {
-		reqBody["system"] = systemPrompt
-	}
-
-	body, _ := json.Marshal(reqBody)
-
-	msgReq, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/session/"+sessionID+"/message", bytes.NewReader(body))
-	if err != nil {
-		cancel()
-		return fmt.Errorf("failed to create message request: %w", err)
-	}
-	msgReq.Header.Set("Content-Type", "application/json")
+		var ocResp OpenCodeResponse
+		if json.Unmarshal(respBody, &ocResp) == nil {
+			postErr <- ocResp.err()
+			return
+		}
+		postErr <- nil
+	}()
 
-	msgResp, err := a.httpClient.Do(msgReq)
-	if err != nil {
-		cancel()
-		return fmt.Errorf("failed to send message: %w", err)
+	// Wait for events to complete, bounded by the time to the first part and
+	// the time between parts. Once OpenCode has answered the prompt the
+	// generation is over, so only the events still in flight are awaited.
+	timeout := time.NewTimer(a.firstTokenTimeout)
+	defer timeout.Stop()
+	resetTimeout := func(d time.Duration) {
+		if !timeout.Stop() {
+			select {
+			case <-timeout.C:
+			default:
+			}
+		}
+		timeout.Reset(d)
 	}
-	msgResp.Body.Close()
+	gotFirst := false
+	answered := false
 
-	// Wait for events to complete or timeout
-	select {
-	case <-messageIDChan:
-		// Message started, wait for completion
+wait:
+	for {
 		select {
 		case err := <-errChan:
 			return err
+		case err := <-postErr:
+			if err != nil {
+				return err
+			}
+			postErr = nil
+			answered = true
+			resetTimeout(drainTimeout)
+		case <-activity:
+			gotFirst = true
+			if answered {
+				resetTimeout(drainTimeout)
+			} else {
+				resetTimeout(a.idleTimeout)
+			}
 		case <-eventCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			// Stream completed
-		case <-time.After(120 * time.Second):
-			cancel()
-			return fmt.Errorf("streaming timeout")
+			break wait
+		case <-timeout.C:
+			if answered {
+				break wait
+			}
+			a.abortSession(sessionID)
+			if !gotFirst {
+				return adapters.NewError(adapters.ErrBackendTimeout, "timeout waiting for message to start")
+			}
+			return adapters.NewError(adapters.ErrBackendTimeout, "stream idle for more than %s", a.idleTimeout)
 		}
-	case <-time.After(30 * time.Second):
-		cancel()
-		return fmt.Errorf("timeout waiting for message to start")
 	}
 
 	// Send final chunk
-	chunks <- models.StreamChunk{
+	finalChunk := models.StreamChunk{
 		ID:      chunkID,
 		Object:  "chat.completion.chunk",
 		Created: created,
@@ -623,6 +1049,9 @@ func (a *Adapter) sendMessageStreaming(ctx context.Context, sessionID, message,
 			},
 		},
 	}
+	if !sendChunk(ctx, chunks, finalChunk) {
+		return ctx.Err()
+	}
 
 	return nil
 }
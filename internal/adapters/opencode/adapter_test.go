@@ -0,0 +1,431 @@
+package opencode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// fakeOpenCode serves the parts of the OpenCode API that the adapter uses.
+// The event stream sends the given number of text deltas, then events, and
+// stays open until the client goes away. Prompts are answered with answer,
+// or never when it is empty.
+type fakeOpenCode struct {
+	deltas int
+	events []string
+	answer string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeOpenCode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.calls = append(f.calls, r.Method+" "+r.URL.Path)
+	f.mu.Unlock()
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/session":
+		fmt.Fprint(w, `{"id":"s1"}`)
+	case r.URL.Path == "/event":
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"type\":\"message.updated\",\"properties\":{\"info\":{\"id\":\"m1\",\"sessionID\":\"s1\",\"role\":\"assistant\"}}}\n\n")
+		for i := 0; i < f.deltas; i++ {
+			fmt.Fprintf(w, "data: %s\n\n", partEvent("m1", "text", fmt.Sprintf("token %d ", i)))
+		}
+		for _, event := range f.events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	case r.Method == "POST" && r.URL.Path == "/session/s1/message":
+		// The server only notices a client that went away once the body
+		// has been read.
+		io.Copy(io.Discard, r.Body)
+		if f.answer != "" {
+			fmt.Fprint(w, f.answer)
+			return
+		}
+		<-r.Context().Done()
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (f *fakeOpenCode) called(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+// partEvent is a message.part.updated event for a part of an assistant
+// message.
+func partEvent(messageID, partType, delta string) string {
+	return fmt.Sprintf(`{"type":"message.part.updated","properties":{"part":{"messageID":%q,"sessionID":"s1","type":%q},"delta":%q}}`, messageID, partType, delta)
+}
+
+// messageEvent is a message.updated event for an assistant message, which
+// has completed when finish is not empty.
+func messageEvent(messageID, finish string) string {
+	if finish == "" {
+		return fmt.Sprintf(`{"type":"message.updated","properties":{"info":{"id":%q,"sessionID":"s1","role":"assistant"}}}`, messageID)
+	}
+	return fmt.Sprintf(`{"type":"message.updated","properties":{"info":{"id":%q,"sessionID":"s1","role":"assistant","finish":%q,"time":{"completed":1}}}}`, messageID, finish)
+}
+
+// toolEvent is a message.part.updated event for a tool part of message m1.
+func toolEvent(status string) string {
+	return fmt.Sprintf(`{"type":"message.part.updated","properties":{"part":{"messageID":"m1","sessionID":"s1","type":"tool","callID":"c1","tool":"read","state":{"status":%q,"input":{"path":"go.mod"},"output":"module x"}}}}`, status)
+}
+
+const idleEvent = `{"type":"session.idle","properties":{"sessionID":"s1"}}`
+
+func newTestAdapter(t *testing.T, handler http.Handler) *Adapter {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	a := New("opencode", config.BackendConfig{
+		Host:      host,
+		Port:      portNum,
+		Discovery: config.DiscoveryConfig{Disabled: true},
+	}, nil)
+	if err := a.Initialize(nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Shutdown() })
+	return a
+}
+
+// adapterGoroutines lists the stacks of goroutines running adapter code.
+func adapterGoroutines() []string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	var leaked []string
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(g, "adapters/opencode.(*Adapter)") {
+			leaked = append(leaked, g)
+		}
+	}
+	return leaked
+}
+
+func TestChatStreamCancelDoesNotLeak(t *testing.T) {
+	tests := []struct {
+		name string
+		// deltas the upstream sends; more than the chunk buffer holds
+		// blocks the adapter on a consumer that stopped reading.
+		deltas int
+		// read is how many chunks the client reads before it goes away.
+		read int
+	}{
+		{name: "before the first part", deltas: 0, read: 0},
+		{name: "mid stream", deltas: 10, read: 3},
+		{name: "consumer stopped reading", deltas: 500, read: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeOpenCode{deltas: tt.deltas}
+			a := newTestAdapter(t, upstream)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			req := &models.ChatRequest{Model: "m", Messages: []models.Message{{Role: "user", Content: "hi"}}}
+			chunks, errs := a.ChatStream(ctx, req)
+
+			for i := 0; i < tt.read; i++ {
+				select {
+				case <-chunks:
+				case <-time.After(2 * time.Second):
+					t.Fatalf("chunk %d never arrived", i)
+				}
+			}
+			// Give the adapter time to fill the chunk buffer.
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+
+			// The client reads nothing more, so the adapter has to give up
+			// on its own.
+			if !eventually(func() bool { return len(adapterGoroutines()) == 0 }) {
+				leaked := adapterGoroutines()
+				go func() {
+					for range chunks {
+					}
+				}()
+				t.Fatalf("adapter goroutines still running after cancel:\n%s", strings.Join(leaked, "\n\n"))
+			}
+			for range chunks {
+			}
+			for range errs {
+			}
+
+			for _, call := range []string{"POST /session/s1/abort", "DELETE /session/s1"} {
+				if !eventually(func() bool { return upstream.called(call) }) {
+					t.Errorf("%s not called after cancel", call)
+				}
+			}
+		})
+	}
+}
+
+// eventually polls cond for up to two seconds.
+func eventually(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+func TestChatStreamTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		deltas  int
+		wantErr string
+	}{
+		{name: "no first token", deltas: 0, wantErr: "timeout waiting for message to start"},
+		{name: "idle after tokens", deltas: 2, wantErr: "stream idle for more than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeOpenCode{deltas: tt.deltas}
+			a := newTestAdapter(t, upstream)
+			a.firstTokenTimeout = 100 * time.Millisecond
+			a.idleTimeout = 100 * time.Millisecond
+
+			req := &models.ChatRequest{Model: "m", Messages: []models.Message{{Role: "user", Content: "hi"}}}
+			chunks, errs := a.ChatStream(context.Background(), req)
+
+			got := 0
+			for chunk := range chunks {
+				if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+					got++
+				}
+			}
+			err := <-errs
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			if got != tt.deltas {
+				t.Errorf("got %d deltas before the timeout, want %d", got, tt.deltas)
+			}
+			if !eventually(func() bool { return upstream.called("POST /session/s1/abort") }) {
+				t.Error("session not aborted after the timeout")
+			}
+		})
+	}
+}
+
+func TestChatReasoning(t *testing.T) {
+	answer := `{"info":{"id":"m1"},"parts":[` +
+		`{"type":"reasoning","text":"thinking"},` +
+		`{"type":"text","text":"answer"}]}`
+
+	tests := []struct {
+		name          string
+		include       bool
+		wantReasoning string
+	}{
+		{name: "not asked for", include: false, wantReasoning: ""},
+		{name: "asked for", include: true, wantReasoning: "thinking"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeOpenCode{
+				events: []string{partEvent("m1", "reasoning", "thinking"), partEvent("m1", "text", "answer"), idleEvent},
+				answer: answer,
+			}
+			a := newTestAdapter(t, upstream)
+			req := &models.ChatRequest{
+				Model:            "m",
+				Messages:         []models.Message{{Role: "user", Content: "hi"}},
+				IncludeReasoning: tt.include,
+			}
+
+			resp, err := a.Chat(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := resp.Choices[0].Message
+			if msg.Content != "answer" || msg.ReasoningContent != tt.wantReasoning {
+				t.Errorf("message = %q with reasoning %q, want %q with reasoning %q", msg.Content, msg.ReasoningContent, "answer", tt.wantReasoning)
+			}
+
+			chunks, errs := a.ChatStream(context.Background(), req)
+			var content, reasoning strings.Builder
+			for chunk := range chunks {
+				for _, choice := range chunk.Choices {
+					content.WriteString(choice.Delta.Content)
+					reasoning.WriteString(choice.Delta.ReasoningContent)
+				}
+			}
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+			if content.String() != "answer" || reasoning.String() != tt.wantReasoning {
+				t.Errorf("streamed %q with reasoning %q, want %q with reasoning %q", content.String(), reasoning.String(), "answer", tt.wantReasoning)
+			}
+		})
+	}
+}
+
+func TestChatStreamMultiStep(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+	}{
+		{
+			name: "steps in one message",
+			events: []string{
+				partEvent("m1", "step-start", ""),
+				partEvent("m1", "text", "Let me look. "),
+				toolEvent("running"),
+				toolEvent("completed"),
+				partEvent("m1", "step-finish", ""),
+				partEvent("m1", "step-start", ""),
+				partEvent("m1", "text", "Found it."),
+				partEvent("m1", "step-finish", ""),
+				idleEvent,
+			},
+		},
+		{
+			name: "a message per step",
+			events: []string{
+				partEvent("m1", "step-start", ""),
+				partEvent("m1", "text", "Let me look. "),
+				toolEvent("running"),
+				toolEvent("completed"),
+				partEvent("m1", "step-finish", ""),
+				messageEvent("m1", "tool-calls"),
+				messageEvent("m2", ""),
+				partEvent("m2", "step-start", ""),
+				partEvent("m2", "text", "Found it."),
+				partEvent("m2", "step-finish", ""),
+				messageEvent("m2", "stop"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The prompt is never answered, so only the events can end the
+			// stream before the idle timeout.
+			upstream := &fakeOpenCode{events: tt.events}
+			a := newTestAdapter(t, upstream)
+			a.idleTimeout = time.Second
+
+			req := &models.ChatRequest{
+				Model:             "m",
+				Messages:          []models.Message{{Role: "user", Content: "hi"}},
+				IncludeToolEvents: true,
+			}
+			start := time.Now()
+			chunks, errs := a.ChatStream(context.Background(), req)
+
+			var content strings.Builder
+			var toolStatus []string
+			finishReason := ""
+			for chunk := range chunks {
+				for _, choice := range chunk.Choices {
+					content.WriteString(choice.Delta.Content)
+					for _, call := range choice.Delta.ToolCalls {
+						toolStatus = append(toolStatus, call.Status)
+					}
+					if choice.FinishReason != "" {
+						finishReason = choice.FinishReason
+					}
+				}
+			}
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+
+			if got := content.String(); got != "Let me look. Found it." {
+				t.Errorf("content = %q, want both steps", got)
+			}
+			if got := strings.Join(toolStatus, ","); got != "running,completed" {
+				t.Errorf("tool statuses = %s, want running,completed", got)
+			}
+			if finishReason != "stop" {
+				t.Errorf("finish reason = %q, want stop", finishReason)
+			}
+			if waited := time.Since(start); waited >= a.idleTimeout {
+				t.Errorf("stream took %s to end, want the events to end it", waited)
+			}
+		})
+	}
+}
+
+func TestToolPolicyEnforced(t *testing.T) {
+	answer := `{"info":{"id":"m1"},"parts":[` +
+		`{"type":"tool","callID":"c1","tool":"read","state":{"status":"completed"}},` +
+		`{"type":"text","text":"answer"}]}`
+
+	tests := []struct {
+		name    string
+		policy  *models.ToolPolicy
+		wantErr bool
+	}{
+		{name: "allowed", policy: &models.ToolPolicy{Allow: []string{"read"}}, wantErr: false},
+		{name: "not in the allow list", policy: &models.ToolPolicy{Allow: []string{"grep"}}, wantErr: true},
+		{name: "tools disabled", policy: &models.ToolPolicy{Disabled: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeOpenCode{
+				events: []string{toolEvent("running"), partEvent("m1", "text", "answer"), idleEvent},
+				answer: answer,
+			}
+			a := newTestAdapter(t, upstream)
+			req := &models.ChatRequest{
+				Model:      "m",
+				Messages:   []models.Message{{Role: "user", Content: "hi"}},
+				ToolPolicy: tt.policy,
+			}
+
+			_, err := a.Chat(context.Background(), req)
+			if got := errors.Is(err, adapters.ErrToolNotAllowed); got != tt.wantErr {
+				t.Errorf("Chat err = %v, want tool not allowed: %v", err, tt.wantErr)
+			}
+
+			chunks, errs := a.ChatStream(context.Background(), req)
+			for range chunks {
+			}
+			err = <-errs
+			if got := errors.Is(err, adapters.ErrToolNotAllowed); got != tt.wantErr {
+				t.Errorf("ChatStream err = %v, want tool not allowed: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !upstream.called("POST /session/s1/abort") {
+				t.Error("session not aborted after a disallowed tool ran")
+			}
+		})
+	}
+}
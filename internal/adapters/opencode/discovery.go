@@ -0,0 +1,193 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const DefaultDiscoveryInterval = 5 * time.Minute
+
+// providersResponse is the body of OpenCode's GET /config/providers: the
+// providers the server is configured for, keyed models included.
+type providersResponse struct {
+	Providers []struct {
+		ID     string                   `json:"id"`
+		Name   string                   `json:"name"`
+		Models map[string]providerModel `json:"models"`
+	} `json:"providers"`
+	Default map[string]string `json:"default"`
+}
+
+type providerModel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ReleaseDate string `json:"release_date"`
+	Attachment  bool   `json:"attachment"`
+	Reasoning   bool   `json:"reasoning"`
+	ToolCall    bool   `json:"tool_call"`
+	Cost        *struct {
+		Input  float64 `json:"input"`
+		Output float64 `json:"output"`
+	} `json:"cost"`
+	Limit struct {
+		Context int `json:"context"`
+		Output  int `json:"output"`
+	} `json:"limit"`
+}
+
+// catalogModel is a model that OpenCode reported, with the provider that
+// serves it.
+type catalogModel struct {
+	ProviderID string
+	ID         string
+	Name       string
+	Info       providerModel
+}
+
+// describe returns what OpenCode reported about the model as a /v1/models
+// entry of the given backend. Costs are per million tokens, like
+// models.ModelPricing.
+func (m catalogModel) describe(backend, id string) models.Model {
+	model := models.Model{
+		ID:              id,
+		OwnedBy:         m.ProviderID,
+		Backend:         backend,
+		ContextWindow:   m.Info.Limit.Context,
+		MaxOutputTokens: m.Info.Limit.Output,
+		Capabilities: &models.ModelCapabilities{
+			Tools:     m.Info.ToolCall,
+			Vision:    m.Info.Attachment,
+			Streaming: true,
+			Reasoning: m.Info.Reasoning,
+		},
+	}
+	if released, err := time.Parse("2006-01-02", m.Info.ReleaseDate); err == nil {
+		model.Created = released.Unix()
+	}
+	if m.Info.Cost != nil {
+		model.Pricing = &models.ModelPricing{Input: m.Info.Cost.Input, Output: m.Info.Cost.Output}
+	}
+	return model
+}
+
+// catalog indexes discovered models by bare ID and by "provider/model". A
+// bare ID offered by several providers maps to the first provider by ID.
+type catalog struct {
+	models []catalogModel
+	byID   map[string]catalogModel
+}
+
+func newCatalog(resp *providersResponse) *catalog {
+	c := &catalog{byID: make(map[string]catalogModel)}
+
+	providers := resp.Providers
+	sort.Slice(providers, func(i, j int) bool { return providers[i].ID < providers[j].ID })
+
+	for _, p := range providers {
+		ids := make([]string, 0, len(p.Models))
+		for id := range p.Models {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			m := catalogModel{ProviderID: p.ID, ID: id, Name: p.Models[id].Name, Info: p.Models[id]}
+			c.models = append(c.models, m)
+			c.byID[p.ID+"/"+id] = m
+			if _, taken := c.byID[id]; !taken {
+				c.byID[id] = m
+			}
+		}
+	}
+	return c
+}
+
+func (c *catalog) lookup(modelID string) (catalogModel, bool) {
+	if c == nil {
+		return catalogModel{}, false
+	}
+	m, ok := c.byID[modelID]
+	return m, ok
+}
+
+// discover fetches the provider catalog from OpenCode and installs it.
+func (a *Adapter) discover(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", a.baseURL+"/config/providers", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider discovery failed: status %d", resp.StatusCode)
+	}
+
+	var providers providersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&providers); err != nil {
+		return fmt.Errorf("failed to decode providers: %w", err)
+	}
+
+	c := newCatalog(&providers)
+	a.mu.Lock()
+	a.catalog = c
+	a.mu.Unlock()
+	return nil
+}
+
+// refreshModels re-runs discovery every interval until the adapter shuts
+// down. A failed refresh keeps the previous catalog.
+func (a *Adapter) refreshModels(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := a.discover(ctx); err != nil {
+				log.Printf("Warning: OpenCode model discovery failed: %v", err)
+			}
+			cancel()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+func (a *Adapter) currentCatalog() *catalog {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.catalog
+}
+
+// parseModelID finds the OpenCode provider for a model. Explicit
+// "provider/model" IDs are taken as given; otherwise the discovered catalog
+// decides, with a guess from the model name when discovery has not run.
+func (a *Adapter) parseModelID(modelID string) (providerID, model string) {
+	if m, ok := a.currentCatalog().lookup(modelID); ok {
+		return m.ProviderID, m.ID
+	}
+
+	if provider, model, ok := strings.Cut(modelID, "/"); ok {
+		return provider, model
+	}
+
+	if strings.HasPrefix(modelID, "gemini") {
+		return "google", modelID
+	}
+
+	return "opencode", modelID
+}
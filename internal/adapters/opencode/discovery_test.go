@@ -0,0 +1,167 @@
+package opencode
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const providersBody = `{
+	"providers": [
+		{"id": "opencode", "models": {
+			"big-pickle": {"id": "big-pickle", "name": "Big Pickle", "limit": {"context": 200000, "output": 32000}},
+			"claude-x": {"id": "claude-x", "name": "Claude X"}
+		}},
+		{"id": "anthropic", "models": {
+			"claude-x": {"id": "claude-x", "name": "Claude X", "release_date": "2025-05-22", "tool_call": true,
+				"cost": {"input": 3, "output": 15}, "limit": {"context": 200000, "output": 64000}}
+		}}
+	]
+}`
+
+// fakeProviders serves status and body on GET /config/providers, and an
+// empty session list for the health check.
+type fakeProviders struct {
+	mu     sync.Mutex
+	status int
+	body   string
+}
+
+func (f *fakeProviders) set(status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.body = status, body
+}
+
+func (f *fakeProviders) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/session" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+		return
+	}
+	w.WriteHeader(f.status)
+	w.Write([]byte(f.body))
+}
+
+func newDiscoveringAdapter(t *testing.T, upstream http.Handler, interval time.Duration, configured ...config.ModelConfig) *Adapter {
+	t.Helper()
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	a := New("opencode", config.BackendConfig{
+		Host:      host,
+		Port:      portNum,
+		Models:    configured,
+		Discovery: config.DiscoveryConfig{Interval: interval},
+	}, nil)
+	if err := a.Initialize(nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Shutdown() })
+	return a
+}
+
+func TestDiscoveredModels(t *testing.T) {
+	upstream := &fakeProviders{status: 200, body: providersBody}
+	a := newDiscoveringAdapter(t, upstream, time.Hour, config.ModelConfig{ID: "big-pickle", Aliases: []string{"bp"}, ContextWindow: 100000})
+
+	list, err := a.ListModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]models.Model)
+	for _, m := range list {
+		byID[m.ID] = m
+	}
+	if len(list) != 3 || len(byID) != 3 {
+		t.Fatalf("models = %+v, want big-pickle, claude-x and opencode/claude-x", list)
+	}
+
+	// Configured fields win over discovered ones.
+	if m := byID["big-pickle"]; m.OwnedBy != "opencode" || m.ContextWindow != 100000 || m.MaxOutputTokens != 32000 || len(m.Aliases) != 1 {
+		t.Errorf("big-pickle = %+v", m)
+	}
+	// A bare ID goes to the first provider by ID; the others keep theirs.
+	claude := byID["claude-x"]
+	if claude.OwnedBy != "anthropic" || claude.Pricing == nil || *claude.Pricing != (models.ModelPricing{Input: 3, Output: 15}) {
+		t.Errorf("claude-x = %+v, want anthropic's with its pricing", claude)
+	}
+	if claude.Capabilities == nil || !claude.Capabilities.Tools || claude.Created != time.Date(2025, 5, 22, 0, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("claude-x = %+v, want tools and the release date", claude)
+	}
+	if m := byID["opencode/claude-x"]; m.OwnedBy != "opencode" {
+		t.Errorf("opencode/claude-x = %+v", m)
+	}
+
+	for id, want := range map[string]bool{"big-pickle": true, "bp": true, "claude-x": true, "opencode/claude-x": true, "gpt-9": false} {
+		if got := a.SupportsModel(id); got != want {
+			t.Errorf("SupportsModel(%s) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestParseModelID(t *testing.T) {
+	tests := []struct {
+		name         string
+		discovered   bool
+		model        string
+		wantProvider string
+		wantModel    string
+	}{
+		{name: "discovered", discovered: true, model: "claude-x", wantProvider: "anthropic", wantModel: "claude-x"},
+		{name: "discovered with provider", discovered: true, model: "opencode/claude-x", wantProvider: "opencode", wantModel: "claude-x"},
+		{name: "explicit provider", discovered: true, model: "openrouter/qwen3", wantProvider: "openrouter", wantModel: "qwen3"},
+		{name: "gemini guess", model: "gemini-2.5-pro", wantProvider: "google", wantModel: "gemini-2.5-pro"},
+		{name: "unknown", model: "claude-x", wantProvider: "opencode", wantModel: "claude-x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New("opencode", config.BackendConfig{}, nil)
+			if tt.discovered {
+				var resp providersResponse
+				if err := json.Unmarshal([]byte(providersBody), &resp); err != nil {
+					t.Fatal(err)
+				}
+				a.catalog = newCatalog(&resp)
+			}
+
+			provider, model := a.parseModelID(tt.model)
+			if provider != tt.wantProvider || model != tt.wantModel {
+				t.Errorf("parseModelID(%s) = %s, %s, want %s, %s", tt.model, provider, model, tt.wantProvider, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestDiscoveryRefresh(t *testing.T) {
+	upstream := &fakeProviders{status: 200, body: `{"providers":[{"id":"opencode","models":{"a":{"id":"a"}}}]}`}
+	a := newDiscoveringAdapter(t, upstream, 20*time.Millisecond)
+	if !a.SupportsModel("a") {
+		t.Fatal("model a was not discovered at startup")
+	}
+
+	// A failed refresh keeps the models found before.
+	upstream.set(http.StatusInternalServerError, "")
+	time.Sleep(60 * time.Millisecond)
+	if !a.SupportsModel("a") {
+		t.Fatal("model a was dropped after a failed refresh")
+	}
+
+	upstream.set(http.StatusOK, `{"providers":[{"id":"opencode","models":{"b":{"id":"b"}}}]}`)
+	if !eventually(func() bool { return a.SupportsModel("b") && !a.SupportsModel("a") }) {
+		t.Error("the refreshed catalog was not installed")
+	}
+}
@@ -2,76 +2,340 @@ package adapters
 
 import (
 	"fmt"
+	"log"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
 )
 
+// entry counts the requests that hold a lease on an adapter, so that a
+// replaced adapter can finish them before it is shut down.
+type entry struct {
+	adapter  Adapter
+	priority int
+
+	refs    atomic.Int64
+	retired atomic.Bool
+	drained chan struct{}
+	once    sync.Once
+}
+
+func newEntry(adapter Adapter) *entry {
+	return &entry{adapter: adapter, drained: make(chan struct{})}
+}
+
+// acquire takes a lease, unless the entry has been replaced.
+func (e *entry) acquire() bool {
+	e.refs.Add(1)
+	if e.retired.Load() {
+		e.release()
+		return false
+	}
+	return true
+}
+
+func (e *entry) release() {
+	if e.refs.Add(-1) == 0 && e.retired.Load() {
+		e.once.Do(func() { close(e.drained) })
+	}
+}
+
+// retire stops new leases; drained is closed once the last one is released.
+func (e *entry) retire() {
+	e.retired.Store(true)
+	if e.refs.Load() == 0 {
+		e.once.Do(func() { close(e.drained) })
+	}
+}
+
+// ModelConflict is a model name that several backends claim, or that a
+// virtual model shadows. Backends are in lookup order: the first one wins.
+type ModelConflict struct {
+	Name     string
+	Backends []string
+}
+
+func (c ModelConflict) String() string {
+	return fmt.Sprintf("model %q is offered by %s; %s wins", c.Name, strings.Join(c.Backends, ", "), c.Backends[0])
+}
+
+// virtualBackend names virtual models in conflicts.
+const virtualBackend = "virtual"
+
+type target struct {
+	entry *entry
+	model string
+}
+
+// snapshot is an immutable view of the registry. Lookups read the current
+// snapshot without locking; every change builds a new one.
+type snapshot struct {
+	entries        map[string]*entry
+	order          []*entry
+	defaultBackend string
+	index          map[string]target
+	virtual        map[string]config.VirtualModelConfig
+	virtualOrder   []config.VirtualModelConfig
+	conflicts      []ModelConflict
+}
+
+// Registry holds the backend adapters and an index from every model ID and
+// alias they list to the adapter that serves it. When several backends
+// claim a name, the default backend wins, then the highest priority, then
+// the lowest backend ID. Names missing from the index, such as models an
+// upstream added since the last Reindex, are looked up in the same order.
 type Registry struct {
-	adapters       map[string]Adapter
-	mu             sync.RWMutex
+	mu             sync.Mutex
+	adapters       map[string]*entry
 	defaultBackend string
+	priorities     map[string]int
+	virtual        []config.VirtualModelConfig
+
+	current atomic.Pointer[snapshot]
 }
 
 func NewRegistry(defaultBackend string) *Registry {
-	return &Registry{
-		adapters:       make(map[string]Adapter),
+	r := &Registry{
+		adapters:       make(map[string]*entry),
 		defaultBackend: defaultBackend,
+		priorities:     make(map[string]int),
 	}
+	r.current.Store(&snapshot{})
+	r.publish()
+	return r
 }
 
 func (r *Registry) Register(adapter Adapter) {
+	r.Replace(adapter.ID(), adapter)
+}
+
+// Replace installs adapter under id. Any adapter it replaces stops receiving
+// new requests and is shut down once its in-flight requests have finished.
+// A nil adapter removes the backend.
+func (r *Registry) Replace(id string, adapter Adapter) {
+	r.mu.Lock()
+	old := r.adapters[id]
+	if adapter != nil {
+		e := newEntry(adapter)
+		e.priority = r.priorities[id]
+		r.adapters[id] = e
+	} else {
+		delete(r.adapters, id)
+	}
+	r.publish()
+	r.mu.Unlock()
+
+	if old != nil {
+		old.retire()
+		go func() {
+			<-old.drained
+			old.adapter.Shutdown()
+		}()
+	}
+}
+
+func (r *Registry) SetDefault(defaultBackend string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.adapters[adapter.ID()] = adapter
+	r.defaultBackend = defaultBackend
+	r.publish()
+}
+
+// SetPriority sets the lookup priority of a backend; higher wins. It may be
+// called before the backend is registered.
+func (r *Registry) SetPriority(id string, priority int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.priorities[id] = priority
+	if e, ok := r.adapters[id]; ok && e.priority != priority {
+		e.priority = priority
+		r.publish()
+	}
+}
+
+// SetVirtualModels replaces the virtual model definitions.
+func (r *Registry) SetVirtualModels(list []config.VirtualModelConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.virtual = list
+	r.publish()
+}
+
+// Reindex rebuilds the model index, picking up models that backends have
+// discovered since it was last built.
+func (r *Registry) Reindex() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.publish()
+}
+
+// publish builds a snapshot from the registry's state and makes it current.
+// Conflicts that were not there before are logged. r.mu must be held.
+func (r *Registry) publish() {
+	s := &snapshot{
+		entries:        make(map[string]*entry, len(r.adapters)),
+		defaultBackend: r.defaultBackend,
+		index:          make(map[string]target),
+		virtual:        make(map[string]config.VirtualModelConfig, len(r.virtual)),
+		virtualOrder:   r.virtual,
+	}
+	for id, e := range r.adapters {
+		s.entries[id] = e
+		s.order = append(s.order, e)
+	}
+	sort.Slice(s.order, func(i, j int) bool {
+		a, b := s.order[i], s.order[j]
+		if (a.adapter.ID() == s.defaultBackend) != (b.adapter.ID() == s.defaultBackend) {
+			return a.adapter.ID() == s.defaultBackend
+		}
+		if a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		return a.adapter.ID() < b.adapter.ID()
+	})
+
+	claims := make(map[string][]string)
+	for _, e := range s.order {
+		list, err := e.adapter.ListModels()
+		if err != nil {
+			continue
+		}
+		for _, m := range list {
+			for _, name := range append([]string{m.ID}, m.Aliases...) {
+				if _, ok := s.index[name]; !ok {
+					s.index[name] = target{entry: e, model: e.adapter.ResolveModel(name)}
+				}
+				claims[name] = appendUnique(claims[name], e.adapter.ID())
+			}
+		}
+	}
+	for _, vm := range r.virtual {
+		s.virtual[vm.ID] = vm
+		if backends, ok := claims[vm.ID]; ok {
+			claims[vm.ID] = append([]string{virtualBackend}, backends...)
+		}
+	}
+
+	for name, backends := range claims {
+		if len(backends) > 1 {
+			s.conflicts = append(s.conflicts, ModelConflict{Name: name, Backends: backends})
+		}
+	}
+	sort.Slice(s.conflicts, func(i, j int) bool { return s.conflicts[i].Name < s.conflicts[j].Name })
+
+	known := make(map[string]bool)
+	for _, c := range r.current.Load().conflicts {
+		known[c.String()] = true
+	}
+	for _, c := range s.conflicts {
+		if !known[c.String()] {
+			log.Printf("Warning: %s", c)
+		}
+	}
+
+	r.current.Store(s)
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// Conflicts lists the model names claimed by more than one backend.
+func (r *Registry) Conflicts() []ModelConflict {
+	return r.current.Load().conflicts
+}
+
+// VirtualModel returns the definition of a virtual model. Virtual models are
+// resolved before anything else, so they shadow backend models of the same
+// name.
+func (r *Registry) VirtualModel(id string) (config.VirtualModelConfig, bool) {
+	vm, ok := r.current.Load().virtual[id]
+	return vm, ok
+}
+
+// VirtualModels lists the virtual models in config order.
+func (r *Registry) VirtualModels() []config.VirtualModelConfig {
+	return r.current.Load().virtualOrder
 }
 
 func (r *Registry) Get(id string) (Adapter, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	adapter, ok := r.adapters[id]
-	return adapter, ok
+	e, ok := r.current.Load().entries[id]
+	if !ok {
+		return nil, false
+	}
+	return e.adapter, true
 }
 
 func (r *Registry) GetDefault() (Adapter, bool) {
-	return r.Get(r.defaultBackend)
+	s := r.current.Load()
+	e, ok := s.entries[s.defaultBackend]
+	if !ok {
+		return nil, false
+	}
+	return e.adapter, true
 }
 
+// List returns the adapters in lookup order.
 func (r *Registry) List() []Adapter {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	adapters := make([]Adapter, 0, len(r.adapters))
-	for _, a := range r.adapters {
-		adapters = append(adapters, a)
+	s := r.current.Load()
+	adapters := make([]Adapter, 0, len(s.order))
+	for _, e := range s.order {
+		adapters = append(adapters, e.adapter)
 	}
 	return adapters
 }
 
 func (r *Registry) FindAdapterForModel(model string) (Adapter, string, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	backendID, modelID := parseModel(model)
+	e, resolvedModel, err := r.current.Load().find(model)
+	if err != nil {
+		return nil, "", err
+	}
+	return e.adapter, resolvedModel, nil
+}
 
-	if backendID != "" {
-		adapter, ok := r.adapters[backendID]
-		if !ok {
-			return nil, "", fmt.Errorf("backend '%s' not found", backendID)
+// Lease is FindAdapterForModel for request handling: the adapter is kept
+// alive until the returned release func is called, even if a config reload
+// replaces it in the meantime.
+func (r *Registry) Lease(model string) (Adapter, string, func(), error) {
+	for {
+		e, resolvedModel, err := r.current.Load().find(model)
+		if err != nil {
+			return nil, "", nil, err
 		}
-		if !adapter.SupportsModel(modelID) {
-			return nil, "", fmt.Errorf("model '%s' not found in backend '%s'", modelID, backendID)
+		// A failed acquire means a reload replaced the adapter after the
+		// snapshot was read; the new snapshot is already current.
+		if e.acquire() {
+			var once sync.Once
+			return e.adapter, resolvedModel, func() { once.Do(e.release) }, nil
 		}
-		return adapter, adapter.ResolveModel(modelID), nil
 	}
+}
 
-	if defaultAdapter, ok := r.adapters[r.defaultBackend]; ok {
-		if defaultAdapter.SupportsModel(model) {
-			return defaultAdapter, defaultAdapter.ResolveModel(model), nil
+func (s *snapshot) find(model string) (*entry, string, error) {
+	if backendID, modelID := parseModel(model); backendID != "" {
+		if e, ok := s.entries[backendID]; ok {
+			if !e.adapter.SupportsModel(modelID) {
+				return nil, "", fmt.Errorf("model '%s' not found in backend '%s'", modelID, backendID)
+			}
+			return e, e.adapter.ResolveModel(modelID), nil
 		}
 	}
 
-	for _, adapter := range r.adapters {
-		if adapter.SupportsModel(model) {
-			return adapter, adapter.ResolveModel(model), nil
+	if t, ok := s.index[model]; ok {
+		return t.entry, t.model, nil
+	}
+
+	for _, e := range s.order {
+		if e.adapter.SupportsModel(model) {
+			return e, e.adapter.ResolveModel(model), nil
 		}
 	}
 
@@ -88,10 +352,7 @@ func parseModel(model string) (backend, modelID string) {
 }
 
 func (r *Registry) Shutdown() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	for _, adapter := range r.adapters {
-		adapter.Shutdown()
+	for _, e := range r.current.Load().order {
+		e.adapter.Shutdown()
 	}
 }
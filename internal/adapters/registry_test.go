@@ -0,0 +1,175 @@
+package adapters
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+type fakeAdapter struct {
+	BaseAdapter
+	models   []string
+	shutdown atomic.Bool
+}
+
+func newFakeAdapter(id string, modelIDs ...string) *fakeAdapter {
+	return &fakeAdapter{BaseAdapter: BaseAdapter{id: id, name: id, healthy: true}, models: modelIDs}
+}
+
+func (a *fakeAdapter) Initialize(map[string]interface{}) error { return nil }
+func (a *fakeAdapter) HealthCheck() error                      { return nil }
+func (a *fakeAdapter) SupportsStreaming() bool                 { return true }
+func (a *fakeAdapter) ResolveModel(modelID string) string      { return modelID }
+
+func (a *fakeAdapter) Shutdown() error {
+	a.shutdown.Store(true)
+	return nil
+}
+
+func (a *fakeAdapter) ListModels() ([]models.Model, error) {
+	list := make([]models.Model, 0, len(a.models))
+	for _, id := range a.models {
+		list = append(list, models.Model{ID: id, Backend: a.ID()})
+	}
+	return list, nil
+}
+
+func (a *fakeAdapter) SupportsModel(modelID string) bool {
+	for _, id := range a.models {
+		if id == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *fakeAdapter) Chat(context.Context, *models.ChatRequest) (*models.ChatResponse, error) {
+	return &models.ChatResponse{}, nil
+}
+
+func (a *fakeAdapter) ChatStream(context.Context, *models.ChatRequest) (<-chan models.StreamChunk, <-chan error) {
+	chunks := make(chan models.StreamChunk)
+	errs := make(chan error)
+	close(chunks)
+	close(errs)
+	return chunks, errs
+}
+
+// eventually polls cond for up to a second.
+func eventually(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+func TestRegistryReplaceDrainsLeases(t *testing.T) {
+	tests := []struct {
+		name        string
+		leases      int
+		replacement bool
+	}{
+		{name: "no leases", leases: 0, replacement: true},
+		{name: "one lease", leases: 1, replacement: true},
+		{name: "several leases", leases: 3, replacement: true},
+		{name: "removed with leases", leases: 2, replacement: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry("b")
+			old := newFakeAdapter("b", "m")
+			r.Register(old)
+
+			var releases []func()
+			for i := 0; i < tt.leases; i++ {
+				adapter, model, release, err := r.Lease("m")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if adapter != old || model != "m" {
+					t.Fatalf("lease = %s/%s, want b/m", adapter.ID(), model)
+				}
+				releases = append(releases, release)
+			}
+
+			var next *fakeAdapter
+			if tt.replacement {
+				next = newFakeAdapter("b", "m")
+				r.Replace("b", next)
+			} else {
+				r.Replace("b", nil)
+			}
+
+			adapter, _, release, err := r.Lease("m")
+			switch {
+			case tt.replacement && (err != nil || adapter != next):
+				t.Fatalf("lease after replace = %v, %v; want the new adapter", adapter, err)
+			case !tt.replacement && err == nil:
+				t.Fatal("lease after removal succeeded")
+			}
+			if release != nil {
+				release()
+			}
+
+			for i, release := range releases {
+				time.Sleep(5 * time.Millisecond)
+				if old.shutdown.Load() {
+					t.Fatalf("old adapter shut down with %d leases held", len(releases)-i)
+				}
+				release()
+				release() // releasing twice must not count twice
+			}
+
+			if !eventually(old.shutdown.Load) {
+				t.Error("old adapter not shut down after its leases were released")
+			}
+			if next != nil && next.shutdown.Load() {
+				t.Error("new adapter shut down")
+			}
+		})
+	}
+}
+
+func TestRegistryLeaseLookupOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultID  string
+		priorities map[string]int
+		model      string
+		want       string
+	}{
+		{name: "default backend wins", defaultID: "z", model: "shared", want: "z"},
+		{name: "higher priority wins", priorities: map[string]int{"z": 10}, model: "shared", want: "z"},
+		{name: "lowest ID wins", model: "shared", want: "a"},
+		{name: "explicit backend", model: "z/shared", want: "z"},
+		{name: "single claim", model: "only-z", want: "z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(tt.defaultID)
+			for id, priority := range tt.priorities {
+				r.SetPriority(id, priority)
+			}
+			r.Register(newFakeAdapter("a", "shared"))
+			r.Register(newFakeAdapter("z", "shared", "only-z"))
+
+			adapter, _, release, err := r.Lease(tt.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer release()
+			if adapter.ID() != tt.want {
+				t.Errorf("lease(%q) = %s, want %s", tt.model, adapter.ID(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+)
+
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 200 * time.Millisecond
+	DefaultRetryMaxBackoff     = 2 * time.Second
+	DefaultRetryMultiplier     = 2.0
+	DefaultRetryJitter         = 0.2
+)
+
+var DefaultRetryableStatus = []int{500, 502, 503, 504}
+
+// StatusError is returned by adapters when an upstream answers with a non
+// successful HTTP status, so callers can decide whether to retry. RetryAfter
+// is set when the upstream said when to come back.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+// RetryAfter parses a Retry-After header given in seconds.
+func RetryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upstream returned status %d: %s", e.StatusCode, e.Body)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err so that RetryPolicy.Do gives up immediately, for example
+// once a stream has already delivered tokens to the client.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type RetryPolicy struct {
+	MaxAttempts     int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	Multiplier      float64
+	Jitter          float64
+	RetryableStatus []int
+}
+
+func NewRetryPolicy(cfg config.RetryConfig) RetryPolicy {
+	p := RetryPolicy{
+		MaxAttempts:     cfg.MaxAttempts,
+		InitialBackoff:  cfg.InitialBackoff,
+		MaxBackoff:      cfg.MaxBackoff,
+		Multiplier:      cfg.Multiplier,
+		Jitter:          DefaultRetryJitter,
+		RetryableStatus: cfg.RetryableStatus,
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultRetryMultiplier
+	}
+	if cfg.Jitter != nil {
+		p.Jitter = *cfg.Jitter
+	}
+	if len(p.RetryableStatus) == 0 {
+		p.RetryableStatus = DefaultRetryableStatus
+	}
+	return p
+}
+
+// Do runs op until it succeeds, returns a non-retryable error, or the policy
+// runs out of attempts. A Retry-After from the upstream is waited out, unless
+// it is longer than MaxBackoff or than ctx has left; then the error is
+// returned at once rather than holding the request. Only use it for calls
+// that are safe to repeat and that have not yet produced output for the
+// client.
+func (p RetryPolicy) Do(ctx context.Context, op func() error) error {
+	backoff := p.InitialBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op()
+		if err == nil || attempt >= p.MaxAttempts || !p.Retryable(ctx, err) {
+			return err
+		}
+
+		delay := backoff
+		if p.Jitter > 0 {
+			delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(backoff))
+		}
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && delay < statusErr.RetryAfter {
+			if statusErr.RetryAfter > p.MaxBackoff {
+				return err
+			}
+			delay = statusErr.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff = time.Duration(float64(backoff) * p.Multiplier)
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+func (p RetryPolicy) Retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		if turnedAway(statusErr) {
+			return true
+		}
+		for _, code := range p.RetryableStatus {
+			if statusErr.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Unsent reports whether err shows that the upstream never took the request
+// in: the connection could not be made, or the upstream turned it away with a
+// Retry-After. Calls that are not safe to repeat may only retry these.
+func Unsent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && turnedAway(statusErr)
+}
+
+func turnedAway(err *StatusError) bool {
+	return err.RetryAfter > 0 && (err.StatusCode == http.StatusTooManyRequests || err.StatusCode == http.StatusServiceUnavailable)
+}
@@ -0,0 +1,146 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:     3,
+		InitialBackoff:  time.Millisecond,
+		MaxBackoff:      100 * time.Millisecond,
+		Multiplier:      2,
+		RetryableStatus: DefaultRetryableStatus,
+	}
+
+	tests := []struct {
+		name     string
+		errs     []error
+		wantErr  bool
+		attempts int
+		minWait  time.Duration
+	}{
+		{name: "success", errs: []error{nil}, attempts: 1},
+		{name: "retryable status then success", errs: []error{&StatusError{StatusCode: 502}, nil}, attempts: 2},
+		{name: "connection reset", errs: []error{io.ErrUnexpectedEOF, nil}, attempts: 2},
+		{name: "gives up after max attempts", errs: []error{&StatusError{StatusCode: 503}, &StatusError{StatusCode: 503}, &StatusError{StatusCode: 503}}, wantErr: true, attempts: 3},
+		{name: "client error is not retried", errs: []error{&StatusError{StatusCode: 400}}, wantErr: true, attempts: 1},
+		{name: "permanent is not retried", errs: []error{Permanent(&StatusError{StatusCode: 502})}, wantErr: true, attempts: 1},
+		{name: "other errors are not retried", errs: []error{errors.New("bad response")}, wantErr: true, attempts: 1},
+		{name: "rate limit with retry after", errs: []error{&StatusError{StatusCode: 429, RetryAfter: 50 * time.Millisecond}, nil}, attempts: 2, minWait: 50 * time.Millisecond},
+		{name: "rate limit without retry after", errs: []error{&StatusError{StatusCode: 429}}, wantErr: true, attempts: 1},
+		{name: "retry after beyond max backoff", errs: []error{&StatusError{StatusCode: 503, RetryAfter: time.Hour}, nil}, wantErr: true, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			start := time.Now()
+			err := policy.Do(context.Background(), func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+			if waited := time.Since(start); waited < tt.minWait {
+				t.Errorf("waited %s, want at least %s", waited, tt.minWait)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoStopsWhenCancelled(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour, Multiplier: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	attempts := 0
+	err := policy.Do(ctx, func() error {
+		attempts++
+		return io.EOF
+	})
+	if !errors.Is(err, io.EOF) || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want io.EOF after 1", err, attempts)
+	}
+}
+
+func TestRetryPolicyDoGivesUpPastDeadline(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := policy.Do(ctx, func() error {
+		attempts++
+		return &StatusError{StatusCode: 429, RetryAfter: 500 * time.Millisecond}
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want the 429 after 1", err, attempts)
+	}
+	if waited := time.Since(start); waited > 50*time.Millisecond {
+		t.Errorf("waited %s for a retry that could not happen before the deadline", waited)
+	}
+}
+
+func TestNewRetryPolicyJitter(t *testing.T) {
+	zero, half := 0.0, 0.5
+
+	tests := []struct {
+		name   string
+		jitter *float64
+		want   float64
+	}{
+		{name: "unset", jitter: nil, want: DefaultRetryJitter},
+		{name: "disabled", jitter: &zero, want: 0},
+		{name: "set", jitter: &half, want: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRetryPolicy(config.RetryConfig{Jitter: tt.jitter})
+			if p.Jitter != tt.want {
+				t.Errorf("jitter = %v, want %v", p.Jitter, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "dial error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{name: "read error", err: &net.OpError{Op: "read", Err: errors.New("connection reset")}, want: false},
+		{name: "turned away", err: &StatusError{StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Second}, want: true},
+		{name: "unavailable without retry after", err: &StatusError{StatusCode: http.StatusServiceUnavailable}, want: false},
+		{name: "server error", err: &StatusError{StatusCode: http.StatusBadGateway, RetryAfter: time.Second}, want: false},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unsent(tt.err); got != tt.want {
+				t.Errorf("Unsent(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// WithSamplingEmulation returns an adapter that emulates stop sequences and
+// n > 1 on top of adapter when the upstream cannot handle them itself, or,
+// for stop sequences, when req.MatchStop asks for it. Stop sequences are cut
+// from the output and n is served by parallel calls.
+func WithSamplingEmulation(adapter Adapter, req *models.ChatRequest) Adapter {
+	stop := len(req.Stop) > 0 && (req.MatchStop || !adapter.SupportsParameter(models.ParamStop))
+
+	n := 1
+	if req.N != nil && *req.N > 1 && !adapter.SupportsParameter(models.ParamN) {
+		n = *req.N
+	}
+
+	if !stop && n == 1 {
+		return adapter
+	}
+	return &samplingAdapter{Adapter: adapter, stop: stop, n: n}
+}
+
+type samplingAdapter struct {
+	Adapter
+	stop bool
+	n    int
+}
+
+// upstream returns the request sent to the wrapped adapter, without the
+// parameters that are emulated here.
+func (a *samplingAdapter) upstream(req *models.ChatRequest) *models.ChatRequest {
+	inner := *req
+	if a.stop {
+		inner.Stop = nil
+	}
+	if a.n > 1 {
+		inner.N = nil
+	}
+	return &inner
+}
+
+func (a *samplingAdapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.ChatResponse, error) {
+	inner := a.upstream(req)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*models.ChatResponse, a.n)
+	errs := make([]error, a.n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < a.n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = a.Adapter.Chat(ctx, inner)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Report the call that failed first rather than the ones it cancelled.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := *results[0]
+	resp.Choices = nil
+	var usage *models.Usage
+	for i, r := range results {
+		for _, choice := range r.Choices {
+			choice.Index = i
+			if a.stop {
+				matcher := NewStopMatcher(req.Stop)
+				if text, stopped := matcher.Truncate(choice.Message.Content); stopped {
+					choice.Message.Content = text
+					choice.FinishReason = "stop"
+					choice.StopSequence = matcher.Matched()
+				}
+			}
+			resp.Choices = append(resp.Choices, choice)
+			// Only the first choice of each call is kept.
+			break
+		}
+		if r.Usage != nil {
+			if usage == nil {
+				usage = &models.Usage{}
+			}
+			usage.PromptTokens += r.Usage.PromptTokens
+			usage.CompletionTokens += r.Usage.CompletionTokens
+			usage.TotalTokens += r.Usage.TotalTokens
+		}
+	}
+	resp.Usage = usage
+	return &resp, nil
+}
+
+func (a *samplingAdapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamChunk, <-chan error) {
+	inner := a.upstream(req)
+
+	chunks := make(chan models.StreamChunk, 100)
+	errs := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	fail := func(err error) {
+		once.Do(func() {
+			errs <- err
+			cancel()
+		})
+	}
+
+	for i := 0; i < a.n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := a.forward(ctx, inner, i, req.Stop, chunks); err != nil {
+				fail(err)
+			}
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(chunks)
+		close(errs)
+	}()
+
+	return chunks, errs
+}
+
+// forward relays one upstream stream as choice index, cutting the text at
+// the first stop sequence when stop emulation is on.
+func (a *samplingAdapter) forward(ctx context.Context, req *models.ChatRequest, index int, stops []string, out chan<- models.StreamChunk) error {
+	// The upstream is cancelled once a stop sequence ends this choice.
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	upChunks, upErrs := a.Adapter.ChatStream(streamCtx, req)
+
+	var stop *StopMatcher
+	if a.stop {
+		stop = NewStopMatcher(stops)
+	}
+	finished := false
+
+	send := func(chunk models.StreamChunk) error {
+		select {
+		case out <- chunk:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var last models.StreamChunk
+	for chunk := range upChunks {
+		last = chunk
+		if finished {
+			// The usage of the whole request comes after the finish reason.
+			// With n > 1 there is one such chunk per call, so none is sent.
+			if chunk.Usage != nil && len(chunk.Choices) == 0 && a.n == 1 {
+				if err := send(chunk); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		choices := make([]models.ChunkChoice, 0, len(chunk.Choices))
+		for _, choice := range chunk.Choices {
+			choice.Index = index
+			if stop != nil {
+				var stopped bool
+				choice.Delta.Content, stopped = stop.Push(choice.Delta.Content)
+				switch {
+				case stopped:
+					choice.FinishReason = "stop"
+					choice.StopSequence = stop.Matched()
+					finished = true
+					cancel()
+				case choice.FinishReason != "":
+					choice.Delta.Content += stop.Flush()
+					finished = true
+				}
+			} else if choice.FinishReason != "" {
+				finished = true
+			}
+			choices = append(choices, choice)
+			if finished {
+				break
+			}
+		}
+		chunk.Choices = choices
+
+		if err := send(chunk); err != nil {
+			return err
+		}
+	}
+
+	if err := <-upErrs; err != nil && !finished {
+		return err
+	}
+
+	// The upstream ended without a finish reason; release held-back text.
+	if !finished && stop != nil {
+		if text := stop.Flush(); text != "" {
+			last.Choices = []models.ChunkChoice{{Index: index, Delta: models.Delta{Content: text}}}
+			return send(last)
+		}
+	}
+	return nil
+}
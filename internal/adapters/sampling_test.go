@@ -0,0 +1,264 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// scriptedAdapter answers every call with reply, or streams chunks and
+// then err. Parameters in supported are passed to the upstream.
+type scriptedAdapter struct {
+	*fakeAdapter
+	supported []string
+	reply     string
+	chunks    []models.StreamChunk
+	err       error
+
+	mu        sync.Mutex
+	requests  []models.ChatRequest
+	cancelled bool
+}
+
+func newScriptedAdapter(supported ...string) *scriptedAdapter {
+	return &scriptedAdapter{fakeAdapter: newFakeAdapter("up", "m"), supported: supported}
+}
+
+func (a *scriptedAdapter) SupportsParameter(name string) bool {
+	for _, p := range a.supported {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *scriptedAdapter) record(req *models.ChatRequest) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = append(a.requests, *req)
+}
+
+func (a *scriptedAdapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.ChatResponse, error) {
+	a.record(req)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &models.ChatResponse{
+		Choices: []models.Choice{{Message: models.Message{Role: "assistant", Content: a.reply}, FinishReason: "length"}},
+		Usage:   &models.Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5},
+	}, nil
+}
+
+func (a *scriptedAdapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamChunk, <-chan error) {
+	a.record(req)
+	chunks := make(chan models.StreamChunk)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+		defer func() {
+			a.mu.Lock()
+			a.cancelled = ctx.Err() != nil
+			a.mu.Unlock()
+		}()
+		for _, chunk := range a.chunks {
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if a.err != nil {
+			errs <- a.err
+		}
+	}()
+	return chunks, errs
+}
+
+func textChunk(text, finishReason string) models.StreamChunk {
+	return models.StreamChunk{Choices: []models.ChunkChoice{{Delta: models.Delta{Content: text}, FinishReason: finishReason}}}
+}
+
+func usageChunk() models.StreamChunk {
+	return models.StreamChunk{Choices: []models.ChunkChoice{}, Usage: &models.Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5}}
+}
+
+func TestWithSamplingEmulation(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name      string
+		supported []string
+		req       models.ChatRequest
+		wantWrap  bool
+	}{
+		{name: "nothing to emulate", req: models.ChatRequest{}},
+		{name: "stop supported", supported: []string{models.ParamStop}, req: models.ChatRequest{Stop: []string{"END"}}},
+		{name: "stop unsupported", req: models.ChatRequest{Stop: []string{"END"}}, wantWrap: true},
+		{name: "stop matched by the gateway", supported: []string{models.ParamStop}, req: models.ChatRequest{Stop: []string{"END"}, MatchStop: true}, wantWrap: true},
+		{name: "n supported", supported: []string{models.ParamN}, req: models.ChatRequest{N: &two}},
+		{name: "n unsupported", req: models.ChatRequest{N: &two}, wantWrap: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := newScriptedAdapter(tt.supported...)
+			_, wrapped := WithSamplingEmulation(up, &tt.req).(*samplingAdapter)
+			if wrapped != tt.wantWrap {
+				t.Errorf("wrapped = %v, want %v", wrapped, tt.wantWrap)
+			}
+		})
+	}
+}
+
+func TestSamplingChat(t *testing.T) {
+	three := 3
+	up := newScriptedAdapter()
+	up.reply = "one two STOP three"
+	req := &models.ChatRequest{Model: "m", N: &three, Stop: []string{"STOP"}}
+
+	resp, err := WithSamplingEmulation(up, req).Chat(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(up.requests) != 3 {
+		t.Fatalf("upstream calls = %d, want 3", len(up.requests))
+	}
+	for _, r := range up.requests {
+		if r.N != nil || r.Stop != nil {
+			t.Errorf("upstream request has n = %v, stop = %v, want neither", r.N, r.Stop)
+		}
+	}
+	for i, choice := range resp.Choices {
+		want := models.Choice{Index: i, Message: models.Message{Role: "assistant", Content: "one two "}, FinishReason: "stop", StopSequence: "STOP"}
+		if !reflect.DeepEqual(choice, want) {
+			t.Errorf("choice %d = %+v, want %+v", i, choice, want)
+		}
+	}
+	if len(resp.Choices) != 3 {
+		t.Errorf("choices = %d, want 3", len(resp.Choices))
+	}
+	if resp.Usage == nil || *resp.Usage != (models.Usage{PromptTokens: 6, CompletionTokens: 9, TotalTokens: 15}) {
+		t.Errorf("usage = %+v, want the sum of the calls", resp.Usage)
+	}
+
+	up.err = errors.New("upstream down")
+	if _, err := WithSamplingEmulation(up, req).Chat(context.Background(), req); !errors.Is(err, up.err) {
+		t.Errorf("err = %v, want the upstream error", err)
+	}
+}
+
+func TestSamplingChatStream(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name          string
+		n             *int
+		stop          []string
+		chunks        []models.StreamChunk
+		wantText      map[int]string
+		wantFinish    map[int]string
+		wantStopSeq   string
+		wantUsage     int
+		wantCancelled bool
+	}{
+		{
+			name:          "stop sequence split across chunks",
+			stop:          []string{"END"},
+			chunks:        []models.StreamChunk{textChunk("Hello E", ""), textChunk("ND world", ""), textChunk("more", ""), textChunk("", "length")},
+			wantText:      map[int]string{0: "Hello "},
+			wantFinish:    map[int]string{0: "stop"},
+			wantStopSeq:   "END",
+			wantCancelled: true,
+		},
+		{
+			name:       "usage after the finish reason",
+			stop:       []string{"END"},
+			chunks:     []models.StreamChunk{textChunk("Hello wor", ""), textChunk("ld", "length"), usageChunk()},
+			wantText:   map[int]string{0: "Hello world"},
+			wantFinish: map[int]string{0: "length"},
+			wantUsage:  1,
+		},
+		{
+			name:       "no finish reason",
+			stop:       []string{"END"},
+			chunks:     []models.StreamChunk{textChunk("Hello", "")},
+			wantText:   map[int]string{0: "Hello"},
+			wantFinish: map[int]string{},
+		},
+		{
+			name:       "n calls",
+			n:          &two,
+			chunks:     []models.StreamChunk{textChunk("Hi", ""), textChunk("", "stop"), usageChunk()},
+			wantText:   map[int]string{0: "Hi", 1: "Hi"},
+			wantFinish: map[int]string{0: "stop", 1: "stop"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := newScriptedAdapter()
+			up.chunks = tt.chunks
+			req := &models.ChatRequest{Model: "m", N: tt.n, Stop: tt.stop}
+
+			chunks, errs := WithSamplingEmulation(up, req).ChatStream(context.Background(), req)
+			text := map[int]string{}
+			finish := map[int]string{}
+			var stopSeq string
+			var usage int
+			for chunk := range chunks {
+				if chunk.Usage != nil {
+					usage++
+				}
+				for _, choice := range chunk.Choices {
+					text[choice.Index] += choice.Delta.Content
+					if choice.FinishReason != "" {
+						finish[choice.Index] = choice.FinishReason
+						stopSeq = choice.StopSequence
+					}
+				}
+			}
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(text, tt.wantText) {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if !reflect.DeepEqual(finish, tt.wantFinish) {
+				t.Errorf("finish reasons = %v, want %v", finish, tt.wantFinish)
+			}
+			if stopSeq != tt.wantStopSeq {
+				t.Errorf("stop sequence = %q, want %q", stopSeq, tt.wantStopSeq)
+			}
+			if usage != tt.wantUsage {
+				t.Errorf("usage chunks = %d, want %d", usage, tt.wantUsage)
+			}
+			// The stream only ends once every upstream stream has.
+			up.mu.Lock()
+			defer up.mu.Unlock()
+			if up.cancelled != tt.wantCancelled {
+				t.Errorf("upstream cancelled = %v, want %v", up.cancelled, tt.wantCancelled)
+			}
+		})
+	}
+}
+
+func TestSamplingChatStreamError(t *testing.T) {
+	two := 2
+	up := newScriptedAdapter()
+	up.chunks = []models.StreamChunk{textChunk("Hi", "")}
+	up.err = errors.New("upstream went away")
+	req := &models.ChatRequest{Model: "m", N: &two}
+
+	chunks, errs := WithSamplingEmulation(up, req).ChatStream(context.Background(), req)
+	for range chunks {
+	}
+	if err := <-errs; !errors.Is(err, up.err) {
+		t.Errorf("err = %v, want the upstream error", err)
+	}
+}
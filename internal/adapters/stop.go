@@ -0,0 +1,92 @@
+package adapters
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// StopMatcher cuts generated text at the first stop sequence. Streamed text
+// is held back just long enough to catch a sequence split across chunks.
+type StopMatcher struct {
+	stops   []string
+	holdLen int
+	pending string
+	matched string
+}
+
+func NewStopMatcher(stops []string) *StopMatcher {
+	m := &StopMatcher{}
+	for _, s := range stops {
+		if s == "" {
+			continue
+		}
+		m.stops = append(m.stops, s)
+		if len(s)-1 > m.holdLen {
+			m.holdLen = len(s) - 1
+		}
+	}
+	return m
+}
+
+// Push adds generated text and returns the part that is safe to emit. Once a
+// stop sequence is seen, stopped is true and later text is discarded.
+func (m *StopMatcher) Push(text string) (out string, stopped bool) {
+	if m.matched != "" {
+		return "", true
+	}
+
+	m.pending += text
+	if i, stop := m.index(m.pending); i >= 0 {
+		out = m.pending[:i]
+		m.pending = ""
+		m.matched = stop
+		return out, true
+	}
+
+	cut := len(m.pending) - m.holdLen
+	for cut > 0 && cut < len(m.pending) && !utf8.RuneStart(m.pending[cut]) {
+		cut--
+	}
+	if cut <= 0 {
+		return "", false
+	}
+	out, m.pending = m.pending[:cut], m.pending[cut:]
+	return out, false
+}
+
+// Flush returns any text still held back.
+func (m *StopMatcher) Flush() string {
+	out := m.pending
+	m.pending = ""
+	return out
+}
+
+// Stopped reports whether a stop sequence has been seen.
+func (m *StopMatcher) Stopped() bool {
+	return m.matched != ""
+}
+
+// Matched returns the stop sequence that was seen, if any.
+func (m *StopMatcher) Matched() string {
+	return m.matched
+}
+
+// Truncate cuts a complete text at the first stop sequence.
+func (m *StopMatcher) Truncate(text string) (string, bool) {
+	if i, stop := m.index(text); i >= 0 {
+		m.matched = stop
+		return text[:i], true
+	}
+	return text, false
+}
+
+// index finds the stop sequence that starts first in text.
+func (m *StopMatcher) index(text string) (int, string) {
+	first, match := -1, ""
+	for _, s := range m.stops {
+		if i := strings.Index(text, s); i >= 0 && (first < 0 || i < first) {
+			first, match = i, s
+		}
+	}
+	return first, match
+}
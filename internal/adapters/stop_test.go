@@ -0,0 +1,94 @@
+package adapters
+
+import "testing"
+
+func TestStopMatcherPush(t *testing.T) {
+	tests := []struct {
+		name        string
+		stops       []string
+		pushes      []string
+		want        []string
+		wantStopped bool
+		wantMatched string
+		wantFlush   string
+	}{
+		{
+			name:      "no stop sequence seen",
+			stops:     []string{"END"},
+			pushes:    []string{"Hello", " world"},
+			want:      []string{"Hel", "lo wor"},
+			wantFlush: "ld",
+		},
+		{
+			name:        "split across chunks",
+			stops:       []string{"END"},
+			pushes:      []string{"Hello E", "ND more", "ignored"},
+			want:        []string{"Hello", " ", ""},
+			wantStopped: true,
+			wantMatched: "END",
+		},
+		{
+			name:        "earliest sequence wins",
+			stops:       []string{"world", "lo"},
+			pushes:      []string{"Hello world"},
+			want:        []string{"Hel"},
+			wantStopped: true,
+			wantMatched: "lo",
+		},
+		{
+			name:      "held back text ends on a rune boundary",
+			stops:     []string{"!!"},
+			pushes:    []string{"aé"},
+			want:      []string{"a"},
+			wantFlush: "é",
+		},
+		{
+			name:        "empty sequences are ignored",
+			stops:       []string{"", "X"},
+			pushes:      []string{"abc", "X"},
+			want:        []string{"abc", ""},
+			wantStopped: true,
+			wantMatched: "X",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewStopMatcher(tt.stops)
+			for i, text := range tt.pushes {
+				if out, _ := m.Push(text); out != tt.want[i] {
+					t.Errorf("push %d = %q, want %q", i+1, out, tt.want[i])
+				}
+			}
+			if m.Stopped() != tt.wantStopped || m.Matched() != tt.wantMatched {
+				t.Errorf("stopped = %v, matched = %q, want %v and %q", m.Stopped(), m.Matched(), tt.wantStopped, tt.wantMatched)
+			}
+			if got := m.Flush(); got != tt.wantFlush {
+				t.Errorf("flush = %q, want %q", got, tt.wantFlush)
+			}
+		})
+	}
+}
+
+func TestStopMatcherTruncate(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		want        string
+		wantMatched string
+	}{
+		{name: "no match", text: "Hello world", want: "Hello world"},
+		{name: "match", text: "Hello END world STOP", want: "Hello ", wantMatched: "END"},
+		{name: "match at the start", text: "STOP", want: "", wantMatched: "STOP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewStopMatcher([]string{"STOP", "END"})
+			got, stopped := m.Truncate(tt.text)
+			if got != tt.want || stopped != (tt.wantMatched != "") || m.Matched() != tt.wantMatched {
+				t.Errorf("Truncate = %q, %v, matched %q; want %q, matched %q", got, stopped, m.Matched(), tt.want, tt.wantMatched)
+			}
+		})
+	}
+}
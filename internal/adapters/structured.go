@@ -0,0 +1,219 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/jsonschema"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// CompileResponseFormat checks a response_format and returns the compiled
+// schema for json_schema formats.
+func CompileResponseFormat(format *models.ResponseFormat) (*jsonschema.Schema, error) {
+	if format == nil {
+		return nil, nil
+	}
+
+	switch format.Type {
+	case models.ResponseFormatText, models.ResponseFormatJSONObject:
+		return nil, nil
+	case models.ResponseFormatJSONSchema:
+		if format.JSONSchema == nil || len(format.JSONSchema.Schema) == 0 {
+			return nil, fmt.Errorf("'response_format.json_schema.schema' is required")
+		}
+		return jsonschema.Compile(format.JSONSchema.Schema)
+	}
+	return nil, fmt.Errorf("unsupported response_format type '%s'", format.Type)
+}
+
+// WithResponseFormat returns an adapter that enforces req's response_format
+// on top of adapter when the upstream cannot do so itself. It injects format
+// instructions, validates the output and retries up to retries times.
+func WithResponseFormat(adapter Adapter, req *models.ChatRequest, retries int) Adapter {
+	if !req.ResponseFormat.WantsJSON() || adapter.SupportsResponseFormat() {
+		return adapter
+	}
+
+	schema, err := CompileResponseFormat(req.ResponseFormat)
+	if err != nil {
+		return adapter
+	}
+	return &structuredAdapter{Adapter: adapter, schema: schema, retries: retries}
+}
+
+type structuredAdapter struct {
+	Adapter
+	schema  *jsonschema.Schema
+	retries int
+}
+
+func (a *structuredAdapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.ChatResponse, error) {
+	attempt := *req
+	attempt.Stream = false
+	attempt.ResponseFormat = nil
+
+	var lastErr error
+	for i := 0; i <= a.retries; i++ {
+		attempt.Messages = withFormatInstructions(req.Messages, req.ResponseFormat, lastErr)
+
+		resp, err := a.Adapter.Chat(ctx, &attempt)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 {
+			lastErr = fmt.Errorf("the reply was empty")
+			continue
+		}
+
+		outs, err := a.checkChoices(resp.Choices)
+		if err == nil {
+			for i := range resp.Choices {
+				resp.Choices[i].Message.Content = outs[i]
+			}
+			return resp, nil
+		}
+		lastErr = err
+	}
+
+	return nil, NewError(ErrStructuredOutput, "model output did not match the requested response_format after %d attempts: %v", a.retries+1, lastErr)
+}
+
+// ChatStream produces the validated output as a single chunk, since nothing
+// can be sent before the whole reply has been checked.
+func (a *structuredAdapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamChunk, <-chan error) {
+	chunks := make(chan models.StreamChunk, 2)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+
+		resp, err := a.Chat(ctx, req)
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		chunk := models.StreamChunk{
+			ID:      resp.ID,
+			Object:  "chat.completion.chunk",
+			Created: time.Now().Unix(),
+			Model:   resp.Model,
+		}
+
+		content := chunk
+		done := chunk
+		for _, choice := range resp.Choices {
+			content.Choices = append(content.Choices, models.ChunkChoice{Index: choice.Index, Delta: models.Delta{Role: "assistant", Content: choice.Message.Content}})
+			done.Choices = append(done.Choices, models.ChunkChoice{Index: choice.Index, FinishReason: choice.FinishReason})
+		}
+
+		chunks <- content
+		chunks <- done
+	}()
+
+	return chunks, errs
+}
+
+// checkChoices checks every choice of a reply, so that none is returned
+// unvalidated.
+func (a *structuredAdapter) checkChoices(choices []models.Choice) ([]string, error) {
+	outs := make([]string, len(choices))
+	for i, choice := range choices {
+		out, err := a.check(choice.Message.Content)
+		if err != nil {
+			if len(choices) > 1 {
+				return nil, fmt.Errorf("choice %d: %v", i, err)
+			}
+			return nil, err
+		}
+		outs[i] = out
+	}
+	return outs, nil
+}
+
+// check extracts the JSON document from a reply and validates it.
+func (a *structuredAdapter) check(content string) (string, error) {
+	out := extractJSON(content)
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		return "", fmt.Errorf("the reply is not valid JSON: %v", err)
+	}
+
+	if a.schema == nil {
+		if _, ok := doc.(map[string]interface{}); !ok {
+			return "", fmt.Errorf("the reply must be a JSON object")
+		}
+		return out, nil
+	}
+
+	if err := a.schema.Validate(doc); err != nil {
+		return "", fmt.Errorf("the reply does not match the schema: %v", err)
+	}
+	return out, nil
+}
+
+// extractJSON strips the Markdown fences and surrounding prose that models
+// often add around a JSON reply.
+func extractJSON(content string) string {
+	s := strings.TrimSpace(content)
+
+	if strings.HasPrefix(s, "```") {
+		if i := strings.Index(s, "\n"); i >= 0 {
+			s = s[i+1:]
+		}
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+	}
+
+	if json.Valid([]byte(s)) {
+		return s
+	}
+
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return s
+	}
+	closer := "}"
+	if s[start] == '[' {
+		closer = "]"
+	}
+	if end := strings.LastIndex(s, closer); end > start {
+		return s[start : end+1]
+	}
+	return s
+}
+
+// withFormatInstructions adds the output format to the last system message,
+// or to a new one, along with why the previous attempt was rejected.
+func withFormatInstructions(messages []models.Message, format *models.ResponseFormat, rejected error) []models.Message {
+	var b strings.Builder
+	b.WriteString("Respond with a single valid JSON ")
+	if format.Type == models.ResponseFormatJSONSchema {
+		b.WriteString("value that conforms to this JSON Schema:\n")
+		b.Write(format.JSONSchema.Schema)
+		b.WriteString("\n")
+	} else {
+		b.WriteString("object.\n")
+	}
+	b.WriteString("Output only the JSON, without Markdown code fences or any other text.")
+	if rejected != nil {
+		fmt.Fprintf(&b, "\nYour previous reply was rejected because %v.", rejected)
+	}
+	instructions := b.String()
+
+	out := make([]models.Message, len(messages))
+	copy(out, messages)
+
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i].Role == "system" {
+			out[i].Content += "\n\n" + instructions
+			return out
+		}
+	}
+	return append([]models.Message{{Role: "system", Content: instructions}}, out...)
+}
@@ -0,0 +1,131 @@
+package adapters
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// Token counts are estimated rather than tokenized where the upstream does
+// not report them: about four characters per token, plus the framing each
+// message adds.
+const (
+	charsPerToken    = 4
+	tokensPerMessage = 4
+)
+
+func estimateTokens(chars int) int {
+	return (chars + charsPerToken - 1) / charsPerToken
+}
+
+// EstimatePromptTokens estimates how many tokens req's messages take up.
+func EstimatePromptTokens(req *models.ChatRequest) int {
+	chars := 0
+	for _, msg := range req.Messages {
+		chars += utf8.RuneCountInString(msg.Content) + utf8.RuneCountInString(msg.ReasoningContent)
+		for _, call := range msg.ToolCalls {
+			chars += utf8.RuneCountInString(call.Function.Name) + utf8.RuneCountInString(call.Function.Arguments)
+		}
+	}
+	return estimateTokens(chars) + tokensPerMessage*len(req.Messages)
+}
+
+// EstimateInputTokens estimates how many tokens inputs take up, e.g. for
+// embeddings.
+func EstimateInputTokens(inputs []string) int {
+	tokens := 0
+	for _, input := range inputs {
+		tokens += estimateTokens(utf8.RuneCountInString(input))
+	}
+	return tokens
+}
+
+// WithUsageHook returns an adapter that calls hook with the token usage of
+// every upstream call, estimated when the upstream does not report it.
+// Streams are reported once they end.
+func WithUsageHook(adapter Adapter, hook func(models.Usage)) Adapter {
+	return &usageAdapter{Adapter: adapter, hook: hook}
+}
+
+type usageAdapter struct {
+	Adapter
+	hook func(models.Usage)
+}
+
+func (a *usageAdapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.ChatResponse, error) {
+	resp, err := a.Adapter.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Usage != nil {
+		a.hook(*resp.Usage)
+		return resp, nil
+	}
+	chars := 0
+	for _, choice := range resp.Choices {
+		chars += utf8.RuneCountInString(choice.Message.Content) + utf8.RuneCountInString(choice.Message.ReasoningContent)
+	}
+	a.hook(estimatedUsage(req, chars))
+	return resp, nil
+}
+
+// ChatStream asks the upstream to report usage at the end of the stream and
+// falls back to an estimate when it does not. The usage chunk is only passed
+// on if the client asked for it too.
+func (a *usageAdapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamChunk, <-chan error) {
+	wantUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	upReq := *req
+	upReq.StreamOptions = &models.StreamOptions{IncludeUsage: true}
+
+	upChunks, upErrs := a.Adapter.ChatStream(ctx, &upReq)
+	chunks := make(chan models.StreamChunk, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		chars := 0
+		var reported *models.Usage
+		for chunk := range upChunks {
+			for _, choice := range chunk.Choices {
+				chars += utf8.RuneCountInString(choice.Delta.Content) + utf8.RuneCountInString(choice.Delta.ReasoningContent)
+			}
+			if chunk.Usage != nil {
+				reported = chunk.Usage
+				if !wantUsage {
+					chunk.Usage = nil
+					if len(chunk.Choices) == 0 {
+						continue
+					}
+				}
+			}
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				// Keep draining so the upstream can finish.
+			}
+		}
+		if reported != nil {
+			a.hook(*reported)
+		} else {
+			a.hook(estimatedUsage(req, chars))
+		}
+
+		if err := <-upErrs; err != nil {
+			errs <- err
+		}
+		close(errs)
+		close(chunks)
+	}()
+
+	return chunks, errs
+}
+
+func estimatedUsage(req *models.ChatRequest, completionChars int) models.Usage {
+	usage := models.Usage{
+		PromptTokens:     EstimatePromptTokens(req),
+		CompletionTokens: estimateTokens(completionChars),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
@@ -0,0 +1,71 @@
+package adapters
+
+import (
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// ApplyVirtualModel rewrites a request for a virtual model into one for the
+// underlying model: the system prompt goes first and the defaults fill in
+// sampling parameters the client left unset. The tool policy is applied by
+// the caller, which knows the backend.
+func ApplyVirtualModel(vm config.VirtualModelConfig, req *models.ChatRequest) {
+	req.Model = vm.Model
+
+	if vm.SystemPrompt != "" {
+		messages := make([]models.Message, 0, len(req.Messages)+1)
+		messages = append(messages, models.Message{Role: "system", Content: vm.SystemPrompt})
+		req.Messages = append(messages, req.Messages...)
+	}
+
+	d := vm.Defaults
+	if req.Temperature == nil && d.Temperature != nil {
+		req.Temperature = floatPtr(*d.Temperature)
+	}
+	if req.TopP == nil && d.TopP != nil {
+		req.TopP = floatPtr(*d.TopP)
+	}
+	if req.MaxTokens == nil && req.MaxCompletionTokens == nil && d.MaxTokens != nil {
+		req.MaxTokens = intPtr(*d.MaxTokens)
+	}
+	if req.Stop == nil && len(d.Stop) > 0 {
+		req.Stop = append(models.StopSequences(nil), d.Stop...)
+	}
+	if req.Seed == nil && d.Seed != nil {
+		req.Seed = intPtr(*d.Seed)
+	}
+	if req.PresencePenalty == nil && d.PresencePenalty != nil {
+		req.PresencePenalty = floatPtr(*d.PresencePenalty)
+	}
+	if req.FrequencyPenalty == nil && d.FrequencyPenalty != nil {
+		req.FrequencyPenalty = floatPtr(*d.FrequencyPenalty)
+	}
+}
+
+// DescribeVirtualModel builds the /v1/models entry for a virtual model. It
+// takes its limits and capabilities from the underlying model when that is
+// known.
+func DescribeVirtualModel(vm config.VirtualModelConfig, underlying *models.Model) models.Model {
+	m := models.Model{}
+	if underlying != nil {
+		m = *underlying
+		m.Aliases = nil
+	}
+	m.ID = vm.ID
+	m.Object = "model"
+	m.OwnedBy = "gateway"
+	m.Description = vm.Description
+	m.VirtualOf = vm.Model
+	if m.Created == 0 {
+		m.Created = started
+	}
+	return m
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func intPtr(v int) *int {
+	return &v
+}
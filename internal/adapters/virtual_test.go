@@ -0,0 +1,105 @@
+package adapters
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func TestApplyVirtualModel(t *testing.T) {
+	vm := config.VirtualModelConfig{
+		ID:           "team-reviewer",
+		Model:        "openai/gpt-4o",
+		SystemPrompt: "Review the code.",
+		Defaults: config.VirtualModelDefaults{
+			Temperature: floatPtr(0.2),
+			MaxTokens:   intPtr(500),
+			Stop:        []string{"END"},
+			Seed:        intPtr(7),
+		},
+	}
+	messages := []models.Message{{Role: "user", Content: "func f() {}"}}
+	wantMessages := []models.Message{{Role: "system", Content: "Review the code."}, {Role: "user", Content: "func f() {}"}}
+
+	tests := []struct {
+		name string
+		req  models.ChatRequest
+		want models.ChatRequest
+	}{
+		{
+			name: "defaults fill unset parameters",
+			req:  models.ChatRequest{Model: "team-reviewer", Messages: messages},
+			want: models.ChatRequest{Model: "openai/gpt-4o", Messages: wantMessages, Temperature: floatPtr(0.2), MaxTokens: intPtr(500), Stop: []string{"END"}, Seed: intPtr(7)},
+		},
+		{
+			name: "the client's parameters win",
+			req:  models.ChatRequest{Model: "team-reviewer", Messages: messages, Temperature: floatPtr(1), Stop: []string{"STOP"}, Seed: intPtr(1)},
+			want: models.ChatRequest{Model: "openai/gpt-4o", Messages: wantMessages, Temperature: floatPtr(1), MaxTokens: intPtr(500), Stop: []string{"STOP"}, Seed: intPtr(1)},
+		},
+		{
+			name: "max_completion_tokens counts as a token limit",
+			req:  models.ChatRequest{Model: "team-reviewer", Messages: messages, MaxCompletionTokens: intPtr(50)},
+			want: models.ChatRequest{Model: "openai/gpt-4o", Messages: wantMessages, Temperature: floatPtr(0.2), MaxCompletionTokens: intPtr(50), Stop: []string{"END"}, Seed: intPtr(7)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			ApplyVirtualModel(vm, &req)
+			if !reflect.DeepEqual(req, tt.want) {
+				t.Errorf("request = %+v, want %+v", req, tt.want)
+			}
+			if !reflect.DeepEqual(tt.req.Messages, messages) {
+				t.Errorf("the client's messages were changed to %+v", tt.req.Messages)
+			}
+		})
+	}
+
+	// Requests get copies, so one request cannot change the next one's
+	// defaults.
+	var req models.ChatRequest
+	ApplyVirtualModel(vm, &req)
+	*req.Temperature = 2
+	req.Stop[0] = "changed"
+	if *vm.Defaults.Temperature != 0.2 || vm.Defaults.Stop[0] != "END" {
+		t.Errorf("defaults = %+v, want them unchanged", vm.Defaults)
+	}
+}
+
+func TestDescribeVirtualModel(t *testing.T) {
+	vm := config.VirtualModelConfig{ID: "team-reviewer", Description: "Code review", Model: "gpt-4o"}
+	underlying := &models.Model{
+		ID:            "gpt-4o",
+		Created:       1700000000,
+		OwnedBy:       "openai",
+		Backend:       "openai",
+		Aliases:       []string{"4o"},
+		ContextWindow: 128000,
+		Pricing:       &models.ModelPricing{Input: 2.5, Output: 10},
+	}
+
+	got := DescribeVirtualModel(vm, underlying)
+	want := models.Model{
+		ID:            "team-reviewer",
+		Object:        "model",
+		Created:       1700000000,
+		OwnedBy:       "gateway",
+		Backend:       "openai",
+		ContextWindow: 128000,
+		Pricing:       &models.ModelPricing{Input: 2.5, Output: 10},
+		Description:   "Code review",
+		VirtualOf:     "gpt-4o",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with the underlying model = %+v, want %+v", got, want)
+	}
+
+	got = DescribeVirtualModel(vm, nil)
+	want = models.Model{ID: "team-reviewer", Object: "model", Created: started, OwnedBy: "gateway", Description: "Code review", VirtualOf: "gpt-4o"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("without the underlying model = %+v, want %+v", got, want)
+	}
+}
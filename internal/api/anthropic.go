@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func anthropicErrorFormatter(apiErr *models.APIError) interface{} {
+	return models.ToAnthropicError(apiErr)
+}
+
+// Messages implements the Anthropic Messages API on top of the chat
+// adapters.
+func (h *Handler) Messages(c *gin.Context) {
+	c.Set(errorFormatterKey, errorFormatter(anthropicErrorFormatter))
+
+	var req models.AnthropicRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Messages) == 0 {
+		writeError(c, models.ErrInvalidMessages())
+		return
+	}
+
+	// Anthropic reports which stop sequence ended the message, so the
+	// gateway applies them itself.
+	chatReq := models.ChatRequest{
+		Model:       req.Model,
+		Stream:      req.Stream,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.StopSequences,
+		MatchStop:   len(req.StopSequences) > 0,
+	}
+	if req.Stream {
+		chatReq.StreamOptions = &models.StreamOptions{IncludeUsage: true}
+	}
+	if req.MaxTokens > 0 {
+		maxTokens := req.MaxTokens
+		chatReq.MaxTokens = &maxTokens
+	}
+	if system := models.AnthropicText(req.System); system != "" {
+		chatReq.Messages = append(chatReq.Messages, models.Message{Role: "system", Content: system})
+	}
+	chatReq.Messages = append(chatReq.Messages, anthropicMessages(req.Messages)...)
+
+	requestedModel := req.Model
+	adapter, release, ok := h.dispatch(c, &chatReq)
+	if !ok {
+		return
+	}
+	defer release()
+
+	id := newID("msg_")
+
+	if req.Stream {
+		h.streamChat(c, adapter, &chatReq, &anthropicStreamWriter{id: id, model: requestedModel})
+		return
+	}
+
+	chatResp, err := adapter.Chat(c.Request.Context(), &chatReq)
+	if err != nil {
+		writeError(c, adapterError(err, adapter.ID(), chatReq.Model))
+		return
+	}
+
+	var text, finishReason, stopSequence string
+	if len(chatResp.Choices) > 0 {
+		text = chatResp.Choices[0].Message.Content
+		finishReason = chatResp.Choices[0].FinishReason
+		stopSequence = chatResp.Choices[0].StopSequence
+	}
+
+	stopReason := anthropicStopReason(finishReason, stopSequence)
+	resp := models.AnthropicResponse{
+		ID:         id,
+		Type:       "message",
+		Role:       "assistant",
+		Model:      requestedModel,
+		Content:    []models.AnthropicTextBlock{{Type: "text", Text: text}},
+		StopReason: &stopReason,
+	}
+	if stopSequence != "" {
+		resp.StopSequence = &stopSequence
+	}
+	if chatResp.Usage != nil {
+		resp.Usage = models.AnthropicUsage{
+			InputTokens:  chatResp.Usage.PromptTokens,
+			OutputTokens: chatResp.Usage.CompletionTokens,
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// anthropicMessages flattens Anthropic content blocks into chat messages.
+// Tool results become tool messages; other non-text blocks are dropped.
+func anthropicMessages(in []models.AnthropicMessage) []models.Message {
+	var out []models.Message
+	for _, msg := range in {
+		var blocks []models.AnthropicContentBlock
+		if err := json.Unmarshal(msg.Content, &blocks); err == nil {
+			for _, block := range blocks {
+				if block.Type == "tool_result" {
+					out = append(out, models.Message{
+						Role:       "tool",
+						Content:    models.AnthropicText(block.Content),
+						ToolCallID: block.ToolUseID,
+					})
+				}
+			}
+		}
+
+		if text := models.AnthropicText(msg.Content); text != "" {
+			out = append(out, models.Message{Role: msg.Role, Content: text})
+		}
+	}
+	return out
+}
+
+func anthropicStopReason(finishReason, stopSequence string) string {
+	if stopSequence != "" {
+		return "stop_sequence"
+	}
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls":
+		return "tool_use"
+	}
+	return "end_turn"
+}
+
+// anthropicStreamWriter turns chat chunks into Anthropic Messages stream
+// events with a single text content block.
+type anthropicStreamWriter struct {
+	id    string
+	model string
+
+	started      bool
+	failed       bool
+	finishReason string
+	stopSequence string
+	usage        models.AnthropicUsage
+}
+
+func (sw *anthropicStreamWriter) start(w io.Writer) {
+	if sw.started {
+		return
+	}
+	sw.started = true
+
+	zero := 0
+	writeSSE(w, "message_start", models.AnthropicStreamEvent{
+		Type: "message_start",
+		Message: &models.AnthropicResponse{
+			ID:      sw.id,
+			Type:    "message",
+			Role:    "assistant",
+			Model:   sw.model,
+			Content: []models.AnthropicTextBlock{},
+		},
+	})
+	writeSSE(w, "content_block_start", models.AnthropicStreamEvent{
+		Type:         "content_block_start",
+		Index:        &zero,
+		ContentBlock: &models.AnthropicTextBlock{Type: "text", Text: ""},
+	})
+	writeSSE(w, "ping", models.AnthropicStreamEvent{Type: "ping"})
+}
+
+func (sw *anthropicStreamWriter) Chunk(w io.Writer, chunk *models.StreamChunk) {
+	sw.start(w)
+
+	if chunk.Usage != nil {
+		sw.usage = models.AnthropicUsage{
+			InputTokens:  chunk.Usage.PromptTokens,
+			OutputTokens: chunk.Usage.CompletionTokens,
+		}
+	}
+
+	zero := 0
+	for _, choice := range chunk.Choices {
+		if choice.FinishReason != "" {
+			sw.finishReason = choice.FinishReason
+			sw.stopSequence = choice.StopSequence
+		}
+		if choice.Delta.Content == "" {
+			continue
+		}
+		writeSSE(w, "content_block_delta", models.AnthropicStreamEvent{
+			Type:  "content_block_delta",
+			Index: &zero,
+			Delta: &models.AnthropicStreamDelta{Type: "text_delta", Text: choice.Delta.Content},
+		})
+	}
+}
+
+func (sw *anthropicStreamWriter) Error(w io.Writer, apiErr *models.APIError) {
+	sw.failed = true
+	anthropicErr := models.ToAnthropicError(apiErr)
+	writeSSE(w, "error", models.AnthropicStreamEvent{Type: "error", Error: &anthropicErr.Error})
+}
+
+func (sw *anthropicStreamWriter) Done(w io.Writer) {
+	if sw.failed {
+		return
+	}
+	sw.start(w)
+
+	zero := 0
+	stopReason := anthropicStopReason(sw.finishReason, sw.stopSequence)
+	delta := &models.AnthropicStreamDelta{StopReason: &stopReason}
+	if sw.stopSequence != "" {
+		delta.StopSequence = &sw.stopSequence
+	}
+	writeSSE(w, "content_block_stop", models.AnthropicStreamEvent{Type: "content_block_stop", Index: &zero})
+	writeSSE(w, "message_delta", models.AnthropicStreamEvent{
+		Type:  "message_delta",
+		Delta: delta,
+		Usage: &sw.usage,
+	})
+	writeSSE(w, "message_stop", models.AnthropicStreamEvent{Type: "message_stop"})
+}
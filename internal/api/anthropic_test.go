@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func TestAnthropicMessages(t *testing.T) {
+	in := []models.AnthropicMessage{
+		{Role: "user", Content: json.RawMessage(`"What is in go.mod?"`)},
+		{Role: "assistant", Content: json.RawMessage(`[{"type":"text","text":"Let me read it."},{"type":"tool_use","id":"toolu_1"}]`)},
+		{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"module x"}]},{"type":"text","text":"Well?"}]`)},
+	}
+	want := []models.Message{
+		{Role: "user", Content: "What is in go.mod?"},
+		{Role: "assistant", Content: "Let me read it."},
+		{Role: "tool", Content: "module x", ToolCallID: "toolu_1"},
+		{Role: "user", Content: "Well?"},
+	}
+
+	if got := anthropicMessages(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %+v, want %+v", got, want)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		reply            models.Choice
+		wantStatus       int
+		wantText         string
+		wantStopReason   string
+		wantStopSequence string
+	}{
+		{
+			name:           "end of turn",
+			body:           `{"model":"m","max_tokens":100,"system":"Be brief.","messages":[{"role":"user","content":"hi"}]}`,
+			reply:          models.Choice{Message: models.Message{Content: "Hello"}, FinishReason: "stop"},
+			wantStatus:     200,
+			wantText:       "Hello",
+			wantStopReason: "end_turn",
+		},
+		{
+			name:           "max tokens",
+			body:           `{"model":"m","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}`,
+			reply:          models.Choice{Message: models.Message{Content: "Hel"}, FinishReason: "length"},
+			wantStatus:     200,
+			wantText:       "Hel",
+			wantStopReason: "max_tokens",
+		},
+		{
+			name:             "stop sequence",
+			body:             `{"model":"m","max_tokens":100,"stop_sequences":["STOP","END"],"messages":[{"role":"user","content":"hi"}]}`,
+			reply:            models.Choice{Message: models.Message{Content: "Hello END world STOP"}, FinishReason: "stop"},
+			wantStatus:       200,
+			wantText:         "Hello ",
+			wantStopReason:   "stop_sequence",
+			wantStopSequence: "END",
+		},
+		{
+			name:           "stop sequence not reached",
+			body:           `{"model":"m","max_tokens":100,"stop_sequences":["STOP"],"messages":[{"role":"user","content":"hi"}]}`,
+			reply:          models.Choice{Message: models.Message{Content: "Hello"}, FinishReason: "stop"},
+			wantStatus:     200,
+			wantText:       "Hello",
+			wantStopReason: "end_turn",
+		},
+		{
+			name:       "no messages",
+			body:       `{"model":"m","max_tokens":100,"messages":[]}`,
+			wantStatus: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{
+				id:     "fake",
+				models: []models.Model{{ID: "m"}},
+				reply: &models.ChatResponse{
+					Choices: []models.Choice{tt.reply},
+					Usage:   &models.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10},
+				},
+			}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			resp, body := post(t, srv, "/v1/messages", tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantStatus != 200 {
+				var anthropicErr models.AnthropicError
+				if err := json.Unmarshal([]byte(body), &anthropicErr); err != nil || anthropicErr.Type != "error" || anthropicErr.Error.Type != "invalid_request_error" {
+					t.Errorf("body = %s, want an Anthropic invalid_request_error", body)
+				}
+				return
+			}
+
+			var got models.AnthropicResponse
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Content) != 1 || got.Content[0].Text != tt.wantText {
+				t.Errorf("content = %+v, want %q", got.Content, tt.wantText)
+			}
+			if value(got.StopReason) != tt.wantStopReason {
+				t.Errorf("stop_reason = %v, want %s", got.StopReason, tt.wantStopReason)
+			}
+			if value(got.StopSequence) != tt.wantStopSequence {
+				t.Errorf("stop_sequence = %v, want %q", got.StopSequence, tt.wantStopSequence)
+			}
+			if got.Usage != (models.AnthropicUsage{InputTokens: 7, OutputTokens: 3}) {
+				t.Errorf("usage = %+v", got.Usage)
+			}
+		})
+	}
+}
+
+func TestMessagesStream(t *testing.T) {
+	usage := models.StreamChunk{Usage: &models.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}}
+
+	tests := []struct {
+		name             string
+		stops            string
+		chunks           []models.StreamChunk
+		wantText         string
+		wantStopReason   string
+		wantStopSequence string
+		wantUsage        models.AnthropicUsage
+	}{
+		{
+			name:           "end of turn",
+			stops:          `[]`,
+			chunks:         append(textChunks("stop", "Hel", "lo"), usage),
+			wantText:       "Hello",
+			wantStopReason: "end_turn",
+			wantUsage:      models.AnthropicUsage{InputTokens: 7, OutputTokens: 3},
+		},
+		{
+			name:           "max tokens",
+			stops:          `[]`,
+			chunks:         append(textChunks("length", "Hel"), usage),
+			wantText:       "Hel",
+			wantStopReason: "max_tokens",
+			wantUsage:      models.AnthropicUsage{InputTokens: 7, OutputTokens: 3},
+		},
+		{
+			name:           "stop sequence not reached",
+			stops:          `["END"]`,
+			chunks:         append(textChunks("stop", "Hel", "lo"), usage),
+			wantText:       "Hello",
+			wantStopReason: "end_turn",
+			wantUsage:      models.AnthropicUsage{InputTokens: 7, OutputTokens: 3},
+		},
+		{
+			name:             "stop sequence split across chunks",
+			stops:            `["END"]`,
+			chunks:           textChunks("stop", "Hello E", "ND world"),
+			wantText:         "Hello ",
+			wantStopReason:   "stop_sequence",
+			wantStopSequence: "END",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{id: "fake", models: []models.Model{{ID: "m"}}, chunks: tt.chunks}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			_, body := post(t, srv, "/v1/messages", `{"model":"m","max_tokens":100,"stream":true,"stop_sequences":`+tt.stops+`,"messages":[{"role":"user","content":"hi"}]}`)
+
+			// Runs of the same event, such as deltas, count once.
+			var types []string
+			var text string
+			var delta models.AnthropicStreamEvent
+			for _, data := range sseData(body) {
+				var event models.AnthropicStreamEvent
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatal(err)
+				}
+				if n := len(types); n == 0 || types[n-1] != event.Type {
+					types = append(types, event.Type)
+				}
+				switch event.Type {
+				case "content_block_delta":
+					text += event.Delta.Text
+				case "message_delta":
+					delta = event
+				}
+			}
+
+			wantTypes := []string{"message_start", "content_block_start", "ping", "content_block_delta", "content_block_stop", "message_delta", "message_stop"}
+			if !reflect.DeepEqual(types, wantTypes) {
+				t.Errorf("events = %v, want %v", types, wantTypes)
+			}
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if delta.Delta == nil || value(delta.Delta.StopReason) != tt.wantStopReason {
+				t.Fatalf("message_delta = %+v, want stop_reason %s", delta.Delta, tt.wantStopReason)
+			}
+			if got := value(delta.Delta.StopSequence); got != tt.wantStopSequence {
+				t.Errorf("stop_sequence = %q, want %q", got, tt.wantStopSequence)
+			}
+			if delta.Usage == nil || *delta.Usage != tt.wantUsage {
+				t.Errorf("usage = %+v, want %+v", delta.Usage, tt.wantUsage)
+			}
+		})
+	}
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/batch"
+	"github.com/kashifkhan/ai-gateway/internal/limiter"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// batchQueueRetry is how long a batch request waits before trying again
+// when the backend's wait queue is full.
+const batchQueueRetry = time.Second
+
+func (h *Handler) batchOwner(c *gin.Context) batch.Owner {
+	apiKey := c.GetString("api_key")
+	return batch.Owner{
+		KeyHash:    auth.HashKey(apiKey),
+		Caller:     auth.MaskKey(apiKey),
+		ToolPolicy: h.policies.KeyPolicy(apiKey),
+	}
+}
+
+func (h *Handler) UploadFile(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.batches.MaxFileSize()+1<<20)
+
+	purpose := c.PostForm("purpose")
+	if purpose != "batch" {
+		writeError(c, models.ErrInvalidInput("'purpose' must be 'batch'"))
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		writeError(c, models.ErrInvalidInput("Missing 'file' upload"))
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		writeError(c, models.ErrInvalidInput("Unreadable 'file' upload"))
+		return
+	}
+	defer src.Close()
+
+	file, err := h.batches.CreateFile(h.batchOwner(c), header.Filename, purpose, src)
+	if errors.Is(err, batch.ErrFileTooLarge) {
+		writeError(c, models.NewAPIError("File exceeds the maximum upload size", models.ErrorTypeInvalidRequest, models.ErrorCodeInvalidInput, 413))
+		return
+	}
+	if err != nil {
+		writeError(c, models.NewAPIError("Failed to store file", models.ErrorTypeService, models.ErrorCodeServiceUnavailable, 500))
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+func (h *Handler) ListFiles(c *gin.Context) {
+	owner := h.batchOwner(c)
+	c.JSON(http.StatusOK, models.FileList{
+		Object: "list",
+		Data:   h.batches.ListFiles(owner.KeyHash, c.Query("purpose")),
+	})
+}
+
+func (h *Handler) GetFile(c *gin.Context) {
+	file, err := h.batches.GetFile(h.batchOwner(c).KeyHash, c.Param("id"))
+	if err != nil {
+		writeError(c, models.ErrFileNotFound(c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+func (h *Handler) GetFileContent(c *gin.Context) {
+	path, err := h.batches.FilePath(h.batchOwner(c).KeyHash, c.Param("id"))
+	if err != nil {
+		writeError(c, models.ErrFileNotFound(c.Param("id")))
+		return
+	}
+	c.Header("Content-Type", "application/jsonl")
+	c.File(path)
+}
+
+func (h *Handler) DeleteFile(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.batches.DeleteFile(h.batchOwner(c).KeyHash, id); err != nil {
+		writeError(c, models.ErrFileNotFound(id))
+		return
+	}
+	c.JSON(http.StatusOK, models.FileDeleted{ID: id, Object: "file", Deleted: true})
+}
+
+func (h *Handler) CreateBatch(c *gin.Context) {
+	var req models.CreateBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, models.ErrInvalidInput("Invalid request body"))
+		return
+	}
+
+	b, err := h.batches.CreateBatch(h.batchOwner(c), &req)
+	switch {
+	case errors.Is(err, batch.ErrFileNotFound):
+		writeError(c, models.ErrFileNotFound(req.InputFileID))
+		return
+	case errors.Is(err, batch.ErrUnsupportedEndpoint):
+		writeError(c, models.ErrInvalidInput("'endpoint' must be '/v1/chat/completions'"))
+		return
+	case errors.Is(err, batch.ErrInvalidWindow):
+		writeError(c, models.ErrInvalidInput("'completion_window' must be '"+batch.CompletionWindow+"'"))
+		return
+	case errors.Is(err, batch.ErrInvalidPurpose):
+		writeError(c, models.ErrInvalidInput("The input file must be uploaded with purpose 'batch'"))
+		return
+	case err != nil:
+		writeError(c, models.NewAPIError("Failed to create batch", models.ErrorTypeService, models.ErrorCodeServiceUnavailable, 500))
+		return
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+func (h *Handler) ListBatches(c *gin.Context) {
+	limit := 20
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	batches, hasMore := h.batches.ListBatches(h.batchOwner(c).KeyHash, c.Query("after"), limit)
+	c.JSON(http.StatusOK, models.BatchList{
+		Object:  "list",
+		Data:    batches,
+		HasMore: hasMore,
+	})
+}
+
+func (h *Handler) GetBatch(c *gin.Context) {
+	b, err := h.batches.GetBatch(h.batchOwner(c).KeyHash, c.Param("id"))
+	if err != nil {
+		writeError(c, models.ErrBatchNotFound(c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+func (h *Handler) CancelBatch(c *gin.Context) {
+	b, err := h.batches.CancelBatch(h.batchOwner(c).KeyHash, c.Param("id"))
+	if errors.Is(err, batch.ErrBatchFinished) {
+		writeError(c, models.NewAPIError("Batch has already finished", models.ErrorTypeInvalidRequest, models.ErrorCodeInvalidInput, 409))
+		return
+	}
+	if err != nil {
+		writeError(c, models.ErrBatchNotFound(c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// runBatchRequest executes one batch line like a non-streaming chat request.
+// Batch work runs at the default priority and waits out full queues instead
+// of failing.
+func (h *Handler) runBatchRequest(ctx context.Context, owner *batch.Owner, req *models.ChatRequest) (*models.ChatResponse, *models.APIError) {
+	who := caller{
+		keyHash:   owner.KeyHash,
+		name:      owner.Caller,
+		keyPolicy: owner.ToolPolicy,
+	}
+	acquire := func(backend, model string) (func(), *models.APIError) {
+		for {
+			release, _, err := h.limiter.Acquire(ctx, backend, model, "")
+			if err == nil {
+				return release, nil
+			}
+			if !errors.Is(err, limiter.ErrQueueFull) && !errors.Is(err, limiter.ErrQueueTimeout) {
+				return nil, models.ErrBackend(err.Error())
+			}
+
+			select {
+			case <-time.After(batchQueueRetry):
+			case <-ctx.Done():
+				return nil, models.ErrBackend(ctx.Err().Error())
+			}
+		}
+	}
+
+	adapter, _, release, apiErr := h.prepare(req, who, acquire, nil)
+	if adapter == nil {
+		return nil, apiErr
+	}
+	defer release()
+
+	resp, err := adapter.Chat(ctx, req)
+	if err != nil {
+		return nil, adapterError(err, adapter.ID(), req.Model)
+	}
+	return resp, nil
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// Completions implements the legacy text completions API by sending the
+// prompt as a single user message.
+func (h *Handler) Completions(c *gin.Context) {
+	var req models.CompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, invalidRequest("Invalid request body"))
+		return
+	}
+
+	prompts, err := models.StringOrList(req.Prompt)
+	if err != nil || len(prompts) == 0 {
+		writeError(c, invalidRequest("'prompt' must be a string or a list of strings"))
+		return
+	}
+	if len(prompts) > 1 {
+		writeError(c, invalidRequest("Only a single prompt per request is supported"))
+		return
+	}
+	prompt := prompts[0]
+
+	stops, err := models.StringOrList(req.Stop)
+	if err != nil {
+		writeError(c, invalidRequest("'stop' must be a string or a list of strings"))
+		return
+	}
+
+	chatReq := models.ChatRequest{
+		Model:       req.Model,
+		Stream:      req.Stream,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Backend:     req.Backend,
+	}
+	if req.Suffix != "" {
+		chatReq.Messages = append(chatReq.Messages, models.Message{
+			Role:    "system",
+			Content: "Continue the user's text. Reply with only the continuation, which must lead naturally into this suffix:\n" + req.Suffix,
+		})
+	}
+	chatReq.Messages = append(chatReq.Messages, models.Message{Role: "user", Content: prompt})
+
+	requestedModel := req.Model
+	adapter, release, ok := h.dispatch(c, &chatReq)
+	if !ok {
+		return
+	}
+	defer release()
+
+	resp := &models.CompletionResponse{
+		ID:      newID("cmpl-"),
+		Object:  "text_completion",
+		Created: time.Now().Unix(),
+		Model:   requestedModel,
+	}
+	stop := adapters.NewStopMatcher(stops)
+
+	if req.Stream {
+		sw := &completionStreamWriter{resp: resp, stop: stop}
+		if req.Echo {
+			sw.echo = prompt
+		}
+		h.streamChat(c, adapter, &chatReq, sw)
+		return
+	}
+
+	chatResp, err := adapter.Chat(c.Request.Context(), &chatReq)
+	if err != nil {
+		writeError(c, adapterError(err, adapter.ID(), chatReq.Model))
+		return
+	}
+
+	var text, finishReason string
+	if len(chatResp.Choices) > 0 {
+		text = chatResp.Choices[0].Message.Content
+		finishReason = chatResp.Choices[0].FinishReason
+	}
+	if truncated, stopped := stop.Truncate(text); stopped {
+		text, finishReason = truncated, "stop"
+	}
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	if req.Echo {
+		text = prompt + text
+	}
+
+	resp.Choices = []models.CompletionChoice{{Text: text, FinishReason: &finishReason}}
+	resp.Usage = chatResp.Usage
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func invalidRequest(message string) *models.APIError {
+	return models.NewAPIError(message, models.ErrorTypeInvalidRequest, models.ErrorCodeInvalidMessages, 400)
+}
+
+// completionStreamWriter turns chat chunks into legacy text_completion
+// chunks, applying stop sequences on the gateway side.
+type completionStreamWriter struct {
+	resp *models.CompletionResponse
+	stop *adapters.StopMatcher
+	echo string
+
+	finishReason string
+}
+
+func (sw *completionStreamWriter) write(w io.Writer, text string, finishReason string) {
+	chunk := *sw.resp
+	choice := models.CompletionChoice{Text: text}
+	if finishReason != "" {
+		choice.FinishReason = &finishReason
+	}
+	chunk.Choices = []models.CompletionChoice{choice}
+	writeSSE(w, "", chunk)
+}
+
+func (sw *completionStreamWriter) Chunk(w io.Writer, chunk *models.StreamChunk) {
+	if sw.echo != "" {
+		sw.write(w, sw.echo, "")
+		sw.echo = ""
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.FinishReason != "" {
+			sw.finishReason = choice.FinishReason
+		}
+		if choice.Delta.Content == "" {
+			continue
+		}
+		if text, _ := sw.stop.Push(choice.Delta.Content); text != "" {
+			sw.write(w, text, "")
+		}
+	}
+}
+
+func (sw *completionStreamWriter) Error(w io.Writer, apiErr *models.APIError) {
+	writeSSE(w, "", apiErr)
+}
+
+func (sw *completionStreamWriter) Done(w io.Writer) {
+	if sw.echo != "" {
+		sw.write(w, sw.echo, "")
+	}
+
+	finishReason := sw.finishReason
+	if sw.stop.Stopped() || finishReason == "" {
+		finishReason = "stop"
+	}
+	sw.write(w, sw.stop.Flush(), finishReason)
+	fmt.Fprintf(w, "data: [DONE]\n\n")
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func TestCompletions(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		reply            models.Choice
+		wantStatus       int
+		wantText         string
+		wantFinishReason string
+		wantMessages     []models.Message
+	}{
+		{
+			name:             "prompt",
+			body:             `{"model":"m","prompt":"Once upon"}`,
+			reply:            models.Choice{Message: models.Message{Content: " a time"}, FinishReason: "length"},
+			wantStatus:       200,
+			wantText:         " a time",
+			wantFinishReason: "length",
+			wantMessages:     []models.Message{{Role: "user", Content: "Once upon"}},
+		},
+		{
+			name:             "list of one prompt with echo",
+			body:             `{"model":"m","prompt":["Once upon"],"echo":true}`,
+			reply:            models.Choice{Message: models.Message{Content: " a time"}},
+			wantStatus:       200,
+			wantText:         "Once upon a time",
+			wantFinishReason: "stop",
+		},
+		{
+			name:             "stop sequence",
+			body:             `{"model":"m","prompt":"Count:","stop":["4","3"]}`,
+			reply:            models.Choice{Message: models.Message{Content: " 1 2 3 4 5"}, FinishReason: "length"},
+			wantStatus:       200,
+			wantText:         " 1 2 ",
+			wantFinishReason: "stop",
+		},
+		{
+			name:             "suffix",
+			body:             `{"model":"m","prompt":"func add(a, b int) int {","suffix":"}"}`,
+			reply:            models.Choice{Message: models.Message{Content: " return a + b "}, FinishReason: "stop"},
+			wantStatus:       200,
+			wantText:         " return a + b ",
+			wantFinishReason: "stop",
+			wantMessages: []models.Message{
+				{Role: "system", Content: "Continue the user's text. Reply with only the continuation, which must lead naturally into this suffix:\n}"},
+				{Role: "user", Content: "func add(a, b int) int {"},
+			},
+		},
+		{name: "several prompts", body: `{"model":"m","prompt":["a","b"]}`, wantStatus: 400},
+		{name: "no prompt", body: `{"model":"m"}`, wantStatus: 400},
+		{name: "bad stop", body: `{"model":"m","prompt":"a","stop":3}`, wantStatus: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{
+				id:     "fake",
+				models: []models.Model{{ID: "m"}},
+				reply:  &models.ChatResponse{Choices: []models.Choice{tt.reply}},
+			}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			resp, body := post(t, srv, "/v1/completions", tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+
+			var got models.CompletionResponse
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Object != "text_completion" || got.Model != "m" || len(got.Choices) != 1 {
+				t.Fatalf("response = %s", body)
+			}
+			if got.Choices[0].Text != tt.wantText {
+				t.Errorf("text = %q, want %q", got.Choices[0].Text, tt.wantText)
+			}
+			if got.Choices[0].FinishReason == nil || *got.Choices[0].FinishReason != tt.wantFinishReason {
+				t.Errorf("finish_reason = %v, want %s", got.Choices[0].FinishReason, tt.wantFinishReason)
+			}
+			if tt.wantMessages != nil {
+				if got := backend.lastRequest().Messages; !reflect.DeepEqual(got, tt.wantMessages) {
+					t.Errorf("chat messages = %+v, want %+v", got, tt.wantMessages)
+				}
+			}
+		})
+	}
+}
+
+func TestCompletionsStream(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		chunks           []models.StreamChunk
+		wantText         string
+		wantFinishReason string
+	}{
+		{
+			name:             "plain",
+			body:             `{"model":"m","prompt":"Once upon","stream":true}`,
+			chunks:           textChunks("length", " a", " time"),
+			wantText:         " a time",
+			wantFinishReason: "length",
+		},
+		{
+			name:             "echo",
+			body:             `{"model":"m","prompt":"Once upon","stream":true,"echo":true}`,
+			chunks:           textChunks("stop", " a time"),
+			wantText:         "Once upon a time",
+			wantFinishReason: "stop",
+		},
+		{
+			name:             "stop sequence split across chunks",
+			body:             `{"model":"m","prompt":"Count:","stream":true,"stop":"END"}`,
+			chunks:           textChunks("length", " 1 2 E", "ND 3"),
+			wantText:         " 1 2 ",
+			wantFinishReason: "stop",
+		},
+		{
+			name:             "no finish reason",
+			body:             `{"model":"m","prompt":"Once upon","stream":true}`,
+			chunks:           textChunks("", " a time"),
+			wantText:         " a time",
+			wantFinishReason: "stop",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{id: "fake", models: []models.Model{{ID: "m"}}, chunks: tt.chunks}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			_, body := post(t, srv, "/v1/completions", tt.body)
+			data := sseData(body)
+			if len(data) == 0 || data[len(data)-1] != "[DONE]" {
+				t.Fatalf("stream = %s, want it to end with [DONE]", body)
+			}
+
+			var text strings.Builder
+			var finishReasons []string
+			for _, d := range data[:len(data)-1] {
+				var chunk models.CompletionResponse
+				if err := json.Unmarshal([]byte(d), &chunk); err != nil {
+					t.Fatal(err)
+				}
+				if chunk.Object != "text_completion" || len(chunk.Choices) != 1 {
+					t.Fatalf("chunk = %s", d)
+				}
+				text.WriteString(chunk.Choices[0].Text)
+				if chunk.Choices[0].FinishReason != nil {
+					finishReasons = append(finishReasons, *chunk.Choices[0].FinishReason)
+				}
+			}
+			if text.String() != tt.wantText {
+				t.Errorf("text = %q, want %q", text.String(), tt.wantText)
+			}
+			if len(finishReasons) != 1 || finishReasons[0] != tt.wantFinishReason {
+				t.Errorf("finish reasons = %v, want only %s", finishReasons, tt.wantFinishReason)
+			}
+		})
+	}
+}
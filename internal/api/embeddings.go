@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// Embeddings implements the OpenAI embeddings API for backends whose adapter
+// implements adapters.EmbeddingAdapter.
+func (h *Handler) Embeddings(c *gin.Context) {
+	var req models.EmbeddingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, models.ErrInvalidInput("Invalid request body"))
+		return
+	}
+
+	inputs, err := models.StringOrList(req.Input)
+	if err != nil || len(inputs) == 0 {
+		writeError(c, models.ErrInvalidInput("'input' must be a non-empty string or list of strings"))
+		return
+	}
+	for _, input := range inputs {
+		if input == "" {
+			writeError(c, models.ErrInvalidInput("'input' must not contain empty strings"))
+			return
+		}
+	}
+	req.Inputs = inputs
+
+	switch req.EncodingFormat {
+	case "", "float", "base64":
+	default:
+		writeError(c, models.ErrInvalidInput("'encoding_format' must be 'float' or 'base64'"))
+		return
+	}
+	if req.Dimensions != nil && *req.Dimensions <= 0 {
+		writeError(c, models.ErrInvalidInput("'dimensions' must be positive"))
+		return
+	}
+
+	requestedModel := req.Model
+	adapter, resolvedModel, done, ok := h.resolve(c, req.Model, req.Backend)
+	if !ok {
+		return
+	}
+	defer done()
+
+	embedder, ok := adapter.(adapters.EmbeddingAdapter)
+	if !ok {
+		writeError(c, models.ErrEmbeddingsNotSupported(requestedModel))
+		return
+	}
+	req.Model = resolvedModel
+
+	model, _ := adapters.FindModel(adapter, req.Model)
+	keyHash := auth.HashKey(c.GetString("api_key"))
+	if apiErr := h.checkBudget(model, keyHash); apiErr != nil {
+		writeError(c, apiErr)
+		return
+	}
+
+	release, ok := h.reserve(c, adapter, resolvedModel)
+	if !ok {
+		return
+	}
+	defer release()
+
+	resp, err := embedder.Embed(c.Request.Context(), &req)
+	if err != nil {
+		writeError(c, adapterError(err, adapter.ID(), req.Model))
+		return
+	}
+
+	if !model.Free && model.Pricing != nil {
+		tokens := resp.Usage.PromptTokens
+		if tokens == 0 {
+			tokens = adapters.EstimateInputTokens(req.Inputs)
+		}
+		setCost(c, h.charge(model, keyHash, models.Usage{PromptTokens: tokens, TotalTokens: tokens}))
+	}
+
+	for i := range resp.Data {
+		vector, _ := resp.Data[i].Embedding.([]float64)
+		if req.Dimensions != nil && len(vector) > *req.Dimensions {
+			vector = shortenEmbedding(vector, *req.Dimensions)
+		}
+		if req.EncodingFormat == "base64" {
+			resp.Data[i].Embedding = encodeEmbedding(vector)
+		} else {
+			resp.Data[i].Embedding = vector
+		}
+	}
+	resp.Model = requestedModel
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// shortenEmbedding truncates v to dims and rescales it to unit length, for
+// upstreams that ignore the dimensions parameter.
+func shortenEmbedding(v []float64, dims int) []float64 {
+	out := make([]float64, dims)
+	copy(out, v[:dims])
+
+	var norm float64
+	for _, x := range out {
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return out
+	}
+	for i := range out {
+		out[i] /= norm
+	}
+	return out
+}
+
+// encodeEmbedding packs v as little-endian float32 values in base64, the
+// same encoding OpenAI uses.
+func encodeEmbedding(v []float64) string {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(x)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func TestEmbeddings(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       []float64
+	}{
+		{name: "float", body: `{"model":"m","input":"hi"}`, wantStatus: 200, want: []float64{3, 4, 12}},
+		{name: "dimensions", body: `{"model":"m","input":"hi","dimensions":2}`, wantStatus: 200, want: []float64{0.6, 0.8}},
+		{name: "dimensions above the vector length", body: `{"model":"m","input":"hi","dimensions":8}`, wantStatus: 200, want: []float64{3, 4, 12}},
+		{name: "base64", body: `{"model":"m","input":"hi","encoding_format":"base64"}`, wantStatus: 200, want: []float64{3, 4, 12}},
+		{name: "base64 with dimensions", body: `{"model":"m","input":["hi"],"encoding_format":"base64","dimensions":2}`, wantStatus: 200, want: []float64{0.6, 0.8}},
+		{name: "unknown encoding", body: `{"model":"m","input":"hi","encoding_format":"int8"}`, wantStatus: 400},
+		{name: "zero dimensions", body: `{"model":"m","input":"hi","dimensions":0}`, wantStatus: 400},
+		{name: "empty input", body: `{"model":"m","input":[]}`, wantStatus: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{id: "fake", models: []models.Model{{ID: "m"}}, embedding: []float64{3, 4, 12}}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			resp, body := post(t, srv, "/v1/embeddings", tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+
+			var got struct {
+				Data []struct {
+					Embedding json.RawMessage `json:"embedding"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(body), &got); err != nil || len(got.Data) != 1 {
+				t.Fatalf("body = %s, want one embedding", body)
+			}
+
+			var vector []float64
+			var encoded string
+			isString := json.Unmarshal(got.Data[0].Embedding, &encoded) == nil
+			if isString != strings.Contains(tt.body, "base64") {
+				t.Fatalf("embedding = %s, want a base64 string only when asked for", got.Data[0].Embedding)
+			}
+			if isString {
+				raw, err := base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i+4 <= len(raw); i += 4 {
+					vector = append(vector, float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[i:]))))
+				}
+			} else if err := json.Unmarshal(got.Data[0].Embedding, &vector); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(vector) != len(tt.want) {
+				t.Fatalf("embedding = %v, want %v", vector, tt.want)
+			}
+			for i := range vector {
+				if math.Abs(vector[i]-tt.want[i]) > 1e-6 {
+					t.Fatalf("embedding = %v, want %v", vector, tt.want)
+				}
+			}
+		})
+	}
+}
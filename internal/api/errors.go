@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const errorFormatterKey = "error_formatter"
+
+// errorFormatter renders an API error for endpoints that speak a different
+// wire format than OpenAI.
+type errorFormatter func(apiErr *models.APIError) interface{}
+
+// writeError writes apiErr in the format of the current endpoint.
+func writeError(c *gin.Context, apiErr *models.APIError) {
+	if f, ok := c.Get(errorFormatterKey); ok {
+		if format, ok := f.(errorFormatter); ok {
+			c.JSON(apiErr.GetStatus(), format(apiErr))
+			return
+		}
+	}
+	c.JSON(apiErr.GetStatus(), apiErr)
+}
+
+// adapterError maps an error returned by an adapter to the OpenAI-style error
+// that clients receive.
+func adapterError(err error, backend, model string) *models.APIError {
+	var apiErr *models.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	switch {
+	case errors.Is(err, adapters.ErrInvalidRequest):
+		return models.NewAPIError(err.Error(), models.ErrorTypeInvalidRequest, models.ErrorCodeInvalidMessages, 400)
+	case errors.Is(err, adapters.ErrBackendTimeout):
+		return models.ErrBackendTimeout(backend)
+	case errors.Is(err, adapters.ErrContextLengthExceeded):
+		return models.ErrContextLengthExceeded(err.Error())
+	case errors.Is(err, adapters.ErrContentFiltered):
+		return models.ErrContentFiltered(err.Error())
+	case errors.Is(err, adapters.ErrUpstreamRateLimit):
+		return models.ErrUpstreamRateLimit(backend)
+	case errors.Is(err, adapters.ErrUpstreamAuth):
+		return models.ErrUpstreamAuth(backend)
+	case errors.Is(err, adapters.ErrModelNotFound):
+		return models.ErrModelNotFound(model)
+	case errors.Is(err, adapters.ErrToolNotAllowed):
+		return models.ErrToolNotAllowed(err.Error())
+	case errors.Is(err, adapters.ErrStructuredOutput):
+		return models.ErrStructuredOutput(err.Error())
+	}
+
+	return models.ErrBackend(err.Error())
+}
@@ -1,29 +1,65 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/batch"
+	"github.com/kashifkhan/ai-gateway/internal/budget"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/conversation"
+	"github.com/kashifkhan/ai-gateway/internal/limiter"
 	"github.com/kashifkhan/ai-gateway/internal/models"
+	"github.com/kashifkhan/ai-gateway/internal/routing"
+	"github.com/kashifkhan/ai-gateway/internal/sandbox"
 )
 
+// maxChoices caps n, since choices beyond what the backend produces natively
+// each cost a full upstream call.
+const maxChoices = 16
+
 type Handler struct {
-	registry  *adapters.Registry
-	startTime time.Time
-	version   string
+	registry      *adapters.Registry
+	limiter       *limiter.Limiter
+	policies      *sandbox.Policies
+	routes        *routing.Table
+	budget        *budget.Tracker
+	conversations *conversation.Store
+	batches       *batch.Manager
+	heartbeat     time.Duration
+	formatRetries int
+	paramPolicy   string
+	startTime     time.Time
+	version       string
 }
 
-func NewHandler(registry *adapters.Registry, version string) *Handler {
-	return &Handler{
-		registry:  registry,
-		startTime: time.Now(),
-		version:   version,
+func NewHandler(registry *adapters.Registry, limiter *limiter.Limiter, policies *sandbox.Policies, routes *routing.Table, budget *budget.Tracker, batches *batch.Manager, cfg *config.Config, version string) *Handler {
+	h := &Handler{
+		registry:      registry,
+		limiter:       limiter,
+		policies:      policies,
+		routes:        routes,
+		budget:        budget,
+		conversations: conversation.NewStore(cfg.Responses.StoreTTL, cfg.Responses.MaxStored),
+		batches:       batches,
+		heartbeat:     cfg.Server.HeartbeatInterval,
+		formatRetries: cfg.Structured.MaxRetries,
+		paramPolicy:   cfg.Sampling.UnsupportedParams,
+		startTime:     time.Now(),
+		version:       version,
+	}
+	if batches != nil {
+		batches.Start(h.runBatchRequest)
 	}
+	return h
 }
 
 func (h *Handler) Health(c *gin.Context) {
@@ -40,12 +76,17 @@ func (h *Handler) Health(c *gin.Context) {
 		Status:   "healthy",
 		Version:  h.version,
 		Backends: backends,
+		Queues:   h.limiter.Stats(),
 		Uptime:   int64(time.Since(h.startTime).Seconds()),
 	})
 }
 
+// ListModels lists every backend's models and the virtual models. With
+// ?include_aliases=true each alias is listed too, as an entry of the model
+// it resolves to.
 func (h *Handler) ListModels(c *gin.Context) {
 	var allModels []models.Model
+	includeAliases := c.Query("include_aliases") == "true"
 
 	for _, adapter := range h.registry.List() {
 		adapterModels, err := adapter.ListModels()
@@ -53,6 +94,12 @@ func (h *Handler) ListModels(c *gin.Context) {
 			continue
 		}
 		allModels = append(allModels, adapterModels...)
+		if includeAliases {
+			allModels = append(allModels, h.aliasEntries(adapter, adapterModels)...)
+		}
+	}
+	for _, vm := range h.registry.VirtualModels() {
+		allModels = append(allModels, h.describeVirtualModel(vm))
 	}
 
 	c.JSON(http.StatusOK, models.ModelsResponse{
@@ -61,6 +108,66 @@ func (h *Handler) ListModels(c *gin.Context) {
 	})
 }
 
+// aliasEntries lists the aliases of an adapter's models that actually
+// resolve to that adapter, skipping those another backend wins.
+func (h *Handler) aliasEntries(adapter adapters.Adapter, list []models.Model) []models.Model {
+	var entries []models.Model
+	for _, m := range list {
+		for _, alias := range m.Aliases {
+			if owner, _, err := h.registry.FindAdapterForModel(alias); err != nil || owner.ID() != adapter.ID() {
+				continue
+			}
+			entry := m
+			entry.ID = alias
+			entry.Aliases = nil
+			entry.AliasFor = m.ID
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (h *Handler) describeVirtualModel(vm config.VirtualModelConfig) models.Model {
+	_, target := h.route(routing.Request{Model: vm.Model}, "")
+	adapter, resolvedModel, err := h.registry.FindAdapterForModel(target)
+	if err != nil {
+		return adapters.DescribeVirtualModel(vm, nil)
+	}
+	underlying, ok := adapters.FindModel(adapter, resolvedModel)
+	if !ok {
+		return adapters.DescribeVirtualModel(vm, nil)
+	}
+	return adapters.DescribeVirtualModel(vm, &underlying)
+}
+
+// GetModel describes one model. IDs may contain slashes, as in
+// "backend/model" or OpenCode's "provider/model".
+func (h *Handler) GetModel(c *gin.Context) {
+	id := strings.TrimPrefix(c.Param("id"), "/")
+
+	if vm, ok := h.registry.VirtualModel(id); ok {
+		c.JSON(http.StatusOK, h.describeVirtualModel(vm))
+		return
+	}
+
+	adapter, resolvedModel, done, err := h.registry.Lease(id)
+	if err != nil {
+		writeError(c, models.ErrModelNotFound(id))
+		return
+	}
+	defer done()
+
+	model, ok := adapters.FindModel(adapter, resolvedModel)
+	if !ok {
+		model, ok = adapters.FindModel(adapter, id)
+	}
+	if !ok {
+		writeError(c, models.ErrModelNotFound(id))
+		return
+	}
+	c.JSON(http.StatusOK, model)
+}
+
 func (h *Handler) ListBackends(c *gin.Context) {
 	var backends []models.Backend
 
@@ -107,43 +214,270 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 		return
 	}
 
-	model := req.Model
-	if req.Backend != "" {
-		model = req.Backend + "/" + req.Model
+	adapter, release, ok := h.dispatch(c, &req)
+	if !ok {
+		return
+	}
+	defer release()
+
+	if req.Stream {
+		h.handleStreamingChat(c, adapter, &req)
+	} else {
+		h.handleNonStreamingChat(c, adapter, &req)
+	}
+}
+
+// applySampling checks req's sampling parameters against adapter. Parameters
+// that the backend cannot honour and the gateway cannot emulate are rejected
+// under the strict policy, or dropped and returned under the lenient one.
+// The returned adapter emulates stop and n where needed.
+func (h *Handler) applySampling(adapter adapters.Adapter, req *models.ChatRequest) (adapters.Adapter, []string, *models.APIError) {
+	if req.MaxTokens == nil {
+		req.MaxTokens = req.MaxCompletionTokens
+	}
+	req.MaxCompletionTokens = nil
+
+	var unsupported []string
+	for _, param := range req.SamplingParams() {
+		switch param {
+		case models.ParamStop, models.ParamN:
+			// Emulated when the backend lacks them.
+			continue
+		case models.ParamUser:
+			// Only an end-user identifier; dropping it changes no output.
+			if !adapter.SupportsParameter(param) {
+				req.ClearParam(param)
+			}
+			continue
+		}
+		if !adapter.SupportsParameter(param) {
+			unsupported = append(unsupported, param)
+		}
 	}
 
-	adapter, resolvedModel, err := h.registry.FindAdapterForModel(model)
+	if len(unsupported) > 0 {
+		if h.paramPolicy == config.ParamPolicyStrict {
+			return nil, nil, models.ErrUnsupportedParameters(unsupported, adapter.ID())
+		}
+		for _, param := range unsupported {
+			req.ClearParam(param)
+		}
+	}
+
+	return adapters.WithSamplingEmulation(adapter, req), unsupported, nil
+}
+
+// dispatch prepares req for the calling API key. When it returns false the
+// error response has already been written; otherwise release must be called
+// when done.
+func (h *Handler) dispatch(c *gin.Context, req *models.ChatRequest) (adapters.Adapter, func(), bool) {
+	apiKey := c.GetString("api_key")
+	who := caller{
+		keyHash:   auth.HashKey(apiKey),
+		name:      auth.MaskKey(apiKey),
+		keyPolicy: h.policies.KeyPolicy(apiKey),
+		header:    c.Request.Header,
+	}
+	acquire := func(backend, model string) (func(), *models.APIError) {
+		release, retryAfter, err := h.limiter.Acquire(c.Request.Context(), backend, model, apiKey)
+		if err != nil {
+			return nil, limiterError(c, backend, retryAfter, err)
+		}
+		return release, nil
+	}
+
+	// Streams are charged when they end, after the headers are gone, so
+	// streamChat sends their cost in a trailer.
+	report := func(cost float64) { setCost(c, cost) }
+	if req.Stream {
+		report = func(cost float64) { c.Set(streamCostKey, cost) }
+	}
+
+	adapter, ignored, release, apiErr := h.prepare(req, who, acquire, report)
+	if adapter == nil {
+		if apiErr != nil {
+			writeError(c, apiErr)
+		} else {
+			c.Abort()
+		}
+		return nil, nil, false
+	}
+	if len(ignored) > 0 {
+		c.Header("X-Gateway-Ignored-Params", strings.Join(ignored, ", "))
+	}
+	return adapter, release, true
+}
+
+// caller is who a chat request runs for: an API key, known by its hash,
+// with the headers of the live request. Batch jobs have no headers.
+type caller struct {
+	keyHash   string
+	name      string
+	keyPolicy *models.ToolPolicy
+	header    http.Header
+}
+
+// acquireFunc waits for capacity for model on backend. A nil release with a
+// nil error means the caller went away.
+type acquireFunc func(backend, model string) (func(), *models.APIError)
+
+// prepare is the request pipeline shared by live requests and batch jobs.
+// It checks req, applies a virtual model, finds and leases the adapter, sets
+// the tool policy, checks the model's limits and the caller's budget and
+// waits for capacity. The returned adapter charges the caller, passing the
+// running cost to report unless it is nil, and handles the response format and the
+// sampling parameters; ignored lists the parameters it dropped. A nil
+// adapter means the request cannot run; otherwise release must be called
+// when done.
+func (h *Handler) prepare(req *models.ChatRequest, who caller, acquire acquireFunc, report func(cost float64)) (adapter adapters.Adapter, ignored []string, release func(), apiErr *models.APIError) {
+	if _, err := adapters.CompileResponseFormat(req.ResponseFormat); err != nil {
+		return nil, nil, nil, models.ErrInvalidResponseFormat(err.Error())
+	}
+	if req.N != nil && (*req.N < 1 || *req.N > maxChoices) {
+		return nil, nil, nil, invalidRequest(fmt.Sprintf("'n' must be between 1 and %d", maxChoices))
+	}
+
+	vm, virtual := h.registry.VirtualModel(req.Model)
+	if virtual {
+		adapters.ApplyVirtualModel(vm, req)
+	}
+
+	adapter, resolvedModel, done, apiErr := h.lookup(routing.Request{Model: req.Model, Header: who.header, KeyHash: who.keyHash}, req.Backend)
+	if apiErr != nil {
+		return nil, nil, nil, apiErr
+	}
+
+	req.Model = resolvedModel
+	req.ToolPolicy = h.policies.Restrict(adapter.ID(), who.keyPolicy)
+	if virtual {
+		req.ToolPolicy = sandbox.Narrow(req.ToolPolicy, vm.Tools)
+	}
+	req.Caller = who.name
+
+	model, _ := adapters.FindModel(adapter, req.Model)
+	apiErr = checkModelLimits(model, req)
+	if apiErr == nil {
+		apiErr = h.checkBudget(model, who.keyHash)
+	}
+	if apiErr != nil {
+		done()
+		return nil, nil, nil, apiErr
+	}
+
+	reserved, apiErr := acquire(adapter.ID(), resolvedModel)
+	if reserved == nil {
+		done()
+		return nil, nil, nil, apiErr
+	}
+	release = func() {
+		reserved()
+		done()
+	}
+
+	adapter = h.meter(report, adapter, model, who.keyHash)
+	adapter = adapters.WithResponseFormat(adapter, req, h.formatRetries)
+	adapter, ignored, apiErr = h.applySampling(adapter, req)
+	if apiErr != nil {
+		release()
+		return nil, nil, nil, apiErr
+	}
+	return adapter, ignored, release, nil
+}
+
+// resolve finds a healthy adapter for model, optionally pinned to backend,
+// and returns it with the backend's own model ID. The adapter is leased
+// until done is called.
+func (h *Handler) resolve(c *gin.Context, model, backend string) (adapters.Adapter, string, func(), bool) {
+	adapter, resolvedModel, done, apiErr := h.lookup(routeRequest(c, model), backend)
+	if apiErr != nil {
+		writeError(c, apiErr)
+		return nil, "", nil, false
+	}
+	return adapter, resolvedModel, done, true
+}
+
+func routeRequest(c *gin.Context, model string) routing.Request {
+	return routing.Request{
+		Model:   model,
+		Header:  c.Request.Header,
+		KeyHash: auth.HashKey(c.GetString("api_key")),
+	}
+}
+
+func (h *Handler) lookup(req routing.Request, backend string) (adapters.Adapter, string, func(), *models.APIError) {
+	_, model := h.route(req, backend)
+
+	adapter, resolvedModel, done, err := h.registry.Lease(model)
 	if err != nil {
-		apiErr := models.ErrInvalidModel(req.Model)
-		c.JSON(apiErr.GetStatus(), apiErr)
-		return
+		return nil, "", nil, models.ErrInvalidModel(req.Model)
 	}
 
 	if !adapter.IsHealthy() {
-		apiErr := models.ErrBackendUnavailable(adapter.ID())
-		c.JSON(apiErr.GetStatus(), apiErr)
-		return
+		done()
+		return nil, "", nil, models.ErrBackendUnavailable(adapter.ID())
 	}
 
-	req.Model = resolvedModel
+	return adapter, resolvedModel, done, nil
+}
 
-	if req.Stream {
-		h.handleStreamingChat(c, adapter, &req)
-	} else {
-		h.handleNonStreamingChat(c, adapter, &req)
+// route applies the routing table and returns the matching route, if any,
+// and the model to look up in the registry. Requests that name a backend
+// bypass the table.
+func (h *Handler) route(req routing.Request, backend string) (*models.Route, string) {
+	if backend != "" {
+		return nil, backend + "/" + req.Model
+	}
+	route, target, model, ok := h.routes.Route(req)
+	if !ok {
+		return nil, req.Model
+	}
+	if target != "" {
+		model = target + "/" + model
+	}
+	return route, model
+}
+
+// reserve waits for a concurrency slot on the adapter's backend.
+func (h *Handler) reserve(c *gin.Context, adapter adapters.Adapter, model string) (func(), bool) {
+	release, retryAfter, err := h.limiter.Acquire(c.Request.Context(), adapter.ID(), model, c.GetString("api_key"))
+	if err != nil {
+		if apiErr := limiterError(c, adapter.ID(), retryAfter, err); apiErr != nil {
+			writeError(c, apiErr)
+		} else {
+			c.Abort()
+		}
+		return nil, false
+	}
+	return release, true
+}
+
+// limiterError turns a failed limiter.Acquire into an API error and sets
+// the Retry-After header. It is nil when the client went away while queued,
+// since there is nobody to answer.
+func limiterError(c *gin.Context, backend string, retryAfter time.Duration, err error) *models.APIError {
+	var apiErr *models.APIError
+	switch {
+	case errors.Is(err, limiter.ErrQueueFull):
+		apiErr = models.ErrQueueFull(backend)
+	case errors.Is(err, limiter.ErrQueueTimeout):
+		apiErr = models.ErrQueueTimeout(backend)
+	default:
+		return nil
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
 	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	return apiErr
 }
 
 func (h *Handler) handleNonStreamingChat(c *gin.Context, adapter adapters.Adapter, req *models.ChatRequest) {
 	resp, err := adapter.Chat(c.Request.Context(), req)
 	if err != nil {
-		apiErr := models.NewAPIError(
-			err.Error(),
-			models.ErrorTypeBackend,
-			models.ErrorCodeBackendUnavailable,
-			500,
-		)
-		c.JSON(apiErr.GetStatus(), apiErr)
+		apiErr := adapterError(err, adapter.ID(), req.Model)
+		writeError(c, apiErr)
 		return
 	}
 
@@ -151,42 +485,5 @@ func (h *Handler) handleNonStreamingChat(c *gin.Context, adapter adapters.Adapte
 }
 
 func (h *Handler) handleStreamingChat(c *gin.Context, adapter adapters.Adapter, req *models.ChatRequest) {
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Transfer-Encoding", "chunked")
-
-	chunks, errs := adapter.ChatStream(c.Request.Context(), req)
-
-	c.Stream(func(w io.Writer) bool {
-		select {
-		case chunk, ok := <-chunks:
-			if !ok {
-				fmt.Fprintf(w, "data: [DONE]\n\n")
-				return false
-			}
-
-			data, _ := json.Marshal(chunk)
-			fmt.Fprintf(w, "data: %s\n\n", string(data))
-			c.Writer.Flush()
-			return true
-
-		case err, ok := <-errs:
-			if ok && err != nil {
-				errData := map[string]interface{}{
-					"error": map[string]interface{}{
-						"message": err.Error(),
-						"type":    "backend_error",
-					},
-				}
-				data, _ := json.Marshal(errData)
-				fmt.Fprintf(w, "data: %s\n\n", string(data))
-			}
-			fmt.Fprintf(w, "data: [DONE]\n\n")
-			return false
-
-		case <-c.Request.Context().Done():
-			return false
-		}
-	})
+	h.streamChat(c, adapter, req, chatStreamWriter{})
 }
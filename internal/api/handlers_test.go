@@ -0,0 +1,359 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/budget"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/limiter"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+	"github.com/kashifkhan/ai-gateway/internal/routing"
+	"github.com/kashifkhan/ai-gateway/internal/sandbox"
+)
+
+// fakeAdapter is a backend whose answers are scripted by the test. Chat
+// returns reply or err; ChatStream sends chunks, gap apart, and then err;
+// Embed returns embedding for every input.
+type fakeAdapter struct {
+	adapters.BaseAdapter
+	id          string
+	models      []models.Model
+	unsupported []string
+
+	reply  *models.ChatResponse
+	chunks []models.StreamChunk
+	gap    time.Duration
+	err    error
+
+	embedding []float64
+
+	mu       sync.Mutex
+	requests []models.ChatRequest
+}
+
+func (a *fakeAdapter) ID() string                              { return a.id }
+func (a *fakeAdapter) Name() string                            { return a.id }
+func (a *fakeAdapter) Initialize(map[string]interface{}) error { return nil }
+func (a *fakeAdapter) Shutdown() error                         { return nil }
+func (a *fakeAdapter) HealthCheck() error                      { return nil }
+func (a *fakeAdapter) IsHealthy() bool                         { return true }
+func (a *fakeAdapter) SupportsStreaming() bool                 { return true }
+func (a *fakeAdapter) ResolveModel(modelID string) string      { return modelID }
+func (a *fakeAdapter) ListModels() ([]models.Model, error)     { return a.models, nil }
+
+func (a *fakeAdapter) SupportsModel(modelID string) bool {
+	for _, m := range a.models {
+		if m.ID == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *fakeAdapter) SupportsParameter(name string) bool {
+	for _, param := range a.unsupported {
+		if param == name {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *fakeAdapter) record(req *models.ChatRequest) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = append(a.requests, *req)
+}
+
+// lastRequest is the last request the adapter received.
+func (a *fakeAdapter) lastRequest() models.ChatRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.requests) == 0 {
+		return models.ChatRequest{}
+	}
+	return a.requests[len(a.requests)-1]
+}
+
+func (a *fakeAdapter) Chat(ctx context.Context, req *models.ChatRequest) (*models.ChatResponse, error) {
+	a.record(req)
+	if a.err != nil {
+		return nil, a.err
+	}
+	resp := *a.reply
+	return &resp, nil
+}
+
+func (a *fakeAdapter) ChatStream(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamChunk, <-chan error) {
+	a.record(req)
+	chunks := make(chan models.StreamChunk)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+
+		for _, chunk := range a.chunks {
+			select {
+			case <-time.After(a.gap):
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if a.err != nil {
+			errs <- a.err
+		}
+	}()
+	return chunks, errs
+}
+
+func (a *fakeAdapter) Embed(ctx context.Context, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	return adapters.EmbedBatches(ctx, req.Inputs, 0, func(ctx context.Context, batch []string) ([][]float64, int, error) {
+		vectors := make([][]float64, len(batch))
+		for i := range vectors {
+			vectors[i] = append([]float64(nil), a.embedding...)
+		}
+		return vectors, len(batch), nil
+	})
+}
+
+// newTestServer serves the API over the given backends, the first of which
+// is the default. Authentication is on when cfg lists keys.
+func newTestServer(t *testing.T, cfg *config.Config, backends ...*fakeAdapter) *httptest.Server {
+	t.Helper()
+
+	registry := adapters.NewRegistry(backends[0].id)
+	for _, backend := range backends {
+		registry.Register(backend)
+	}
+	registry.SetVirtualModels(cfg.VirtualModels)
+
+	tracker, err := budget.New(cfg.Budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+
+	router := SetupRouter(
+		registry,
+		auth.NewAuthenticator(cfg.Auth.Keys, len(cfg.Auth.Keys) > 0),
+		auth.NewRateLimiter(false, 0),
+		limiter.New(cfg.Backends, nil),
+		sandbox.New(cfg.Backends, nil),
+		routing.New(cfg.Routes),
+		tracker,
+		nil,
+		cfg,
+		"test",
+	)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// post sends body to path and returns the response with its body read.
+func post(t *testing.T, srv *httptest.Server, path, body string) (*http.Response, string) {
+	t.Helper()
+	return postAs(t, srv, "", path, body)
+}
+
+// postAs is post with an API key.
+func postAs(t *testing.T, srv *httptest.Server, key, path, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest("POST", srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// get fetches path and returns the response with its body read.
+func get(t *testing.T, srv *httptest.Server, path string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// textChunks is a stream that sends each of texts as a content delta and
+// then, unless finishReason is empty, a chunk that finishes with it.
+func textChunks(finishReason string, texts ...string) []models.StreamChunk {
+	var chunks []models.StreamChunk
+	for _, text := range texts {
+		chunks = append(chunks, models.StreamChunk{
+			Object:  "chat.completion.chunk",
+			Choices: []models.ChunkChoice{{Delta: models.Delta{Content: text}}},
+		})
+	}
+	if finishReason == "" {
+		return chunks
+	}
+	return append(chunks, models.StreamChunk{
+		Object:  "chat.completion.chunk",
+		Choices: []models.ChunkChoice{{FinishReason: finishReason}},
+	})
+}
+
+// sseData lists the data payloads of an SSE body.
+func sseData(body string) []string {
+	var data []string
+	for _, line := range strings.Split(body, "\n") {
+		if payload, ok := strings.CutPrefix(line, "data: "); ok {
+			data = append(data, payload)
+		}
+	}
+	return data
+}
+
+// metadataModel is a model with everything /v1/models can describe.
+var metadataModel = models.Model{
+	ID:              "m",
+	Object:          "model",
+	Created:         1700000000,
+	OwnedBy:         "fake",
+	Backend:         "fake",
+	Aliases:         []string{"em"},
+	ContextWindow:   128000,
+	MaxOutputTokens: 4096,
+	Capabilities:    &models.ModelCapabilities{Tools: true, Streaming: true},
+	Pricing:         &models.ModelPricing{Input: 2.5, Output: 10},
+	Deprecated:      true,
+	DeprecationDate: "2026-01-01",
+	ReplacedBy:      "m2",
+}
+
+func TestListModels(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []models.Model
+	}{
+		{name: "models", want: []models.Model{metadataModel}},
+		{
+			name:  "with aliases",
+			query: "?include_aliases=true",
+			want: func() []models.Model {
+				alias := metadataModel
+				alias.ID, alias.Aliases, alias.AliasFor = "em", nil, "m"
+				return []models.Model{metadataModel, alias}
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{id: "fake", models: []models.Model{metadataModel}}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			resp, body := get(t, srv, "/v1/models"+tt.query)
+			if resp.StatusCode != 200 {
+				t.Fatalf("status = %d: %s", resp.StatusCode, body)
+			}
+			var got models.ModelsResponse
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Data, tt.want) {
+				t.Errorf("models = %+v, want %+v", got.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "id", id: "m", wantStatus: 200},
+		{name: "alias", id: "em", wantStatus: 200},
+		{name: "backend prefix", id: "fake/m", wantStatus: 200},
+		{name: "unknown", id: "nope", wantStatus: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{id: "fake", models: []models.Model{metadataModel}}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			resp, body := get(t, srv, "/v1/models/"+tt.id)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+			var got models.Model
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, metadataModel) {
+				t.Errorf("model = %+v, want %+v", got, metadataModel)
+			}
+		})
+	}
+}
+
+func TestChatVirtualModel(t *testing.T) {
+	temperature := 0.2
+	cfg := &config.Config{VirtualModels: []config.VirtualModelConfig{{
+		ID:           "team-reviewer",
+		Model:        "m",
+		SystemPrompt: "Review the code.",
+		Defaults:     config.VirtualModelDefaults{Temperature: &temperature},
+	}}}
+	backend := &fakeAdapter{
+		id:     "fake",
+		models: []models.Model{{ID: "m"}},
+		reply:  &models.ChatResponse{Choices: []models.Choice{{Message: models.Message{Content: "LGTM"}}}},
+	}
+	srv := newTestServer(t, cfg, backend)
+
+	resp, body := post(t, srv, "/v1/chat/completions", `{"model":"team-reviewer","messages":[{"role":"user","content":"func f() {}"}]}`)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d: %s", resp.StatusCode, body)
+	}
+
+	req := backend.lastRequest()
+	wantMessages := []models.Message{{Role: "system", Content: "Review the code."}, {Role: "user", Content: "func f() {}"}}
+	if req.Model != "m" || !reflect.DeepEqual(req.Messages, wantMessages) || req.Temperature == nil || *req.Temperature != 0.2 {
+		t.Errorf("backend request = %+v, want m with the system prompt and temperature 0.2", req)
+	}
+}
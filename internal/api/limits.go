@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/budget"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// checkModelLimits rejects a request that cannot fit the model's context
+// window, or that asks for more output than the model can produce, before it
+// waits for a slot on the backend. Models without known limits pass.
+func checkModelLimits(model models.Model, req *models.ChatRequest) *models.APIError {
+	maxTokens := 0
+	if req.MaxTokens != nil {
+		maxTokens = *req.MaxTokens
+	} else if req.MaxCompletionTokens != nil {
+		maxTokens = *req.MaxCompletionTokens
+	}
+
+	if model.MaxOutputTokens > 0 && maxTokens > model.MaxOutputTokens {
+		return invalidRequest(fmt.Sprintf("max_tokens is %d, but model '%s' produces at most %d tokens", maxTokens, model.ID, model.MaxOutputTokens))
+	}
+
+	if model.ContextWindow > 0 {
+		prompt := adapters.EstimatePromptTokens(req)
+		if prompt+maxTokens > model.ContextWindow {
+			return models.ErrContextLengthExceeded(fmt.Sprintf(
+				"This model's maximum context length is %d tokens. However, you requested about %d tokens (%d in the messages, %d in the completion). Please reduce the length of the messages or completion.",
+				model.ContextWindow, prompt+maxTokens, prompt, maxTokens))
+		}
+	}
+	return nil
+}
+
+// checkBudget rejects requests for paid models once the global budget or
+// the caller's own is spent. Models the backend does not describe count as
+// paid.
+func (h *Handler) checkBudget(model models.Model, keyHash string) *models.APIError {
+	if model.Free {
+		return nil
+	}
+	if scope := h.budget.Check(keyHash); scope != "" {
+		return models.ErrBudgetExceeded(scope)
+	}
+	return nil
+}
+
+// meter charges what the model's calls cost to keyHash. When report is not
+// nil it gets the running total, in US dollars, after every call.
+func (h *Handler) meter(report func(cost float64), adapter adapters.Adapter, model models.Model, keyHash string) adapters.Adapter {
+	if model.Free || model.Pricing == nil {
+		return adapter
+	}
+
+	var mu sync.Mutex
+	total := 0.0
+	return adapters.WithUsageHook(adapter, func(usage models.Usage) {
+		cost := h.charge(model, keyHash, usage)
+
+		mu.Lock()
+		defer mu.Unlock()
+		total += cost
+		if report != nil {
+			report(total)
+		}
+	})
+}
+
+// charge records what usage of model costs keyHash and returns the cost.
+// Free models and models without pricing cost nothing.
+func (h *Handler) charge(model models.Model, keyHash string, usage models.Usage) float64 {
+	if model.Free || model.Pricing == nil {
+		return 0
+	}
+	cost := budget.Cost(model.Pricing, usage)
+	h.budget.Record(keyHash, cost)
+	return cost
+}
+
+const costHeader = "X-Gateway-Cost"
+
+// streamCostKey holds the cost of a stream until streamChat can send it.
+const streamCostKey = "stream_cost"
+
+func setCost(c *gin.Context, cost float64) {
+	c.Header(costHeader, strconv.FormatFloat(cost, 'f', 6, 64))
+}
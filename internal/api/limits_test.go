@@ -0,0 +1,101 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func TestCheckModelLimits(t *testing.T) {
+	// 36 characters and the message framing come to about 13 tokens.
+	messages := []models.Message{{Role: "user", Content: strings.Repeat("x", 36)}}
+	intPtr := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		model    models.Model
+		req      models.ChatRequest
+		wantCode string
+	}{
+		{name: "no limits", model: models.Model{ID: "m"}, req: models.ChatRequest{Messages: messages, MaxTokens: intPtr(1 << 20)}},
+		{name: "within the output limit", model: models.Model{ID: "m", MaxOutputTokens: 100}, req: models.ChatRequest{Messages: messages, MaxTokens: intPtr(100)}},
+		{name: "over the output limit", model: models.Model{ID: "m", MaxOutputTokens: 100}, req: models.ChatRequest{Messages: messages, MaxTokens: intPtr(101)}, wantCode: models.ErrorCodeInvalidMessages},
+		{name: "max_completion_tokens over the output limit", model: models.Model{ID: "m", MaxOutputTokens: 100}, req: models.ChatRequest{Messages: messages, MaxCompletionTokens: intPtr(101)}, wantCode: models.ErrorCodeInvalidMessages},
+		{name: "fills the context window", model: models.Model{ID: "m", ContextWindow: 50}, req: models.ChatRequest{Messages: messages, MaxTokens: intPtr(37)}},
+		{name: "over the context window", model: models.Model{ID: "m", ContextWindow: 50}, req: models.ChatRequest{Messages: messages, MaxTokens: intPtr(38)}, wantCode: models.ErrorCodeContextLength},
+		{name: "prompt alone over the context window", model: models.Model{ID: "m", ContextWindow: 10}, req: models.ChatRequest{Messages: messages}, wantCode: models.ErrorCodeContextLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := checkModelLimits(tt.model, &tt.req)
+			code := ""
+			if apiErr != nil {
+				code = apiErr.ErrorInfo.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("error code = %q, want %q (%v)", code, tt.wantCode, apiErr)
+			}
+		})
+	}
+}
+
+func TestChatModelLimits(t *testing.T) {
+	backend := &fakeAdapter{
+		id:     "fake",
+		models: []models.Model{{ID: "m", ContextWindow: 50, MaxOutputTokens: 20}},
+		reply:  &models.ChatResponse{Choices: []models.Choice{{Message: models.Message{Content: "hi"}}}},
+	}
+	srv := newTestServer(t, &config.Config{}, backend)
+
+	resp, body := post(t, srv, "/v1/chat/completions", `{"model":"m","max_tokens":10,"messages":[{"role":"user","content":"`+strings.Repeat("x", 200)+`"}]}`)
+	if resp.StatusCode != 400 || !strings.Contains(body, `"code":"`+models.ErrorCodeContextLength+`"`) {
+		t.Errorf("status = %d, body = %s, want a context length error", resp.StatusCode, body)
+	}
+	if backend.lastRequest().Model != "" {
+		t.Error("a request over the model's limits reached the backend")
+	}
+
+	if resp, body := post(t, srv, "/v1/chat/completions", `{"model":"m","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`); resp.StatusCode != 200 {
+		t.Errorf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+}
+
+func TestChatCost(t *testing.T) {
+	usage := &models.Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantHeader  string
+		wantTrailer string
+	}{
+		{name: "response", body: `{"model":"m","messages":[{"role":"user","content":"hi"}]}`, wantHeader: "0.007000"},
+		{name: "stream", body: chatStreamBody, wantTrailer: "0.007000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{
+				id:     "fake",
+				models: []models.Model{{ID: "m", Pricing: &models.ModelPricing{Input: 2, Output: 10}}},
+				reply:  &models.ChatResponse{Choices: []models.Choice{{Message: models.Message{Content: "hello"}}}, Usage: usage},
+				chunks: append(textChunks("stop", "hello"), models.StreamChunk{Usage: usage}),
+			}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			resp, body := post(t, srv, "/v1/chat/completions", tt.body)
+			if resp.StatusCode != 200 {
+				t.Fatalf("status = %d: %s", resp.StatusCode, body)
+			}
+			if got := resp.Header.Get("X-Gateway-Cost"); got != tt.wantHeader {
+				t.Errorf("X-Gateway-Cost header = %q, want %q", got, tt.wantHeader)
+			}
+			if got := resp.Trailer.Get("X-Gateway-Cost"); got != tt.wantTrailer {
+				t.Errorf("X-Gateway-Cost trailer = %q, want %q", got, tt.wantTrailer)
+			}
+		})
+	}
+}
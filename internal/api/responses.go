@@ -0,0 +1,273 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// CreateResponse implements the OpenAI Responses API on top of the chat
+// adapters.
+func (h *Handler) CreateResponse(c *gin.Context) {
+	var req models.ResponsesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiErr := models.NewAPIError("Invalid request body", models.ErrorTypeInvalidRequest, models.ErrorCodeInvalidMessages, 400)
+		c.JSON(apiErr.GetStatus(), apiErr)
+		return
+	}
+
+	input, err := responseInputMessages(req.Input)
+	if err != nil || len(input) == 0 {
+		apiErr := models.NewAPIError("Invalid or empty 'input'", models.ErrorTypeInvalidRequest, models.ErrorCodeInvalidMessages, 400)
+		c.JSON(apiErr.GetStatus(), apiErr)
+		return
+	}
+
+	// Stored conversations are only visible to the key that created them.
+	owner := auth.HashKey(c.GetString("api_key"))
+
+	var history []models.Message
+	if req.PreviousResponseID != "" {
+		var ok bool
+		history, ok = h.conversations.Load(owner, req.PreviousResponseID)
+		if !ok {
+			apiErr := models.NewAPIError(
+				fmt.Sprintf("Previous response '%s' not found", req.PreviousResponseID),
+				models.ErrorTypeInvalidRequest,
+				models.ErrorCodePreviousResponseNotFound,
+				404,
+			)
+			c.JSON(apiErr.GetStatus(), apiErr)
+			return
+		}
+	}
+
+	conversation := append(history, input...)
+
+	chatReq := models.ChatRequest{
+		Model:       req.Model,
+		Stream:      req.Stream,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxOutputTokens,
+		Backend:     req.Backend,
+	}
+	if req.Instructions != "" {
+		chatReq.Messages = append(chatReq.Messages, models.Message{Role: "system", Content: req.Instructions})
+	}
+	chatReq.Messages = append(chatReq.Messages, conversation...)
+
+	adapter, release, ok := h.dispatch(c, &chatReq)
+	if !ok {
+		return
+	}
+	defer release()
+
+	resp := &models.Response{
+		ID:                 newID("resp_"),
+		Object:             "response",
+		CreatedAt:          time.Now().Unix(),
+		Status:             "in_progress",
+		Model:              chatReq.Model,
+		Instructions:       req.Instructions,
+		PreviousResponseID: req.PreviousResponseID,
+		Output:             []models.ResponseOutputItem{},
+	}
+
+	// Instructions are not carried over to follow-up responses, so only the
+	// conversation itself is stored.
+	store := func(text string) {
+		if req.Store != nil && !*req.Store {
+			return
+		}
+		messages := append(conversation, models.Message{Role: "assistant", Content: text})
+		h.conversations.Save(owner, resp.ID, messages)
+	}
+
+	if req.Stream {
+		h.streamChat(c, adapter, &chatReq, &responsesStreamWriter{
+			resp:     resp,
+			itemID:   newID("msg_"),
+			complete: store,
+		})
+		return
+	}
+
+	chatResp, err := adapter.Chat(c.Request.Context(), &chatReq)
+	if err != nil {
+		apiErr := adapterError(err, adapter.ID(), chatReq.Model)
+		c.JSON(apiErr.GetStatus(), apiErr)
+		return
+	}
+
+	var text string
+	if len(chatResp.Choices) > 0 {
+		text = chatResp.Choices[0].Message.Content
+	}
+
+	resp.Status = "completed"
+	resp.Output = []models.ResponseOutputItem{outputMessage(newID("msg_"), "completed", text)}
+	resp.OutputText = text
+	if chatResp.Usage != nil {
+		resp.Usage = &models.ResponseUsage{
+			InputTokens:  chatResp.Usage.PromptTokens,
+			OutputTokens: chatResp.Usage.CompletionTokens,
+			TotalTokens:  chatResp.Usage.TotalTokens,
+		}
+	}
+	store(text)
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// responseInputMessages converts the Responses API input, a plain string or
+// a list of items, into chat messages.
+func responseInputMessages(raw json.RawMessage) ([]models.Message, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []models.Message{{Role: "user", Content: text}}, nil
+	}
+
+	var items []models.ResponseInputItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	for i := range items {
+		item := &items[i]
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			messages = append(messages, models.Message{Role: role, Content: item.Text()})
+		case "function_call_output":
+			messages = append(messages, models.Message{Role: "tool", Content: item.Output, ToolCallID: item.CallID})
+		}
+	}
+	return messages, nil
+}
+
+func outputMessage(id, status, text string) models.ResponseOutputItem {
+	content := []models.ResponseContent{}
+	if status == "completed" {
+		content = append(content, outputText(text))
+	}
+	return models.ResponseOutputItem{
+		Type:    "message",
+		ID:      id,
+		Status:  status,
+		Role:    "assistant",
+		Content: content,
+	}
+}
+
+func outputText(text string) models.ResponseContent {
+	return models.ResponseContent{Type: "output_text", Text: text, Annotations: []interface{}{}}
+}
+
+// responsesStreamWriter turns chat chunks into Responses API semantic
+// events for a single assistant message.
+type responsesStreamWriter struct {
+	resp     *models.Response
+	itemID   string
+	complete func(text string)
+
+	seq     int
+	started bool
+	failed  bool
+	text    strings.Builder
+}
+
+func (sw *responsesStreamWriter) emit(w io.Writer, event models.ResponseStreamEvent) {
+	event.SequenceNumber = sw.seq
+	sw.seq++
+	writeSSE(w, event.Type, event)
+}
+
+func (sw *responsesStreamWriter) start(w io.Writer) {
+	if sw.started {
+		return
+	}
+	sw.started = true
+
+	zero := 0
+	item := outputMessage(sw.itemID, "in_progress", "")
+	part := outputText("")
+
+	snapshot := *sw.resp
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.created", Response: &snapshot})
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.in_progress", Response: &snapshot})
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.output_item.added", OutputIndex: &zero, Item: &item})
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.content_part.added", ItemID: sw.itemID, OutputIndex: &zero, ContentIndex: &zero, Part: &part})
+}
+
+func (sw *responsesStreamWriter) Chunk(w io.Writer, chunk *models.StreamChunk) {
+	sw.start(w)
+
+	zero := 0
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content == "" {
+			continue
+		}
+		sw.text.WriteString(choice.Delta.Content)
+		sw.emit(w, models.ResponseStreamEvent{
+			Type:         "response.output_text.delta",
+			ItemID:       sw.itemID,
+			OutputIndex:  &zero,
+			ContentIndex: &zero,
+			Delta:        choice.Delta.Content,
+		})
+	}
+}
+
+func (sw *responsesStreamWriter) Error(w io.Writer, apiErr *models.APIError) {
+	sw.start(w)
+	sw.failed = true
+
+	sw.resp.Status = "failed"
+	sw.resp.Error = &apiErr.ErrorInfo
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.failed", Response: sw.resp})
+}
+
+func (sw *responsesStreamWriter) Done(w io.Writer) {
+	if sw.failed {
+		return
+	}
+	sw.start(w)
+
+	zero := 0
+	text := sw.text.String()
+	part := outputText(text)
+	item := outputMessage(sw.itemID, "completed", text)
+
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.output_text.done", ItemID: sw.itemID, OutputIndex: &zero, ContentIndex: &zero, Text: text})
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.content_part.done", ItemID: sw.itemID, OutputIndex: &zero, ContentIndex: &zero, Part: &part})
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.output_item.done", OutputIndex: &zero, Item: &item})
+
+	sw.resp.Status = "completed"
+	sw.resp.Output = []models.ResponseOutputItem{item}
+	sw.resp.OutputText = text
+	sw.emit(w, models.ResponseStreamEvent{Type: "response.completed", Response: sw.resp})
+
+	if sw.complete != nil {
+		sw.complete(text)
+	}
+}
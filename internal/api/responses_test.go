@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func TestResponseInputMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []models.Message
+		wantErr bool
+	}{
+		{
+			name:  "string",
+			input: `"hello"`,
+			want:  []models.Message{{Role: "user", Content: "hello"}},
+		},
+		{
+			name: "items",
+			input: `[
+				{"role": "developer", "content": "be brief"},
+				{"type": "message", "role": "user", "content": [{"type": "input_text", "text": "a"}, {"type": "input_image"}, {"type": "input_text", "text": "b"}]},
+				{"type": "function_call_output", "call_id": "call_1", "output": "42"},
+				{"type": "reasoning"}
+			]`,
+			want: []models.Message{
+				{Role: "system", Content: "be brief"},
+				{Role: "user", Content: "ab"},
+				{Role: "tool", Content: "42", ToolCallID: "call_1"},
+			},
+		},
+		{name: "neither", input: `{"role": "user"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := responseInputMessages(json.RawMessage(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateResponse(t *testing.T) {
+	backend := &fakeAdapter{
+		id:     "fake",
+		models: []models.Model{{ID: "m"}},
+		reply: &models.ChatResponse{
+			Choices: []models.Choice{{Message: models.Message{Role: "assistant", Content: "Paris"}, FinishReason: "stop"}},
+			Usage:   &models.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+		},
+	}
+	srv := newTestServer(t, &config.Config{}, backend)
+
+	resp, body := post(t, srv, "/v1/responses", `{"model":"m","instructions":"Answer briefly.","input":"Capital of France?","max_output_tokens":20}`)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d: %s", resp.StatusCode, body)
+	}
+	var first models.Response
+	if err := json.Unmarshal([]byte(body), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != "completed" || first.OutputText != "Paris" || len(first.Output) != 1 || first.Output[0].Content[0].Text != "Paris" {
+		t.Errorf("response = %+v, want completed with the text Paris", first)
+	}
+	if first.Usage == nil || *first.Usage != (models.ResponseUsage{InputTokens: 10, OutputTokens: 2, TotalTokens: 12}) {
+		t.Errorf("usage = %+v", first.Usage)
+	}
+	got := backend.lastRequest()
+	wantMessages := []models.Message{{Role: "system", Content: "Answer briefly."}, {Role: "user", Content: "Capital of France?"}}
+	if !reflect.DeepEqual(got.Messages, wantMessages) || got.MaxTokens == nil || *got.MaxTokens != 20 {
+		t.Errorf("chat request = %+v, want the instructions, the input and max_tokens 20", got)
+	}
+
+	// A follow-up gets the stored conversation, but not the instructions.
+	resp, body = post(t, srv, "/v1/responses", fmt.Sprintf(`{"model":"m","previous_response_id":%q,"input":"And Spain?"}`, first.ID))
+	if resp.StatusCode != 200 {
+		t.Fatalf("follow-up status = %d: %s", resp.StatusCode, body)
+	}
+	wantMessages = []models.Message{
+		{Role: "user", Content: "Capital of France?"},
+		{Role: "assistant", Content: "Paris"},
+		{Role: "user", Content: "And Spain?"},
+	}
+	if got := backend.lastRequest().Messages; !reflect.DeepEqual(got, wantMessages) {
+		t.Errorf("follow-up messages = %+v, want %+v", got, wantMessages)
+	}
+}
+
+func TestCreateResponsePreviousResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		store      string
+		followUp   string
+		wantStatus int
+	}{
+		{name: "same key", store: "true", followUp: "alice-key", wantStatus: 200},
+		{name: "another key", store: "true", followUp: "bob-key", wantStatus: 404},
+		{name: "not stored", store: "false", followUp: "alice-key", wantStatus: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{
+				id:     "fake",
+				models: []models.Model{{ID: "m"}},
+				reply:  &models.ChatResponse{Choices: []models.Choice{{Message: models.Message{Content: "hi"}}}},
+			}
+			cfg := &config.Config{Auth: config.AuthConfig{Keys: []string{"alice-key", "bob-key"}}}
+			srv := newTestServer(t, cfg, backend)
+
+			_, body := postAs(t, srv, "alice-key", "/v1/responses", `{"model":"m","input":"hello","store":`+tt.store+`}`)
+			var first models.Response
+			if err := json.Unmarshal([]byte(body), &first); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, body := postAs(t, srv, tt.followUp, "/v1/responses", fmt.Sprintf(`{"model":"m","previous_response_id":%q,"input":"again"}`, first.ID))
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestCreateResponseStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunks    []models.StreamChunk
+		err       error
+		wantTypes []string
+		wantText  string
+	}{
+		{
+			name:   "completed",
+			chunks: textChunks("stop", "Par", "is"),
+			wantTypes: []string{
+				"response.created", "response.in_progress", "response.output_item.added", "response.content_part.added",
+				"response.output_text.delta", "response.output_text.delta",
+				"response.output_text.done", "response.content_part.done", "response.output_item.done", "response.completed",
+			},
+			wantText: "Paris",
+		},
+		{
+			name:   "failed",
+			chunks: textChunks("", "Par"),
+			err:    fmt.Errorf("upstream went away"),
+			wantTypes: []string{
+				"response.created", "response.in_progress", "response.output_item.added", "response.content_part.added",
+				"response.output_text.delta", "response.failed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{id: "fake", models: []models.Model{{ID: "m"}}, chunks: tt.chunks, err: tt.err}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			_, body := post(t, srv, "/v1/responses", `{"model":"m","input":"Capital of France?","stream":true}`)
+
+			var types []string
+			var last models.ResponseStreamEvent
+			for i, data := range sseData(body) {
+				var event models.ResponseStreamEvent
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatalf("event %d: %v", i, err)
+				}
+				if event.SequenceNumber != i {
+					t.Errorf("event %d has sequence number %d", i, event.SequenceNumber)
+				}
+				if !strings.Contains(body, "event: "+event.Type+"\n") {
+					t.Errorf("no SSE event name for %s", event.Type)
+				}
+				types = append(types, event.Type)
+				last = event
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Fatalf("events = %v, want %v", types, tt.wantTypes)
+			}
+			if tt.wantText != "" && (last.Response == nil || last.Response.OutputText != tt.wantText) {
+				t.Errorf("final response = %+v, want the text %q", last.Response, tt.wantText)
+			}
+			if tt.err != nil && (last.Response == nil || last.Response.Status != "failed" || last.Response.Error == nil) {
+				t.Errorf("final response = %+v, want failed with an error", last.Response)
+			}
+		})
+	}
+}
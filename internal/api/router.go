@@ -4,12 +4,24 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/kashifkhan/ai-gateway/internal/adapters"
 	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/batch"
+	"github.com/kashifkhan/ai-gateway/internal/budget"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/limiter"
+	"github.com/kashifkhan/ai-gateway/internal/routing"
+	"github.com/kashifkhan/ai-gateway/internal/sandbox"
 )
 
 func SetupRouter(
 	registry *adapters.Registry,
 	authenticator *auth.Authenticator,
 	rateLimiter *auth.RateLimiter,
+	concurrencyLimiter *limiter.Limiter,
+	toolPolicies *sandbox.Policies,
+	routes *routing.Table,
+	budget *budget.Tracker,
+	batches *batch.Manager,
+	cfg *config.Config,
 	version string,
 ) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
@@ -21,15 +33,33 @@ func SetupRouter(
 	router.Use(rateLimiter.Middleware())
 	router.Use(authenticator.Middleware())
 
-	handler := NewHandler(registry, version)
+	handler := NewHandler(registry, concurrencyLimiter, toolPolicies, routes, budget, batches, cfg, version)
 
 	router.GET("/health", handler.Health)
 
 	v1 := router.Group("/v1")
 	{
 		v1.GET("/models", handler.ListModels)
+		v1.GET("/models/*id", handler.GetModel)
 		v1.GET("/backends", handler.ListBackends)
+		v1.GET("/routes", handler.ListRoutes)
+		v1.GET("/routes/resolve", handler.ResolveRoute)
 		v1.POST("/chat/completions", handler.ChatCompletions)
+		v1.POST("/completions", handler.Completions)
+		v1.POST("/embeddings", handler.Embeddings)
+		v1.POST("/responses", handler.CreateResponse)
+		v1.POST("/messages", handler.Messages)
+
+		v1.POST("/files", handler.UploadFile)
+		v1.GET("/files", handler.ListFiles)
+		v1.GET("/files/:id", handler.GetFile)
+		v1.GET("/files/:id/content", handler.GetFileContent)
+		v1.DELETE("/files/:id", handler.DeleteFile)
+
+		v1.POST("/batches", handler.CreateBatch)
+		v1.GET("/batches", handler.ListBatches)
+		v1.GET("/batches/:id", handler.GetBatch)
+		v1.POST("/batches/:id/cancel", handler.CancelBatch)
 	}
 
 	return router
@@ -39,7 +69,7 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, x-api-key, anthropic-version")
 		c.Header("Access-Control-Max-Age", "86400")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func (h *Handler) ListRoutes(c *gin.Context) {
+	c.JSON(http.StatusOK, models.RoutesResponse{
+		Object: "list",
+		Data:   h.routes.Routes(auth.HashKey(c.GetString("api_key"))),
+	})
+}
+
+// ResolveRoute shows where a chat request for ?model= (and optionally
+// ?backend=) would be sent, given the caller's own headers and API key,
+// without sending anything. Virtual models are routed by their underlying
+// model.
+func (h *Handler) ResolveRoute(c *gin.Context) {
+	model := c.Query("model")
+	if model == "" {
+		writeError(c, invalidRequest("'model' query parameter is required"))
+		return
+	}
+
+	requested := model
+	if vm, ok := h.registry.VirtualModel(model); ok {
+		model = vm.Model
+	}
+
+	req := routeRequest(c, model)
+	route, target := h.route(req, c.Query("backend"))
+
+	adapter, resolvedModel, err := h.registry.FindAdapterForModel(target)
+	if err != nil {
+		writeError(c, models.ErrInvalidModel(requested))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RouteResolution{
+		Model:         requested,
+		Route:         route,
+		Backend:       adapter.ID(),
+		ResolvedModel: resolvedModel,
+	})
+}
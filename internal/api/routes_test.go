@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+var routedConfig = &config.Config{
+	Routes: []config.RouteConfig{
+		{Name: "gpt", Match: "gpt-*", Backend: "openai", Model: "$1"},
+		{Name: "rename", Match: "fast", Model: "small"},
+	},
+}
+
+func routedBackends() (*fakeAdapter, *fakeAdapter) {
+	reply := &models.ChatResponse{Choices: []models.Choice{{Message: models.Message{Content: "hi"}}}}
+	local := &fakeAdapter{id: "local", models: []models.Model{{ID: "small"}, {ID: "4o"}}, reply: reply}
+	openai := &fakeAdapter{id: "openai", models: []models.Model{{ID: "4o"}}, reply: reply}
+	return local, openai
+}
+
+func TestChatRouting(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantBackend string
+		wantModel   string
+		wantStatus  int
+	}{
+		{name: "routed to a backend", body: `{"model":"gpt-4o"}`, wantBackend: "openai", wantModel: "4o", wantStatus: 200},
+		{name: "model rewritten", body: `{"model":"fast"}`, wantBackend: "local", wantModel: "small", wantStatus: 200},
+		{name: "no route", body: `{"model":"4o"}`, wantBackend: "local", wantModel: "4o", wantStatus: 200},
+		{name: "backend bypasses the table", body: `{"model":"4o","backend":"openai"}`, wantBackend: "openai", wantModel: "4o", wantStatus: 200},
+		{name: "route to a missing model", body: `{"model":"gpt-5"}`, wantStatus: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, openai := routedBackends()
+			srv := newTestServer(t, routedConfig, local, openai)
+
+			body := tt.body[:len(tt.body)-1] + `,"messages":[{"role":"user","content":"hi"}]}`
+			resp, respBody := post(t, srv, "/v1/chat/completions", body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, respBody)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+
+			backend := map[string]*fakeAdapter{"local": local, "openai": openai}[tt.wantBackend]
+			if got := backend.lastRequest().Model; got != tt.wantModel {
+				t.Errorf("%s got model %q, want %q", tt.wantBackend, got, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestResolveRoute(t *testing.T) {
+	local, openai := routedBackends()
+	srv := newTestServer(t, routedConfig, local, openai)
+
+	resp, body := get(t, srv, "/v1/routes/resolve?model=gpt-4o")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d: %s", resp.StatusCode, body)
+	}
+	var got models.RouteResolution
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Route == nil || got.Route.Name != "gpt" || got.Backend != "openai" || got.ResolvedModel != "4o" {
+		t.Errorf("resolution = %+v, want route gpt to openai's 4o", got)
+	}
+	if openai.lastRequest().Model != "" {
+		t.Error("resolving a route sent a request")
+	}
+
+	if resp, body := get(t, srv, "/v1/routes/resolve"); resp.StatusCode != 400 {
+		t.Errorf("status without a model = %d, want 400: %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// streamWriter renders adapter stream chunks in the wire format of one API
+// flavour.
+type streamWriter interface {
+	Chunk(w io.Writer, chunk *models.StreamChunk)
+	Error(w io.Writer, apiErr *models.APIError)
+	Done(w io.Writer)
+}
+
+// streamChat runs req as a stream on adapter and writes it through sw, with
+// heartbeats while the upstream is quiet.
+func (h *Handler) streamChat(c *gin.Context, adapter adapters.Adapter, req *models.ChatRequest, sw streamWriter) {
+	chunks, errs := adapter.ChatStream(c.Request.Context(), req)
+
+	// Hold the headers back until the adapter produces something, so that
+	// failures before the first chunk still get a real HTTP status.
+	var first *models.StreamChunk
+	var firstErr error
+wait:
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if errs != nil {
+					firstErr = <-errs
+				}
+				break wait
+			}
+			first = &chunk
+			break wait
+		case err, ok := <-errs:
+			if !ok {
+				// errs closes just before chunks; keep reading chunks.
+				errs = nil
+				continue
+			}
+			firstErr = err
+			break wait
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+
+	if firstErr != nil {
+		apiErr := adapterError(firstErr, adapter.ID(), req.Model)
+		writeError(c, apiErr)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Transfer-Encoding", "chunked")
+	c.Header("Trailer", costHeader)
+
+	// Streams are bounded by the adapter's first-token and idle timeouts, not
+	// by the server-wide write timeout.
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	// The heartbeat only fires after h.heartbeat without any other write.
+	var heartbeat <-chan time.Time
+	wrote := func() {}
+	if h.heartbeat > 0 {
+		ticker := time.NewTicker(h.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+		wrote = func() { ticker.Reset(h.heartbeat) }
+	}
+
+	// Chunks sent before an error or before errs was closed are still
+	// buffered, so drain chunks first and report the error at the end.
+	var streamErr error
+
+	c.Stream(func(w io.Writer) bool {
+		if first != nil {
+			sw.Chunk(w, first)
+			c.Writer.Flush()
+			first = nil
+			wrote()
+			return true
+		}
+
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if errs != nil {
+					if err := <-errs; err != nil {
+						streamErr = err
+					}
+				}
+				if streamErr != nil {
+					sw.Error(w, adapterError(streamErr, adapter.ID(), req.Model))
+				}
+				sw.Done(w)
+				return false
+			}
+
+			sw.Chunk(w, &chunk)
+			c.Writer.Flush()
+			wrote()
+			return true
+
+		case err, ok := <-errs:
+			if ok && err != nil {
+				streamErr = err
+			}
+			errs = nil
+			return true
+
+		case <-heartbeat:
+			fmt.Fprintf(w, ": ping\n\n")
+			c.Writer.Flush()
+			return true
+
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+
+	// The cost is known once the stream has ended and goes in the trailer
+	// declared above.
+	if cost, ok := c.Get(streamCostKey); ok {
+		setCost(c, cost.(float64))
+	}
+}
+
+// writeSSE writes one server-sent event. An empty event name writes a bare
+// data line, as the chat completions API does.
+func writeSSE(w io.Writer, event string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", string(data))
+}
+
+type chatStreamWriter struct{}
+
+func (chatStreamWriter) Chunk(w io.Writer, chunk *models.StreamChunk) {
+	writeSSE(w, "", chunk)
+}
+
+func (chatStreamWriter) Error(w io.Writer, apiErr *models.APIError) {
+	writeSSE(w, "", apiErr)
+}
+
+func (chatStreamWriter) Done(w io.Writer) {
+	fmt.Fprintf(w, "data: [DONE]\n\n")
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/adapters"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const chatStreamBody = `{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+
+func TestStreamChatHeartbeat(t *testing.T) {
+	tests := []struct {
+		name      string
+		heartbeat time.Duration
+		chunks    int
+		gap       time.Duration
+		wantPing  bool
+	}{
+		{name: "quiet upstream", heartbeat: 40 * time.Millisecond, chunks: 2, gap: 150 * time.Millisecond, wantPing: true},
+		{name: "chunks keep the stream busy", heartbeat: 60 * time.Millisecond, chunks: 6, gap: 30 * time.Millisecond, wantPing: false},
+		{name: "disabled", heartbeat: 0, chunks: 2, gap: 100 * time.Millisecond, wantPing: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			texts := make([]string, tt.chunks)
+			for i := range texts {
+				texts[i] = "x"
+			}
+			backend := &fakeAdapter{
+				id:     "fake",
+				models: []models.Model{{ID: "m"}},
+				chunks: textChunks("", texts...),
+				gap:    tt.gap,
+			}
+			cfg := &config.Config{Server: config.ServerConfig{HeartbeatInterval: tt.heartbeat}}
+			srv := newTestServer(t, cfg, backend)
+
+			_, body := post(t, srv, "/v1/chat/completions", chatStreamBody)
+			if got := strings.Contains(body, ": ping\n\n"); got != tt.wantPing {
+				t.Errorf("heartbeat sent = %v, want %v; body:\n%s", got, tt.wantPing, body)
+			}
+			if data := sseData(body); len(data) != tt.chunks+1 || data[len(data)-1] != "[DONE]" {
+				t.Errorf("data = %q, want %d chunks and [DONE]", data, tt.chunks)
+			}
+		})
+	}
+}
+
+func TestStreamChatErrors(t *testing.T) {
+	timeout := adapters.NewError(adapters.ErrBackendTimeout, "timeout waiting for message to start")
+
+	tests := []struct {
+		name        string
+		chunks      []models.StreamChunk
+		err         error
+		wantStatus  int
+		wantData    []string
+		wantErrBody string
+	}{
+		{
+			name:       "success",
+			chunks:     textChunks("stop", "Hel", "lo"),
+			wantStatus: 200,
+			wantData:   []string{`"content":"Hel"`, `"content":"lo"`, `"finish_reason":"stop"`, "[DONE]"},
+		},
+		{
+			name:        "error before the first chunk",
+			err:         timeout,
+			wantStatus:  504,
+			wantErrBody: `"code":"backend_timeout"`,
+		},
+		{
+			name:       "error mid stream",
+			chunks:     textChunks("", "Hel"),
+			err:        timeout,
+			wantStatus: 200,
+			wantData:   []string{`"content":"Hel"`, `"code":"backend_timeout"`, "[DONE]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakeAdapter{id: "fake", models: []models.Model{{ID: "m"}}, chunks: tt.chunks, err: tt.err}
+			srv := newTestServer(t, &config.Config{}, backend)
+
+			resp, body := post(t, srv, "/v1/chat/completions", chatStreamBody)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+
+			if tt.wantErrBody != "" {
+				if ct := resp.Header.Get("Content-Type"); strings.HasPrefix(ct, "text/event-stream") {
+					t.Errorf("Content-Type = %q for a stream that failed before it started", ct)
+				}
+				if !strings.Contains(body, tt.wantErrBody) {
+					t.Errorf("body = %s, want it to contain %s", body, tt.wantErrBody)
+				}
+				return
+			}
+
+			data := sseData(body)
+			if len(data) != len(tt.wantData) {
+				t.Fatalf("data = %q, want %d events", data, len(tt.wantData))
+			}
+			for i, want := range tt.wantData {
+				if !strings.Contains(data[i], want) {
+					t.Errorf("event %d = %s, want it to contain %s", i, data[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event records one state change of a tool run by a backend agent.
+type Event struct {
+	Time    time.Time       `json:"time"`
+	Backend string          `json:"backend"`
+	Session string          `json:"session"`
+	Caller  string          `json:"caller,omitempty"`
+	Model   string          `json:"model,omitempty"`
+	Tool    string          `json:"tool"`
+	CallID  string          `json:"call_id,omitempty"`
+	Status  string          `json:"status"`
+	Allowed bool            `json:"allowed"`
+	Input   json.RawMessage `json:"input,omitempty"`
+}
+
+// Logger writes tool audit events as JSON lines. Without a file it falls back
+// to the standard logger.
+type Logger struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+func New(path string) (*Logger, error) {
+	if path == "" {
+		return &Logger{}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{w: f}, nil
+}
+
+func (l *Logger) Record(e Event) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	data, _ := json.Marshal(e)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.w == nil {
+		log.Printf("[AUDIT] %s", data)
+		return
+	}
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		log.Printf("Warning: failed to write audit event: %v", err)
+	}
+}
+
+func (l *Logger) Close() error {
+	if l == nil || l.w == nil {
+		return nil
+	}
+	return l.w.Close()
+}
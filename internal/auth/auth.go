@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 	"sync"
 
@@ -17,20 +19,32 @@ type Authenticator struct {
 }
 
 func NewAuthenticator(keys []string, enabled bool) *Authenticator {
-	auth := &Authenticator{
-		keys:    make(map[string]bool),
-		enabled: enabled,
-	}
+	auth := &Authenticator{}
+	auth.Update(keys, enabled)
+	return auth
+}
 
+// Update replaces the accepted keys, e.g. after a config reload.
+func (a *Authenticator) Update(keys []string, enabled bool) {
+	keySet := make(map[string]bool, len(keys))
 	for _, key := range keys {
-		auth.keys[key] = true
+		keySet[key] = true
 	}
 
-	if len(auth.keys) == 0 && enabled {
-		auth.keys[DefaultAPIKey] = true
+	if len(keySet) == 0 && enabled {
+		keySet[DefaultAPIKey] = true
 	}
 
-	return auth
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys = keySet
+	a.enabled = enabled
+}
+
+func (a *Authenticator) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.enabled
 }
 
 func (a *Authenticator) AddKey(key string) {
@@ -53,7 +67,7 @@ func (a *Authenticator) ValidateKey(key string) bool {
 
 func (a *Authenticator) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !a.enabled {
+		if !a.Enabled() {
 			c.Next()
 			return
 		}
@@ -63,28 +77,19 @@ func (a *Authenticator) Middleware() gin.HandlerFunc {
 			return
 		}
 
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			apiErr := models.ErrMissingAPIKey()
-			c.JSON(apiErr.GetStatus(), apiErr)
-			c.Abort()
+		apiKey, ok := requestKey(c)
+		if !ok {
+			abortWithError(c, models.ErrInvalidAPIKey())
 			return
 		}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			apiErr := models.ErrInvalidAPIKey()
-			c.JSON(apiErr.GetStatus(), apiErr)
-			c.Abort()
+		if apiKey == "" {
+			abortWithError(c, models.ErrMissingAPIKey())
 			return
 		}
 
-		apiKey := parts[1]
-
 		if !a.ValidateKey(apiKey) {
-			apiErr := models.ErrInvalidAPIKey()
-			c.JSON(apiErr.GetStatus(), apiErr)
-			c.Abort()
+			abortWithError(c, models.ErrInvalidAPIKey())
 			return
 		}
 
@@ -93,11 +98,56 @@ func (a *Authenticator) Middleware() gin.HandlerFunc {
 	}
 }
 
+// requestKey reads the API key from a bearer Authorization header or, for
+// Anthropic clients, the x-api-key header. It returns false when the
+// Authorization header is malformed.
+func requestKey(c *gin.Context) (string, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return c.GetHeader("x-api-key"), true
+	}
+
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// abortWithError answers in Anthropic's error format on the Messages API and
+// in OpenAI's everywhere else.
+func abortWithError(c *gin.Context, apiErr *models.APIError) {
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/messages") {
+		c.AbortWithStatusJSON(apiErr.GetStatus(), models.ToAnthropicError(apiErr))
+		return
+	}
+	c.AbortWithStatusJSON(apiErr.GetStatus(), apiErr)
+}
+
 func GetDefaultKey() string {
 	return DefaultAPIKey
 }
 
+// MaskKey shortens an API key to something safe to log, e.g. "sk-k...2024".
+func MaskKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
+
+// HashKey identifies an API key in stored data, such as batch owners and
+// spending, without keeping the key itself.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
 type RateLimiter struct {
+	mu                sync.RWMutex
 	enabled           bool
 	requestsPerMinute int
 }
@@ -109,9 +159,23 @@ func NewRateLimiter(enabled bool, requestsPerMinute int) *RateLimiter {
 	}
 }
 
+// Update replaces the limits, e.g. after a config reload.
+func (r *RateLimiter) Update(enabled bool, requestsPerMinute int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enabled = enabled
+	r.requestsPerMinute = requestsPerMinute
+}
+
+func (r *RateLimiter) Enabled() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.enabled
+}
+
 func (r *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !r.enabled {
+		if !r.Enabled() {
 			c.Next()
 			return
 		}
@@ -0,0 +1,359 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// maxValidationErrors caps how many bad lines are reported for a rejected
+// input file.
+const maxValidationErrors = 100
+
+// Executor runs one request of a batch on behalf of its owner and returns
+// the response, or the error to record for that line.
+type Executor func(ctx context.Context, owner *Owner, req *models.ChatRequest) (*models.ChatResponse, *models.APIError)
+
+// Start begins executing batches with exec, resuming any that were still
+// running when the gateway last stopped.
+func (m *Manager) Start(exec Executor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.exec = exec
+	for _, j := range m.batches {
+		switch {
+		case j.Status == StatusCancelling:
+			now := time.Now().Unix()
+			j.Status = StatusCancelled
+			j.CancelledAt = &now
+			m.publish(j)
+			m.saveJob(j)
+		case !isTerminal(j.Status):
+			log.Printf("Resuming batch %s (%d/%d done)", j.ID, j.RequestCounts.Completed+j.RequestCounts.Failed, j.RequestCounts.Total)
+			go m.run(j)
+		}
+	}
+}
+
+func (m *Manager) run(j *job) {
+	// ctx bounds every request by the batch's expiry; cancelling the batch
+	// only stops new requests from starting, so those in flight finish.
+	ctx, cancel := context.WithDeadline(context.Background(), time.Unix(j.ExpiresAt, 0))
+	defer cancel()
+	dispatch, stop := context.WithCancel(ctx)
+	defer stop()
+
+	m.mu.Lock()
+	j.cancel = stop
+	status := j.Status
+	m.mu.Unlock()
+
+	if status == StatusCancelling {
+		m.finish(ctx, j)
+		return
+	}
+
+	if status == StatusValidating {
+		total, errs := m.validate(j)
+		if len(errs) > 0 || total == 0 {
+			if total == 0 && len(errs) == 0 {
+				errs = append(errs, models.BatchError{Code: "empty_file", Message: "The input file contains no requests"})
+			}
+			m.fail(j, errs)
+			return
+		}
+
+		m.mu.Lock()
+		if j.Status == StatusValidating {
+			now := time.Now().Unix()
+			j.Status = StatusInProgress
+			j.InProgressAt = &now
+			j.RequestCounts.Total = total
+			m.saveJob(j)
+		}
+		m.mu.Unlock()
+	}
+
+	if err := m.process(ctx, dispatch, j); err != nil {
+		log.Printf("Batch %s failed: %v", j.ID, err)
+		m.fail(j, []models.BatchError{{Code: "batch_failed", Message: err.Error()}})
+		return
+	}
+	m.finish(ctx, j)
+}
+
+// validate checks every line of the input file and returns the number of
+// requests in it.
+func (m *Manager) validate(j *job) (int, []models.BatchError) {
+	in, err := os.Open(m.contentPath(j.InputFileID))
+	if err != nil {
+		return 0, []models.BatchError{{Code: "file_not_found", Message: "The input file is no longer available"}}
+	}
+	defer in.Close()
+
+	var errs []models.BatchError
+	seen := make(map[string]bool)
+	total := 0
+
+	err = eachLine(in, func(n int, data []byte) bool {
+		var line models.BatchRequestLine
+		var req models.ChatRequest
+		switch {
+		case json.Unmarshal(data, &line) != nil:
+			errs = append(errs, lineError(n, "invalid_json", "Line is not valid JSON"))
+		case line.CustomID == "":
+			errs = append(errs, lineError(n, "missing_custom_id", "Missing custom_id"))
+		case seen[line.CustomID]:
+			errs = append(errs, lineError(n, "duplicate_custom_id", "Duplicate custom_id '%s'", line.CustomID))
+		case line.Method != "POST":
+			errs = append(errs, lineError(n, "invalid_method", "Method must be POST"))
+		case line.URL != j.Endpoint:
+			errs = append(errs, lineError(n, "mismatched_url", "URL '%s' does not match the batch endpoint '%s'", line.URL, j.Endpoint))
+		case json.Unmarshal(line.Body, &req) != nil || req.Model == "" || len(req.Messages) == 0:
+			errs = append(errs, lineError(n, "invalid_body", "Body must be a chat completion request with a model and messages"))
+		}
+		seen[line.CustomID] = true
+		total++
+		return len(errs) < maxValidationErrors
+	})
+	if err != nil {
+		errs = append(errs, models.BatchError{Code: "read_failed", Message: err.Error()})
+	}
+	return total, errs
+}
+
+// process runs every request whose result is not yet recorded, appending
+// results to the output and error files as they complete. No request starts
+// once dispatch is done; ctx bounds the requests themselves.
+func (m *Manager) process(ctx, dispatch context.Context, j *job) error {
+	done, err := m.recorded(j)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(m.contentPath(j.InputFileID))
+	if err != nil {
+		return errors.New("the input file is no longer available")
+	}
+	defer in.Close()
+
+	output, err := os.OpenFile(m.contentPath(j.OutputID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	errorsOut, err := os.OpenFile(m.contentPath(j.ErrorID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer errorsOut.Close()
+
+	var wg sync.WaitGroup
+	var writeMu sync.Mutex
+
+	err = eachLine(in, func(n int, data []byte) bool {
+		var line models.BatchRequestLine
+		if json.Unmarshal(data, &line) != nil || done[line.CustomID] {
+			return true
+		}
+
+		select {
+		case m.sem <- struct{}{}:
+		case <-dispatch.Done():
+			return false
+		}
+		if dispatch.Err() != nil {
+			<-m.sem
+			return false
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-m.sem }()
+
+			result, ok := m.execute(ctx, j, &line)
+			if ctx.Err() != nil {
+				// Expired mid-request; leave the line unrecorded.
+				return
+			}
+
+			data, _ := json.Marshal(result)
+			var werr error
+			writeMu.Lock()
+			if ok {
+				_, werr = output.Write(append(data, '\n'))
+			} else {
+				_, werr = errorsOut.Write(append(data, '\n'))
+			}
+			writeMu.Unlock()
+			if werr != nil {
+				log.Printf("Warning: batch %s failed to record %s: %v", j.ID, line.CustomID, werr)
+				return
+			}
+
+			m.mu.Lock()
+			if ok {
+				j.RequestCounts.Completed++
+			} else {
+				j.RequestCounts.Failed++
+			}
+			m.saveJob(j)
+			m.mu.Unlock()
+		}()
+		return true
+	})
+
+	wg.Wait()
+	return err
+}
+
+func (m *Manager) execute(ctx context.Context, j *job, line *models.BatchRequestLine) (*models.BatchResponseLine, bool) {
+	result := &models.BatchResponseLine{
+		ID:       newID("batch_req_"),
+		CustomID: line.CustomID,
+	}
+	requestID := newID("req_")
+
+	var req models.ChatRequest
+	if err := json.Unmarshal(line.Body, &req); err != nil {
+		apiErr := models.ErrInvalidMessages()
+		result.Response = &models.BatchResponse{StatusCode: apiErr.GetStatus(), RequestID: requestID, Body: apiErr}
+		return result, false
+	}
+	req.Stream = false
+
+	resp, apiErr := m.exec(ctx, &j.Owner, &req)
+	if apiErr != nil {
+		result.Response = &models.BatchResponse{StatusCode: apiErr.GetStatus(), RequestID: requestID, Body: apiErr}
+		return result, false
+	}
+
+	result.Response = &models.BatchResponse{StatusCode: 200, RequestID: requestID, Body: resp}
+	return result, true
+}
+
+// recorded returns the custom IDs that already have a result, so a resumed
+// batch does not run them again.
+func (m *Manager) recorded(j *job) (map[string]bool, error) {
+	done := make(map[string]bool)
+	for _, id := range []string{j.OutputID, j.ErrorID} {
+		f, err := os.Open(m.contentPath(id))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = eachLine(f, func(_ int, data []byte) bool {
+			var line models.BatchResponseLine
+			if json.Unmarshal(data, &line) == nil {
+				done[line.CustomID] = true
+			}
+			return true
+		})
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return done, nil
+}
+
+func (m *Manager) fail(j *job, errs []models.BatchError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().Unix()
+	j.Status = StatusFailed
+	j.FailedAt = &now
+	j.Errors = &models.BatchErrors{Object: "list", Data: errs}
+	m.saveJob(j)
+}
+
+// finish moves j to its final status and publishes its result files.
+func (m *Manager) finish(ctx context.Context, j *job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().Unix()
+	switch {
+	case j.Status == StatusCancelling:
+		j.Status = StatusCancelled
+		j.CancelledAt = &now
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		j.Status = StatusExpired
+		j.ExpiredAt = &now
+	default:
+		j.Status = StatusCompleted
+		j.CompletedAt = &now
+	}
+
+	m.publish(j)
+	m.saveJob(j)
+}
+
+// publish registers the non-empty result files of j. Callers hold m.mu.
+func (m *Manager) publish(j *job) {
+	register := func(id, suffix string) *string {
+		info, err := os.Stat(m.contentPath(id))
+		if err != nil {
+			return nil
+		}
+		if info.Size() == 0 {
+			os.Remove(m.contentPath(id))
+			return nil
+		}
+
+		f := &fileRecord{
+			File: models.File{
+				ID:        id,
+				Object:    "file",
+				Bytes:     info.Size(),
+				CreatedAt: time.Now().Unix(),
+				Filename:  j.ID + suffix,
+				Purpose:   "batch_output",
+			},
+			Owner: Owner{KeyHash: j.Owner.KeyHash},
+		}
+		if err := m.saveFile(f); err != nil {
+			log.Printf("Warning: failed to publish %s for batch %s: %v", suffix, j.ID, err)
+			return nil
+		}
+		m.files[id] = f
+		return &id
+	}
+
+	j.OutputFileID = register(j.OutputID, "_output.jsonl")
+	j.ErrorFileID = register(j.ErrorID, "_error.jsonl")
+}
+
+// eachLine calls fn with every non-blank line of r and its 1-based number,
+// until fn returns false.
+func eachLine(r io.Reader, fn func(n int, data []byte) bool) error {
+	reader := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 && !fn(n, data) {
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
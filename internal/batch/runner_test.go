@@ -0,0 +1,218 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// requestLine is an input line asking model for a reply to content.
+func requestLine(customID, model, content string) string {
+	body, _ := json.Marshal(models.ChatRequest{Model: model, Messages: []models.Message{{Role: "user", Content: content}}})
+	line, _ := json.Marshal(models.BatchRequestLine{CustomID: customID, Method: "POST", URL: "/v1/chat/completions", Body: body})
+	return string(line) + "\n"
+}
+
+// echo answers with the request's message, and fails for the model "bad".
+func echo(ctx context.Context, owner *Owner, req *models.ChatRequest) (*models.ChatResponse, *models.APIError) {
+	if req.Model == "bad" {
+		return nil, models.ErrModelNotFound(req.Model)
+	}
+	return &models.ChatResponse{Choices: []models.Choice{{Message: models.Message{Role: "assistant", Content: req.Messages[0].Content}}}}, nil
+}
+
+// waitDone waits for a batch to reach a final status.
+func waitDone(t *testing.T, m *Manager, id string) *models.Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		batch, err := m.GetBatch("alice", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isTerminal(batch.Status) {
+			return batch
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch still %s", batch.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// results reads a result file as custom ID to status code.
+func results(t *testing.T, m *Manager, fileID *string) map[string]int {
+	t.Helper()
+	if fileID == nil {
+		return nil
+	}
+	path, err := m.FilePath("alice", *fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]int)
+	for _, raw := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var line models.BatchResponseLine
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatal(err)
+		}
+		got[line.CustomID] = line.Response.StatusCode
+	}
+	return got
+}
+
+func createBatch(t *testing.T, m *Manager, input string) string {
+	t.Helper()
+	batch, err := m.CreateBatch(alice, &models.CreateBatchRequest{InputFileID: upload(t, m, input), Endpoint: "/v1/chat/completions", CompletionWindow: "24h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch.ID
+}
+
+func TestRun(t *testing.T) {
+	m, err := NewManager(config.BatchConfig{Dir: t.TempDir(), Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start(echo)
+
+	id := createBatch(t, m, requestLine("a", "m", "one")+"\n"+requestLine("b", "bad", "two")+requestLine("c", "m", "three"))
+	batch := waitDone(t, m, id)
+
+	if batch.Status != StatusCompleted || batch.CompletedAt == nil || batch.InProgressAt == nil {
+		t.Errorf("batch = %+v, want completed", batch)
+	}
+	if batch.RequestCounts != (models.BatchRequestCounts{Total: 3, Completed: 2, Failed: 1}) {
+		t.Errorf("request counts = %+v", batch.RequestCounts)
+	}
+	if got := results(t, m, batch.OutputFileID); len(got) != 2 || got["a"] != 200 || got["c"] != 200 {
+		t.Errorf("output = %v, want a and c", got)
+	}
+	if got := results(t, m, batch.ErrorFileID); len(got) != 1 || got["b"] != 404 {
+		t.Errorf("errors = %v, want b with 404", got)
+	}
+}
+
+func TestRunValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantCodes []string
+	}{
+		{name: "empty", input: "\n", wantCodes: []string{"empty_file"}},
+		{
+			name: "bad lines",
+			input: "not json\n" +
+				`{"method":"POST","url":"/v1/chat/completions","body":{}}` + "\n" +
+				requestLine("a", "m", "hi") +
+				requestLine("a", "m", "again") +
+				`{"custom_id":"b","method":"GET","url":"/v1/chat/completions","body":{}}` + "\n" +
+				`{"custom_id":"c","method":"POST","url":"/v1/embeddings","body":{}}` + "\n" +
+				`{"custom_id":"d","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}` + "\n",
+			wantCodes: []string{"invalid_json", "missing_custom_id", "duplicate_custom_id", "invalid_method", "mismatched_url", "invalid_body"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, t.TempDir())
+			m.Start(echo)
+
+			batch := waitDone(t, m, createBatch(t, m, tt.input))
+			if batch.Status != StatusFailed || batch.Errors == nil {
+				t.Fatalf("batch = %+v, want failed with errors", batch)
+			}
+			var codes []string
+			for _, e := range batch.Errors.Data {
+				codes = append(codes, e.Code)
+			}
+			if strings.Join(codes, ",") != strings.Join(tt.wantCodes, ",") {
+				t.Errorf("error codes = %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+	id := createBatch(t, m, requestLine("a", "m", "one")+requestLine("b", "m", "two"))
+
+	// The gateway stopped after recording a.
+	done, _ := json.Marshal(models.BatchResponseLine{ID: "batch_req_1", CustomID: "a", Response: &models.BatchResponse{StatusCode: 200}})
+	if err := os.WriteFile(m.contentPath(m.batches[id].OutputID), append(done, '\n'), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var ran []string
+	restarted := newTestManager(t, dir)
+	restarted.Start(func(ctx context.Context, owner *Owner, req *models.ChatRequest) (*models.ChatResponse, *models.APIError) {
+		mu.Lock()
+		ran = append(ran, req.Messages[0].Content)
+		mu.Unlock()
+		return echo(ctx, owner, req)
+	})
+
+	batch := waitDone(t, restarted, id)
+	if batch.Status != StatusCompleted {
+		t.Errorf("batch = %+v, want completed", batch)
+	}
+	if len(ran) != 1 || ran[0] != "two" {
+		t.Errorf("requests run after restart = %v, want only two", ran)
+	}
+	got := results(t, restarted, batch.OutputFileID)
+	ids := make([]string, 0, len(got))
+	for id := range got {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if strings.Join(ids, ",") != "a,b" {
+		t.Errorf("output = %v, want a and b", got)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	m.Start(func(ctx context.Context, owner *Owner, req *models.ChatRequest) (*models.ChatResponse, *models.APIError) {
+		started <- struct{}{}
+		<-release
+		return echo(ctx, owner, req)
+	})
+
+	id := createBatch(t, m, requestLine("a", "m", "one")+requestLine("b", "m", "two"))
+	<-started
+	if _, err := m.CancelBatch("alice", id); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	// The request in flight finishes and is kept; the next never starts.
+	batch := waitDone(t, m, id)
+	if batch.Status != StatusCancelled || batch.CancelledAt == nil {
+		t.Errorf("batch = %+v, want cancelled", batch)
+	}
+	if len(started) != 0 {
+		t.Error("a request started after the batch was cancelled")
+	}
+	if got := results(t, m, batch.OutputFileID); len(got) != 1 || got["a"] != 200 {
+		t.Errorf("output = %v, want only a", got)
+	}
+}
@@ -0,0 +1,444 @@
+package batch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const (
+	DefaultDir         = "data/batches"
+	DefaultConcurrency = 4
+	DefaultMaxFileSize = 100 << 20
+
+	CompletionWindow = "24h"
+)
+
+const (
+	StatusValidating = "validating"
+	StatusFailed     = "failed"
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+	StatusExpired    = "expired"
+	StatusCancelling = "cancelling"
+	StatusCancelled  = "cancelled"
+)
+
+var (
+	ErrFileNotFound        = errors.New("file not found")
+	ErrFileTooLarge        = errors.New("file too large")
+	ErrBatchNotFound       = errors.New("batch not found")
+	ErrUnsupportedEndpoint = errors.New("unsupported endpoint")
+	ErrInvalidWindow       = errors.New("invalid completion window")
+	ErrInvalidPurpose      = errors.New("input file purpose must be 'batch'")
+	ErrBatchFinished       = errors.New("batch already finished")
+)
+
+// Endpoints that batch input lines may target.
+var supportedEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+}
+
+// Owner identifies who created a file or batch. Only a hash of the API key
+// is stored, together with what a batch needs to act on the key's behalf.
+type Owner struct {
+	KeyHash    string             `json:"key_hash"`
+	Caller     string             `json:"caller,omitempty"`
+	ToolPolicy *models.ToolPolicy `json:"tool_policy,omitempty"`
+}
+
+type fileRecord struct {
+	models.File
+	Owner Owner `json:"owner"`
+}
+
+type job struct {
+	models.Batch
+	Owner Owner `json:"owner"`
+
+	// IDs reserved for the result files, which are written while the batch
+	// runs but only published when it finishes.
+	OutputID string `json:"output_id"`
+	ErrorID  string `json:"error_id"`
+
+	cancel func()
+}
+
+// Manager stores uploaded files and batch jobs on local disk and runs the
+// jobs in the background.
+type Manager struct {
+	dir         string
+	maxFileSize int64
+	sem         chan struct{}
+	exec        Executor
+
+	mu      sync.Mutex
+	files   map[string]*fileRecord
+	batches map[string]*job
+}
+
+func NewManager(cfg config.BatchConfig) (*Manager, error) {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = DefaultDir
+	}
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	maxFileSize := cfg.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+
+	m := &Manager{
+		dir:         dir,
+		maxFileSize: maxFileSize,
+		sem:         make(chan struct{}, concurrency),
+		files:       make(map[string]*fileRecord),
+		batches:     make(map[string]*job),
+	}
+
+	for _, sub := range []string{"files", "batches"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Manager) MaxFileSize() int64 {
+	return m.maxFileSize
+}
+
+func (m *Manager) load() error {
+	metas, err := filepath.Glob(filepath.Join(m.dir, "files", "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range metas {
+		var f fileRecord
+		if err := readJSON(path, &f); err != nil {
+			log.Printf("Warning: skipping file record %s: %v", path, err)
+			continue
+		}
+		m.files[f.ID] = &f
+	}
+
+	jobs, err := filepath.Glob(filepath.Join(m.dir, "batches", "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range jobs {
+		var j job
+		if err := readJSON(path, &j); err != nil {
+			log.Printf("Warning: skipping batch record %s: %v", path, err)
+			continue
+		}
+		m.batches[j.ID] = &j
+	}
+	return nil
+}
+
+func (m *Manager) contentPath(id string) string {
+	return filepath.Join(m.dir, "files", id+".jsonl")
+}
+
+func (m *Manager) saveFile(f *fileRecord) error {
+	return writeJSON(filepath.Join(m.dir, "files", f.ID+".json"), f)
+}
+
+// saveJob persists j. Callers hold m.mu.
+func (m *Manager) saveJob(j *job) {
+	if err := writeJSON(filepath.Join(m.dir, "batches", j.ID+".json"), j); err != nil {
+		log.Printf("Warning: failed to persist batch %s: %v", j.ID, err)
+	}
+}
+
+// CreateFile stores the uploaded content of r. Content beyond the configured
+// maximum size fails with ErrFileTooLarge.
+func (m *Manager) CreateFile(owner Owner, filename, purpose string, r io.Reader) (*models.File, error) {
+	id := newID("file-")
+	path := m.contentPath(id)
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := io.Copy(out, io.LimitReader(r, m.maxFileSize+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > m.maxFileSize {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	f := &fileRecord{
+		File: models.File{
+			ID:        id,
+			Object:    "file",
+			Bytes:     n,
+			CreatedAt: time.Now().Unix(),
+			Filename:  filename,
+			Purpose:   purpose,
+		},
+		Owner: Owner{KeyHash: owner.KeyHash},
+	}
+	if err := m.saveFile(f); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.files[id] = f
+	m.mu.Unlock()
+
+	file := f.File
+	return &file, nil
+}
+
+func (m *Manager) GetFile(keyHash, id string) (*models.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.files[id]
+	if !ok || f.Owner.KeyHash != keyHash {
+		return nil, ErrFileNotFound
+	}
+	file := f.File
+	return &file, nil
+}
+
+// FilePath returns the location of a file's content on disk.
+func (m *Manager) FilePath(keyHash, id string) (string, error) {
+	if _, err := m.GetFile(keyHash, id); err != nil {
+		return "", err
+	}
+	return m.contentPath(id), nil
+}
+
+func (m *Manager) ListFiles(keyHash, purpose string) []models.File {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := []models.File{}
+	for _, f := range m.files {
+		if f.Owner.KeyHash != keyHash || (purpose != "" && f.Purpose != purpose) {
+			continue
+		}
+		files = append(files, f.File)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].CreatedAt != files[j].CreatedAt {
+			return files[i].CreatedAt > files[j].CreatedAt
+		}
+		return files[i].ID > files[j].ID
+	})
+	return files
+}
+
+func (m *Manager) DeleteFile(keyHash, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.files[id]
+	if !ok || f.Owner.KeyHash != keyHash {
+		return ErrFileNotFound
+	}
+
+	delete(m.files, id)
+	os.Remove(filepath.Join(m.dir, "files", id+".json"))
+	os.Remove(m.contentPath(id))
+	return nil
+}
+
+// CreateBatch queues a batch over an uploaded input file. Validation of the
+// individual lines happens in the background.
+func (m *Manager) CreateBatch(owner Owner, req *models.CreateBatchRequest) (*models.Batch, error) {
+	if !supportedEndpoints[req.Endpoint] {
+		return nil, ErrUnsupportedEndpoint
+	}
+	if req.CompletionWindow != CompletionWindow {
+		return nil, ErrInvalidWindow
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	input, ok := m.files[req.InputFileID]
+	if !ok || input.Owner.KeyHash != owner.KeyHash {
+		return nil, ErrFileNotFound
+	}
+	if input.Purpose != "batch" {
+		return nil, ErrInvalidPurpose
+	}
+
+	now := time.Now()
+	j := &job{
+		Batch: models.Batch{
+			ID:               newID("batch_"),
+			Object:           "batch",
+			Endpoint:         req.Endpoint,
+			InputFileID:      req.InputFileID,
+			CompletionWindow: req.CompletionWindow,
+			Status:           StatusValidating,
+			CreatedAt:        now.Unix(),
+			ExpiresAt:        now.Add(24 * time.Hour).Unix(),
+			Metadata:         req.Metadata,
+		},
+		Owner:    owner,
+		OutputID: newID("file-"),
+		ErrorID:  newID("file-"),
+	}
+	m.batches[j.ID] = j
+	m.saveJob(j)
+
+	if m.exec != nil {
+		go m.run(j)
+	}
+
+	batch := j.Batch
+	return &batch, nil
+}
+
+func (m *Manager) GetBatch(keyHash, id string) (*models.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.batches[id]
+	if !ok || j.Owner.KeyHash != keyHash {
+		return nil, ErrBatchNotFound
+	}
+	batch := j.Batch
+	return &batch, nil
+}
+
+// ListBatches returns the caller's batches, newest first, starting after the
+// batch with ID after. hasMore reports whether limit cut the list short.
+func (m *Manager) ListBatches(keyHash, after string, limit int) (batches []models.Batch, hasMore bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	all := []models.Batch{}
+	for _, j := range m.batches {
+		if j.Owner.KeyHash == keyHash {
+			all = append(all, j.Batch)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].CreatedAt != all[j].CreatedAt {
+			return all[i].CreatedAt > all[j].CreatedAt
+		}
+		return all[i].ID > all[j].ID
+	})
+
+	if after != "" {
+		for i, b := range all {
+			if b.ID == after {
+				all = all[i+1:]
+				break
+			}
+		}
+	}
+	if limit > 0 && len(all) > limit {
+		return all[:limit], true
+	}
+	return all, false
+}
+
+// CancelBatch stops a running batch. Requests already in flight finish and
+// their results are kept.
+func (m *Manager) CancelBatch(keyHash, id string) (*models.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.batches[id]
+	if !ok || j.Owner.KeyHash != keyHash {
+		return nil, ErrBatchNotFound
+	}
+
+	switch j.Status {
+	case StatusValidating, StatusInProgress:
+		j.Status = StatusCancelling
+		m.saveJob(j)
+		if j.cancel != nil {
+			j.cancel()
+		}
+	case StatusCancelling:
+	default:
+		return nil, ErrBatchFinished
+	}
+
+	batch := j.Batch
+	return &batch, nil
+}
+
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON replaces path atomically, so a crash never leaves a truncated
+// record behind.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func isTerminal(status string) bool {
+	switch status {
+	case StatusFailed, StatusCompleted, StatusExpired, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+func lineError(line int, code, format string, args ...interface{}) models.BatchError {
+	return models.BatchError{
+		Code:    code,
+		Message: strings.TrimSpace(fmt.Sprintf(format, args...)),
+		Line:    &line,
+	}
+}
@@ -0,0 +1,198 @@
+package batch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+var alice = Owner{KeyHash: "alice"}
+
+func newTestManager(t *testing.T, dir string) *Manager {
+	t.Helper()
+	m, err := NewManager(config.BatchConfig{Dir: dir, Concurrency: 1, MaxFileSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// upload stores content as a batch input file owned by alice.
+func upload(t *testing.T, m *Manager, content string) string {
+	t.Helper()
+	f, err := m.CreateFile(alice, "input.jsonl", "batch", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.ID
+}
+
+func TestCreateFile(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+
+	if _, err := m.CreateFile(alice, "big.jsonl", "batch", strings.NewReader(strings.Repeat("x", 1025))); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("err = %v, want ErrFileTooLarge", err)
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, "files", "*")); len(left) != 0 {
+		t.Errorf("files left behind by a rejected upload: %v", left)
+	}
+
+	id := upload(t, m, "{}\n")
+	if f, err := m.GetFile("alice", id); err != nil || f.Bytes != 3 || f.Purpose != "batch" {
+		t.Errorf("GetFile = %+v, %v", f, err)
+	}
+	if path, err := m.FilePath("alice", id); err != nil {
+		t.Error(err)
+	} else if data, _ := os.ReadFile(path); string(data) != "{}\n" {
+		t.Errorf("content = %q", data)
+	}
+
+	// Files are only visible to the key that uploaded them.
+	if _, err := m.GetFile("bob", id); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("GetFile by another key: err = %v, want ErrFileNotFound", err)
+	}
+	if files := m.ListFiles("bob", ""); len(files) != 0 {
+		t.Errorf("ListFiles by another key = %+v", files)
+	}
+	if err := m.DeleteFile("bob", id); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("DeleteFile by another key: err = %v, want ErrFileNotFound", err)
+	}
+
+	if files := m.ListFiles("alice", "batch_output"); len(files) != 0 {
+		t.Errorf("ListFiles with another purpose = %+v", files)
+	}
+	if err := m.DeleteFile("alice", id); err != nil {
+		t.Fatal(err)
+	}
+	if files := m.ListFiles("alice", ""); len(files) != 0 {
+		t.Errorf("ListFiles after delete = %+v", files)
+	}
+}
+
+func TestCreateBatch(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+	input := upload(t, m, "{}\n")
+	output, err := m.CreateFile(alice, "out.jsonl", "batch_output", strings.NewReader("{}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		owner   Owner
+		req     models.CreateBatchRequest
+		wantErr error
+	}{
+		{name: "valid", owner: alice, req: models.CreateBatchRequest{InputFileID: input, Endpoint: "/v1/chat/completions", CompletionWindow: "24h"}},
+		{name: "unsupported endpoint", owner: alice, req: models.CreateBatchRequest{InputFileID: input, Endpoint: "/v1/embeddings", CompletionWindow: "24h"}, wantErr: ErrUnsupportedEndpoint},
+		{name: "other window", owner: alice, req: models.CreateBatchRequest{InputFileID: input, Endpoint: "/v1/chat/completions", CompletionWindow: "1h"}, wantErr: ErrInvalidWindow},
+		{name: "missing file", owner: alice, req: models.CreateBatchRequest{InputFileID: "file-nope", Endpoint: "/v1/chat/completions", CompletionWindow: "24h"}, wantErr: ErrFileNotFound},
+		{name: "another key's file", owner: Owner{KeyHash: "bob"}, req: models.CreateBatchRequest{InputFileID: input, Endpoint: "/v1/chat/completions", CompletionWindow: "24h"}, wantErr: ErrFileNotFound},
+		{name: "wrong purpose", owner: alice, req: models.CreateBatchRequest{InputFileID: output.ID, Endpoint: "/v1/chat/completions", CompletionWindow: "24h"}, wantErr: ErrInvalidPurpose},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := m.CreateBatch(tt.owner, &tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (batch.Status != StatusValidating || batch.InputFileID != input) {
+				t.Errorf("batch = %+v, want a validating batch over %s", batch, input)
+			}
+		})
+	}
+}
+
+func TestListBatches(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+	input := upload(t, m, "{}\n")
+	for i := 0; i < 3; i++ {
+		if _, err := m.CreateBatch(alice, &models.CreateBatchRequest{InputFileID: input, Endpoint: "/v1/chat/completions", CompletionWindow: "24h"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, hasMore := m.ListBatches("alice", "", 0)
+	if len(all) != 3 || hasMore {
+		t.Fatalf("ListBatches = %d batches, has more %v, want 3 and false", len(all), hasMore)
+	}
+
+	page, hasMore := m.ListBatches("alice", all[0].ID, 1)
+	if len(page) != 1 || page[0].ID != all[1].ID || !hasMore {
+		t.Errorf("page after the first = %+v, has more %v, want only %s and more", page, hasMore, all[1].ID)
+	}
+	if page, hasMore := m.ListBatches("alice", all[1].ID, 5); len(page) != 1 || page[0].ID != all[2].ID || hasMore {
+		t.Errorf("last page = %+v, has more %v, want only %s", page, hasMore, all[2].ID)
+	}
+	if others, _ := m.ListBatches("bob", "", 0); len(others) != 0 {
+		t.Errorf("ListBatches by another key = %+v", others)
+	}
+}
+
+func TestManagerReload(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+	input := upload(t, m, "{}\n")
+	batch, err := m.CreateBatch(alice, &models.CreateBatchRequest{
+		InputFileID:      input,
+		Endpoint:         "/v1/chat/completions",
+		CompletionWindow: "24h",
+		Metadata:         map[string]string{"run": "nightly"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A corrupt record is skipped rather than failing the restart.
+	if err := os.WriteFile(filepath.Join(dir, "batches", "batch_bad.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newTestManager(t, dir)
+	if _, err := reloaded.GetFile("alice", input); err != nil {
+		t.Errorf("file after restart: %v", err)
+	}
+	got, err := reloaded.GetBatch("alice", batch.ID)
+	if err != nil {
+		t.Fatalf("batch after restart: %v", err)
+	}
+	if got.Status != StatusValidating || got.Metadata["run"] != "nightly" {
+		t.Errorf("batch after restart = %+v", got)
+	}
+}
+
+func TestCancelBatch(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+	input := upload(t, m, "{}\n")
+	batch, err := m.CreateBatch(alice, &models.CreateBatchRequest{InputFileID: input, Endpoint: "/v1/chat/completions", CompletionWindow: "24h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.CancelBatch("bob", batch.ID); !errors.Is(err, ErrBatchNotFound) {
+		t.Errorf("cancel by another key: err = %v, want ErrBatchNotFound", err)
+	}
+	for i := 0; i < 2; i++ {
+		got, err := m.CancelBatch("alice", batch.ID)
+		if err != nil || got.Status != StatusCancelling {
+			t.Fatalf("cancel %d = %+v, %v, want cancelling", i+1, got, err)
+		}
+	}
+
+	// Started without a running job, the cancellation completes at once.
+	m.Start(nil)
+	got, _ := m.GetBatch("alice", batch.ID)
+	if got.Status != StatusCancelled || got.CancelledAt == nil {
+		t.Fatalf("batch = %+v, want cancelled", got)
+	}
+	if _, err := m.CancelBatch("alice", batch.ID); !errors.Is(err, ErrBatchFinished) {
+		t.Errorf("cancel a finished batch: err = %v, want ErrBatchFinished", err)
+	}
+}
@@ -0,0 +1,236 @@
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const (
+	ScopeGlobal = "global"
+	ScopeKey    = "key"
+)
+
+// FlushInterval is how often recorded spending is written to the spend file.
+const FlushInterval = 5 * time.Second
+
+// Tracker adds up what requests to paid models cost this month, globally and
+// per API key, and tells when a budget is spent. Keys are known only by
+// their auth.HashKey, so batch jobs are charged to the key that created
+// them and the spend file holds no keys. Spending is saved every
+// FlushInterval and on Close.
+type Tracker struct {
+	mu      sync.Mutex
+	path    string
+	monthly float64
+	perKey  float64
+	keys    map[string]float64
+	spend   spend
+	dirty   bool
+
+	saveMu sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// spend is the content of the spend file.
+type spend struct {
+	Month string             `json:"month"`
+	Total float64            `json:"total"`
+	Keys  map[string]float64 `json:"keys"`
+}
+
+func New(cfg config.BudgetConfig) (*Tracker, error) {
+	t := &Tracker{
+		path: cfg.File,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	t.Update(cfg)
+
+	t.spend = spend{Month: currentMonth(), Keys: make(map[string]float64)}
+	if t.path == "" {
+		close(t.done)
+		return t, nil
+	}
+
+	data, err := os.ReadFile(t.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		var saved spend
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", t.path, err)
+		}
+		if saved.Month == t.spend.Month {
+			t.spend.Total = saved.Total
+			for key, amount := range saved.Keys {
+				t.spend.Keys[key] = amount
+			}
+		}
+	}
+
+	go t.flushLoop()
+	return t, nil
+}
+
+// Update replaces the limits, e.g. after a config reload. The spend file is
+// only read at startup.
+func (t *Tracker) Update(cfg config.BudgetConfig) {
+	keys := make(map[string]float64, len(cfg.Keys))
+	for key, limit := range cfg.Keys {
+		keys[auth.HashKey(key)] = limit
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.monthly = cfg.Monthly
+	t.perKey = cfg.PerKey
+	t.keys = keys
+}
+
+// Enabled reports whether any budget is set.
+func (t *Tracker) Enabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.monthly > 0 || t.perKey > 0 || len(t.keys) > 0
+}
+
+// Check returns the scope of the first budget that keyHash has exhausted,
+// or "" when both still have room.
+func (t *Tracker) Check(keyHash string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	if t.monthly > 0 && t.spend.Total >= t.monthly {
+		return ScopeGlobal
+	}
+	if limit := t.keyLimit(keyHash); limit > 0 && t.spend.Keys[keyHash] >= limit {
+		return ScopeKey
+	}
+	return ""
+}
+
+// Record charges cost to keyHash and the global total. The spend file is
+// written on the next flush.
+func (t *Tracker) Record(keyHash string, cost float64) {
+	if cost <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	t.spend.Total += cost
+	t.spend.Keys[keyHash] += cost
+	t.dirty = true
+}
+
+// Flush writes the spending recorded since the last flush to the spend file.
+func (t *Tracker) Flush() error {
+	if t.path == "" {
+		return nil
+	}
+
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(t.spend)
+	t.dirty = false
+	t.mu.Unlock()
+	if err == nil {
+		err = t.save(data)
+	}
+
+	if err != nil {
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+	}
+	return err
+}
+
+// Close stops the periodic flush and writes any spending not yet saved.
+func (t *Tracker) Close() error {
+	t.once.Do(func() { close(t.stop) })
+	<-t.done
+	return t.Flush()
+}
+
+func (t *Tracker) flushLoop() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				log.Printf("Warning: failed to save spending: %v", err)
+			}
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+func (t *Tracker) keyLimit(keyHash string) float64 {
+	if limit, ok := t.keys[keyHash]; ok {
+		return limit
+	}
+	return t.perKey
+}
+
+// rollover starts a new month's spending. t.mu must be held.
+func (t *Tracker) rollover() {
+	if month := currentMonth(); month != t.spend.Month {
+		t.spend = spend{Month: month, Keys: make(map[string]float64)}
+	}
+}
+
+func (t *Tracker) save(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o700); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func currentMonth() string {
+	return time.Now().UTC().Format("2006-01")
+}
+
+// Cost is what usage costs at pricing, in US dollars. Models without
+// pricing cost nothing.
+func Cost(pricing *models.ModelPricing, usage models.Usage) float64 {
+	if pricing == nil {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*pricing.Input + float64(usage.CompletionTokens)*pricing.Output) / 1e6
+}
@@ -0,0 +1,157 @@
+package budget
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func TestTrackerCheck(t *testing.T) {
+	alice, bob := auth.HashKey("alice-key"), auth.HashKey("bob-key")
+
+	type charge struct {
+		key  string
+		cost float64
+	}
+	tests := []struct {
+		name    string
+		cfg     config.BudgetConfig
+		charges []charge
+		key     string
+		want    string
+	}{
+		{name: "no budgets", charges: []charge{{alice, 1000}}, key: alice, want: ""},
+		{name: "under global", cfg: config.BudgetConfig{Monthly: 10}, charges: []charge{{alice, 4}, {bob, 5}}, key: alice, want: ""},
+		{name: "global spent by others", cfg: config.BudgetConfig{Monthly: 10}, charges: []charge{{alice, 4}, {bob, 6}}, key: alice, want: ScopeGlobal},
+		{name: "per key spent", cfg: config.BudgetConfig{PerKey: 5}, charges: []charge{{alice, 5}}, key: alice, want: ScopeKey},
+		{name: "per key spent by another key", cfg: config.BudgetConfig{PerKey: 5}, charges: []charge{{bob, 5}}, key: alice, want: ""},
+		{name: "key limit overrides per key", cfg: config.BudgetConfig{PerKey: 5, Keys: map[string]float64{"alice-key": 20}}, charges: []charge{{alice, 10}}, key: alice, want: ""},
+		{name: "key limit spent", cfg: config.BudgetConfig{Keys: map[string]float64{"alice-key": 2}}, charges: []charge{{alice, 2}}, key: alice, want: ScopeKey},
+		{name: "global reported first", cfg: config.BudgetConfig{Monthly: 1, PerKey: 1}, charges: []charge{{alice, 1}}, key: alice, want: ScopeGlobal},
+		{name: "free requests are not charged", cfg: config.BudgetConfig{PerKey: 1}, charges: []charge{{alice, 0}, {alice, -1}}, key: alice, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := New(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tracker.Close()
+
+			for _, c := range tt.charges {
+				tracker.Record(c.key, c.cost)
+			}
+			if got := tracker.Check(tt.key); got != tt.want {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackerRollover(t *testing.T) {
+	key := auth.HashKey("alice-key")
+
+	tests := []struct {
+		name  string
+		month string
+		want  string
+	}{
+		{name: "same month", month: currentMonth(), want: ScopeKey},
+		{name: "new month", month: "2000-01", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := New(config.BudgetConfig{PerKey: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tracker.Close()
+
+			tracker.Record(key, 1)
+			tracker.spend.Month = tt.month
+
+			if got := tracker.Check(key); got != tt.want {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackerPersistence(t *testing.T) {
+	key := auth.HashKey("alice-key")
+
+	tests := []struct {
+		name  string
+		saved *spend
+		flush func(*Tracker) error
+		want  float64
+	}{
+		{name: "close flushes", flush: (*Tracker).Close, want: 3},
+		{name: "flush", flush: (*Tracker).Flush, want: 3},
+		{name: "adds to saved spending", saved: &spend{Month: currentMonth(), Total: 2, Keys: map[string]float64{key: 2}}, flush: (*Tracker).Close, want: 5},
+		{name: "ignores a past month", saved: &spend{Month: "2000-01", Total: 2, Keys: map[string]float64{key: 2}}, flush: (*Tracker).Close, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "spend", "spend.json")
+			if tt.saved != nil {
+				data, _ := json.Marshal(tt.saved)
+				os.MkdirAll(filepath.Dir(path), 0o700)
+				if err := os.WriteFile(path, data, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tracker, err := New(config.BudgetConfig{File: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracker.Record(key, 3)
+			if err := tt.flush(tracker); err != nil {
+				t.Fatal(err)
+			}
+			tracker.Close()
+
+			reopened, err := New(config.BudgetConfig{File: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			if got := reopened.spend.Keys[key]; got != tt.want {
+				t.Errorf("saved spending for key = %v, want %v", got, tt.want)
+			}
+			if got := reopened.spend.Total; got != tt.want {
+				t.Errorf("saved total = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		pricing *models.ModelPricing
+		usage   models.Usage
+		want    float64
+	}{
+		{name: "unpriced", usage: models.Usage{PromptTokens: 1000}, want: 0},
+		{name: "input and output", pricing: &models.ModelPricing{Input: 2, Output: 8}, usage: models.Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000}, want: 6},
+		{name: "input only", pricing: &models.ModelPricing{Input: 0.1}, usage: models.Usage{PromptTokens: 10_000}, want: 0.001},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Cost(tt.pricing, tt.usage); got != tt.want {
+				t.Errorf("Cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
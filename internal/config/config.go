@@ -1,8 +1,14 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,26 +20,44 @@ type Config struct {
 	Logging        LoggingConfig            `yaml:"logging"`
 	Auth           AuthConfig               `yaml:"auth"`
 	RateLimit      RateLimitConfig          `yaml:"rate_limit"`
+	Responses      ResponsesConfig          `yaml:"responses"`
+	Batch          BatchConfig              `yaml:"batch"`
+	Structured     StructuredOutputConfig   `yaml:"structured_output"`
+	Sampling       SamplingConfig           `yaml:"sampling"`
+	Reload         ReloadConfig             `yaml:"reload"`
 	DefaultBackend string                   `yaml:"default_backend"`
 	Backends       map[string]BackendConfig `yaml:"backends"`
+	Routes         []RouteConfig            `yaml:"routes"`
+	VirtualModels  []VirtualModelConfig     `yaml:"virtual_models"`
+	ModelConflicts string                   `yaml:"model_conflicts"`
+	Budget         BudgetConfig             `yaml:"budget"`
+
+	// SecretFiles lists the files that secret references were read from.
+	SecretFiles []string `yaml:"-"`
 }
 
 type ServerConfig struct {
-	Host         string        `yaml:"host"`
-	Port         int           `yaml:"port"`
-	ReadTimeout  time.Duration `yaml:"read_timeout"`
-	WriteTimeout time.Duration `yaml:"write_timeout"`
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
 }
 
 type LoggingConfig struct {
-	Level  string `yaml:"level"`
-	Format string `yaml:"format"`
+	Level     string `yaml:"level"`
+	Format    string `yaml:"format"`
+	AuditFile string `yaml:"audit_file"`
 }
 
 type AuthConfig struct {
-	Enabled  bool     `yaml:"enabled"`
-	Keys     []string `yaml:"keys"`
-	KeysFile string   `yaml:"keys_file"`
+	Enabled    bool           `yaml:"enabled"`
+	Keys       []string       `yaml:"keys" secret:"true"`
+	KeysFile   string         `yaml:"keys_file"`
+	Priorities map[string]int `yaml:"priorities"`
+	// ToolPolicies restricts agent tools per API key, on top of the
+	// backend's own policy.
+	ToolPolicies map[string]ToolPolicyConfig `yaml:"tool_policies"`
 }
 
 type RateLimitConfig struct {
@@ -42,28 +66,237 @@ type RateLimitConfig struct {
 	Burst             int  `yaml:"burst"`
 }
 
+type ResponsesConfig struct {
+	StoreTTL  time.Duration `yaml:"store_ttl"`
+	MaxStored int           `yaml:"max_stored"`
+}
+
+// StructuredOutputConfig controls response_format emulation for backends
+// without native JSON mode.
+type StructuredOutputConfig struct {
+	MaxRetries int `yaml:"max_retries"`
+}
+
+const (
+	ParamPolicyLenient = "lenient"
+	ParamPolicyStrict  = "strict"
+)
+
+// SamplingConfig decides what happens to sampling parameters that the chosen
+// backend cannot honour and the gateway cannot emulate. Lenient drops them
+// and lists them in a response header; strict rejects the request.
+type SamplingConfig struct {
+	UnsupportedParams string `yaml:"unsupported_params"`
+}
+
+// ReloadConfig controls how often the config file and keys file are checked
+// for changes. A zero interval disables polling; SIGHUP always reloads.
+type ReloadConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// VirtualModelConfig defines a model name that stands for another model with
+// a fixed system prompt, default sampling parameters and a tool policy.
+type VirtualModelConfig struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description"`
+	// Model is the underlying model, optionally as "backend/model". It goes
+	// through the routing table like any requested model.
+	Model        string `yaml:"model"`
+	SystemPrompt string `yaml:"system_prompt"`
+	// Defaults apply only to parameters the request leaves unset.
+	Defaults VirtualModelDefaults `yaml:"defaults"`
+	// Tools narrows the agent tools allowed on top of the backend and key
+	// policies.
+	Tools ToolPolicyConfig `yaml:"tools"`
+}
+
+type VirtualModelDefaults struct {
+	Temperature      *float64 `yaml:"temperature"`
+	TopP             *float64 `yaml:"top_p"`
+	MaxTokens        *int     `yaml:"max_tokens"`
+	Stop             []string `yaml:"stop"`
+	Seed             *int     `yaml:"seed"`
+	PresencePenalty  *float64 `yaml:"presence_penalty"`
+	FrequencyPenalty *float64 `yaml:"frequency_penalty"`
+}
+
+// RouteConfig sends requests for matching models to a backend, optionally
+// under another model name. Routes are tried in order and the first match
+// wins; requests that name a backend explicitly bypass the table.
+type RouteConfig struct {
+	Name string `yaml:"name"`
+	// Match is a glob on the requested model, where "*" matches any run of
+	// characters and "?" a single one. Regex is an alternative; exactly one
+	// of them is set.
+	Match string `yaml:"match"`
+	Regex string `yaml:"regex"`
+	// Headers must all be present on the request, with values matching the
+	// given globs. Keys restricts the route to these API keys.
+	Headers map[string]string `yaml:"headers"`
+	Keys    []string          `yaml:"keys" secret:"true"`
+	Backend string            `yaml:"backend"`
+	// Model replaces the requested model; $1, $2... refer to the wildcards
+	// or regex groups. Empty keeps the requested model.
+	Model string `yaml:"model"`
+}
+
+// Pattern compiles the route's match condition.
+func (r RouteConfig) Pattern() (*regexp.Regexp, error) {
+	if r.Regex != "" {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			return nil, err
+		}
+		return regexp.MustCompile("^(?:" + r.Regex + ")$"), nil
+	}
+	return Glob(r.Match), nil
+}
+
+// Glob compiles a pattern where "*" matches any run of characters and "?" a
+// single one. Each wildcard is a capture group.
+func Glob(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString("(.*)")
+		case '?':
+			b.WriteString("(.)")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// BudgetConfig caps monthly spending on models that are not free, in US
+// dollars, using the models' pricing and the token usage of each request.
+// Zero means no limit. Spending is kept in File so it survives restarts.
+type BudgetConfig struct {
+	Monthly float64 `yaml:"monthly"`
+	// PerKey applies to every API key without its own entry in Keys.
+	PerKey float64            `yaml:"per_key"`
+	Keys   map[string]float64 `yaml:"keys"`
+	File   string             `yaml:"file"`
+}
+
+// BatchConfig controls the /v1/files and /v1/batches job store.
+type BatchConfig struct {
+	Dir         string `yaml:"dir"`
+	Concurrency int    `yaml:"concurrency"`
+	MaxFileSize int64  `yaml:"max_file_size"`
+}
+
+const (
+	ModelConflictsWarn  = "warn"
+	ModelConflictsError = "error"
+)
+
 type BackendConfig struct {
-	Enabled bool          `yaml:"enabled"`
-	Type    string        `yaml:"type"`
-	Host    string        `yaml:"host"`
-	Port    int           `yaml:"port"`
-	Timeout time.Duration `yaml:"timeout"`
-	Models  []ModelConfig `yaml:"models"`
+	Enabled     bool              `yaml:"enabled"`
+	Type        string            `yaml:"type"`
+	Host        string            `yaml:"host"`
+	Port        int               `yaml:"port"`
+	BaseURL     string            `yaml:"base_url"`
+	APIKey      string            `yaml:"api_key" secret:"true"`
+	Timeout     time.Duration     `yaml:"timeout"`
+	Concurrency ConcurrencyConfig `yaml:"concurrency"`
+	Retry       RetryConfig       `yaml:"retry"`
+	Streaming   StreamingConfig   `yaml:"streaming"`
+	Tools       ToolPolicyConfig  `yaml:"tools"`
+	Embeddings  EmbeddingsConfig  `yaml:"embeddings"`
+	Discovery   DiscoveryConfig   `yaml:"discovery"`
+	// Priority decides which backend serves a model or alias that several
+	// of them offer: the default backend first, then the highest priority.
+	Priority int           `yaml:"priority"`
+	Models   []ModelConfig `yaml:"models"`
+}
+
+// DiscoveryConfig controls how often a backend's model list is refreshed
+// from the upstream.
+type DiscoveryConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+type EmbeddingsConfig struct {
+	BatchSize int `yaml:"batch_size"`
+}
+
+const (
+	ToolModeAllowAll  = "allow_all"
+	ToolModeDisabled  = "disabled"
+	ToolModeAllowList = "allow_list"
+)
+
+// ToolPolicyConfig controls which agent tools a backend may run. An empty
+// mode allows every tool.
+type ToolPolicyConfig struct {
+	Mode  string   `yaml:"mode"`
+	Allow []string `yaml:"allow"`
+}
+
+type StreamingConfig struct {
+	FirstTokenTimeout time.Duration `yaml:"first_token_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+}
+
+type ConcurrencyConfig struct {
+	MaxConcurrent int            `yaml:"max_concurrent"`
+	MaxQueue      int            `yaml:"max_queue"`
+	QueueTimeout  time.Duration  `yaml:"queue_timeout"`
+	Models        map[string]int `yaml:"models"`
+}
+
+type RetryConfig struct {
+	MaxAttempts     int           `yaml:"max_attempts"`
+	InitialBackoff  time.Duration `yaml:"initial_backoff"`
+	MaxBackoff      time.Duration `yaml:"max_backoff"`
+	Multiplier      float64       `yaml:"multiplier"`
+	Jitter          *float64      `yaml:"jitter"`
+	RetryableStatus []int         `yaml:"retryable_status"`
 }
 
 type ModelConfig struct {
 	ID      string   `yaml:"id"`
 	Aliases []string `yaml:"aliases"`
 	Free    bool     `yaml:"free"`
+
+	// Metadata for /v1/models. Values set here win over what the backend
+	// reports; zero values leave the reported ones alone.
+	Created         int64                   `yaml:"created"`
+	ContextWindow   int                     `yaml:"context_window"`
+	MaxOutputTokens int                     `yaml:"max_output_tokens"`
+	Capabilities    ModelCapabilitiesConfig `yaml:"capabilities"`
+	Pricing         *ModelPricingConfig     `yaml:"pricing"`
+	Deprecated      bool                    `yaml:"deprecated"`
+	DeprecationDate string                  `yaml:"deprecation_date"`
+	ReplacedBy      string                  `yaml:"replaced_by"`
+}
+
+type ModelCapabilitiesConfig struct {
+	Tools     *bool `yaml:"tools"`
+	Vision    *bool `yaml:"vision"`
+	Streaming *bool `yaml:"streaming"`
+	Reasoning *bool `yaml:"reasoning"`
+}
+
+// ModelPricingConfig is in US dollars per million tokens.
+type ModelPricingConfig struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
 }
 
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host:         "0.0.0.0",
-			Port:         8080,
-			ReadTimeout:  30 * time.Second,
-			WriteTimeout: 120 * time.Second,
+			Host:              "0.0.0.0",
+			Port:              8080,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      120 * time.Second,
+			HeartbeatInterval: 15 * time.Second,
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -78,6 +311,28 @@ func DefaultConfig() *Config {
 			RequestsPerMinute: 60,
 			Burst:             10,
 		},
+		Responses: ResponsesConfig{
+			StoreTTL:  24 * time.Hour,
+			MaxStored: 1000,
+		},
+		Structured: StructuredOutputConfig{
+			MaxRetries: 2,
+		},
+		Sampling: SamplingConfig{
+			UnsupportedParams: ParamPolicyLenient,
+		},
+		Reload: ReloadConfig{
+			PollInterval: 5 * time.Second,
+		},
+		ModelConflicts: ModelConflictsWarn,
+		Budget: BudgetConfig{
+			File: "data/spend.json",
+		},
+		Batch: BatchConfig{
+			Dir:         "data/batches",
+			Concurrency: 4,
+			MaxFileSize: 100 << 20,
+		},
 		DefaultBackend: "opencode",
 		Backends: map[string]BackendConfig{
 			"opencode": {
@@ -97,26 +352,58 @@ func DefaultConfig() *Config {
 	}
 }
 
+// Load reads the config file at path over the defaults. An empty path
+// yields the defaults; a path that does not exist is an error.
 func Load(path string) (*Config, error) {
 	godotenv.Load()
 
 	cfg := DefaultConfig()
 
+	var data []byte
 	if path != "" {
-		data, err := os.ReadFile(path)
-		if err == nil {
-			yaml.Unmarshal(data, cfg)
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	data, err := preprocess(data, true)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := decodeStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	cfg.SecretFiles, err = resolveSecrets(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	applyEnvOverrides(cfg)
 
 	if cfg.Auth.KeysFile != "" {
-		keys, err := loadKeysFile(cfg.Auth.KeysFile)
+		keys, files, err := loadKeysFile(cfg.Auth.KeysFile)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s: %w", cfg.Auth.KeysFile, err)
+		}
+		cfg.SecretFiles = append(cfg.SecretFiles, files...)
+		if cfg.Auth.Priorities == nil {
+			cfg.Auth.Priorities = make(map[string]int)
+		}
+		if cfg.Auth.ToolPolicies == nil {
+			cfg.Auth.ToolPolicies = make(map[string]ToolPolicyConfig)
+		}
+		for _, k := range keys {
+			cfg.Auth.Keys = append(cfg.Auth.Keys, k.Key)
+			if k.Priority != 0 {
+				cfg.Auth.Priorities[k.Key] = k.Priority
+			}
+			if k.Tools != nil {
+				cfg.Auth.ToolPolicies[k.Key] = *k.Tools
+			}
 		}
-		cfg.Auth.Keys = append(cfg.Auth.Keys, keys...)
 	}
 
 	return cfg, nil
@@ -181,28 +468,51 @@ type KeysFileConfig struct {
 }
 
 type KeyConfig struct {
-	Key     string `yaml:"key"`
-	Name    string `yaml:"name"`
-	Enabled bool   `yaml:"enabled"`
+	Key      string            `yaml:"key" secret:"true"`
+	Name     string            `yaml:"name"`
+	Enabled  bool              `yaml:"enabled"`
+	Priority int               `yaml:"priority"`
+	Tools    *ToolPolicyConfig `yaml:"tools"`
 }
 
-func loadKeysFile(path string) ([]string, error) {
+func loadKeysFile(path string) ([]KeyConfig, []string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	data, err = preprocess(data, false)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var keysFile KeysFileConfig
-	if err := yaml.Unmarshal(data, &keysFile); err != nil {
-		return nil, err
+	if err := decodeStrict(data, &keysFile); err != nil {
+		return nil, nil, err
+	}
+
+	files, err := resolveSecrets(&keysFile)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	var keys []string
+	var keys []KeyConfig
 	for _, k := range keysFile.Keys {
 		if k.Enabled {
-			keys = append(keys, k.Key)
+			keys = append(keys, k)
 		}
 	}
 
-	return keys, nil
+	return keys, files, nil
+}
+
+// decodeStrict decodes YAML into out and rejects fields that out does not
+// have, so that a misspelt key is an error instead of a silent default.
+func decodeStrict(data []byte, out interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvOverridePrefix starts environment variables that set a single config
+// field, e.g. AI_GATEWAY__BACKENDS__OLLAMA__HOST sets backends.ollama.host.
+const EnvOverridePrefix = "AI_GATEWAY__"
+
+var envRef = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// preprocess expands ${VAR} and ${VAR:-default} in every value of a YAML
+// document and, when overrides is set, applies AI_GATEWAY__ variables on
+// top. The result is YAML again, so it can be decoded strictly.
+func preprocess(data []byte, overrides bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		if !overrides {
+			return data, nil
+		}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	if err := expandNode(&doc); err != nil {
+		return nil, err
+	}
+	if overrides {
+		if err := applyOverrides(doc.Content[0], os.Environ()); err != nil {
+			return nil, err
+		}
+	}
+	return yaml.Marshal(&doc)
+}
+
+func expandNode(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		value, err := expandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if value != node.Value {
+			node.Value = value
+			// Let plain values be re-typed, so "${PORT}" can become an int.
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+		return nil
+	}
+
+	for _, child := range node.Content {
+		if err := expandNode(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} references; "$$" stands for
+// a literal "$". Referencing an unset variable without a default is an
+// error rather than an empty value.
+func expandEnv(s string) (string, error) {
+	var missing []string
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		m := envRef.FindStringSubmatch(ref)
+		if value := os.Getenv(m[1]); value != "" {
+			return value
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		if _, ok := os.LookupEnv(m[1]); !ok {
+			missing = append(missing, m[1])
+		}
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// applyOverrides sets the fields named by AI_GATEWAY__ variables. Path
+// segments are separated by "__" and lower-cased; numeric segments index
+// into lists. Values are parsed as YAML, so lists can be given as [a, b].
+func applyOverrides(root *yaml.Node, environ []string) error {
+	var names []string
+	values := make(map[string]string)
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvOverridePrefix) {
+			continue
+		}
+		names = append(names, name)
+		values[name] = value
+	}
+	// Shorter paths first, so that a whole section can be set and then
+	// refined by a more specific variable.
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(name, EnvOverridePrefix)), "__")
+		var value yaml.Node
+		if err := yaml.Unmarshal([]byte(values[name]), &value); err != nil || value.Kind == 0 {
+			value = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: values[name]}}}
+		}
+		if err := setPath(root, path, value.Content[0]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setPath(node *yaml.Node, path []string, value *yaml.Node) error {
+	key := path[0]
+	if key == "" {
+		return fmt.Errorf("empty path segment")
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			if node.Content[i].Value != key {
+				continue
+			}
+			if len(path) == 1 {
+				node.Content[i+1] = value
+				return nil
+			}
+			if child := node.Content[i+1]; child.Kind == yaml.ScalarNode && child.Tag == "!!null" {
+				node.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			return setPath(node.Content[i+1], path[1:], value)
+		}
+
+		child := value
+		if len(path) > 1 {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if err := setPath(child, path[1:], value); err != nil {
+				return err
+			}
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+		return nil
+
+	case yaml.SequenceNode:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(node.Content) {
+			return fmt.Errorf("invalid list index %q", key)
+		}
+		if i == len(node.Content) {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+		}
+		if len(path) == 1 {
+			node.Content[i] = value
+			return nil
+		}
+		return setPath(node.Content[i], path[1:], value)
+	}
+
+	return fmt.Errorf("%q is not a section", key)
+}
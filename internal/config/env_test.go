@@ -0,0 +1,115 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		environ []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "replaces a value",
+			yaml:    "server: {port: 8080}",
+			environ: []string{"AI_GATEWAY__SERVER__PORT=9090"},
+			want:    "server: {port: 9090}",
+		},
+		{
+			name:    "adds a missing section",
+			yaml:    "server: {port: 8080}",
+			environ: []string{"AI_GATEWAY__BACKENDS__OLLAMA__HOST=gpu-box"},
+			want:    "server: {port: 8080}\nbackends: {ollama: {host: gpu-box}}",
+		},
+		{
+			name:    "fills a null section",
+			yaml:    "backends:",
+			environ: []string{"AI_GATEWAY__BACKENDS__OLLAMA__PORT=11434"},
+			want:    "backends: {ollama: {port: 11434}}",
+		},
+		{
+			name:    "indexes and appends to lists",
+			yaml:    "routes: [{match: a}]",
+			environ: []string{"AI_GATEWAY__ROUTES__0__BACKEND=x", "AI_GATEWAY__ROUTES__1__MATCH=b"},
+			want:    "routes: [{match: a, backend: x}, {match: b}]",
+		},
+		{
+			name:    "parses YAML values",
+			yaml:    "auth: {}",
+			environ: []string{"AI_GATEWAY__AUTH__KEYS=[k1, k2]"},
+			want:    "auth: {keys: [k1, k2]}",
+		},
+		{
+			name:    "whole section first, then a field",
+			yaml:    "",
+			environ: []string{"AI_GATEWAY__SERVER__HOST=h", "AI_GATEWAY__SERVER={port: 1, host: x}"},
+			want:    "server: {port: 1, host: h}",
+		},
+		{
+			name:    "ignores other variables",
+			yaml:    "a: 1",
+			environ: []string{"PATH=/bin", "AI_GATEWAY_PORT=1"},
+			want:    "a: 1",
+		},
+		{
+			name:    "list index out of range",
+			yaml:    "routes: [{match: a}]",
+			environ: []string{"AI_GATEWAY__ROUTES__5__MATCH=b"},
+			wantErr: `invalid list index "5"`,
+		},
+		{
+			name:    "path through a scalar",
+			yaml:    "server: {port: 8080}",
+			environ: []string{"AI_GATEWAY__SERVER__PORT__X=1"},
+			wantErr: `"x" is not a section`,
+		},
+		{
+			name:    "empty segment",
+			yaml:    "a: 1",
+			environ: []string{"AI_GATEWAY__A____B=1"},
+			wantErr: "empty path segment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if tt.yaml != "" {
+				var doc yaml.Node
+				if err := yaml.Unmarshal([]byte(tt.yaml), &doc); err != nil {
+					t.Fatal(err)
+				}
+				root = doc.Content[0]
+			}
+
+			err := applyOverrides(root, tt.environ)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, want interface{}
+			if err := root.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
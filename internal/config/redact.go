@@ -0,0 +1,57 @@
+package config
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "[redacted]"
+
+// Redacted returns a copy of the config that is safe to print: every field
+// tagged secret:"true" is replaced, and API keys used as map keys are
+// masked.
+func (c *Config) Redacted() *Config {
+	// A YAML round trip gives a deep copy, so the maps and slices of c are
+	// left alone.
+	out := &Config{}
+	if data, err := yaml.Marshal(c); err == nil {
+		yaml.Unmarshal(data, out)
+	}
+
+	walkSecrets(reflect.ValueOf(out).Elem(), "", func(string, string) (string, error) {
+		return redactedValue, nil
+	})
+
+	if out.Auth.Priorities != nil {
+		priorities := make(map[string]int, len(out.Auth.Priorities))
+		for key, priority := range out.Auth.Priorities {
+			priorities[maskKey(key)] = priority
+		}
+		out.Auth.Priorities = priorities
+	}
+	if out.Auth.ToolPolicies != nil {
+		policies := make(map[string]ToolPolicyConfig, len(out.Auth.ToolPolicies))
+		for key, policy := range out.Auth.ToolPolicies {
+			policies[maskKey(key)] = policy
+		}
+		out.Auth.ToolPolicies = policies
+	}
+	if out.Budget.Keys != nil {
+		budgets := make(map[string]float64, len(out.Budget.Keys))
+		for key, budget := range out.Budget.Keys {
+			budgets[maskKey(key)] = budget
+		}
+		out.Budget.Keys = budgets
+	}
+	return out
+}
+
+// maskKey keeps just enough of an API key to tell keys apart, like
+// auth.MaskKey.
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRedacted(t *testing.T) {
+	const key = "sk-live-0123456789"
+	const masked = "sk-l...6789"
+
+	tests := []struct {
+		name string
+		cfg  Config
+		got  func(*Config) interface{}
+		want interface{}
+	}{
+		{
+			name: "auth keys",
+			cfg:  Config{Auth: AuthConfig{Keys: []string{key, "short"}}},
+			got:  func(c *Config) interface{} { return c.Auth.Keys },
+			want: []string{redactedValue, redactedValue},
+		},
+		{
+			name: "backend api key",
+			cfg:  Config{Backends: map[string]BackendConfig{"openai": {APIKey: key, Host: "api"}}},
+			got:  func(c *Config) interface{} { return []string{c.Backends["openai"].APIKey, c.Backends["openai"].Host} },
+			want: []string{redactedValue, "api"},
+		},
+		{
+			name: "empty secrets stay empty",
+			cfg:  Config{Backends: map[string]BackendConfig{"ollama": {Host: "local"}}},
+			got:  func(c *Config) interface{} { return c.Backends["ollama"].APIKey },
+			want: "",
+		},
+		{
+			name: "route keys",
+			cfg:  Config{Routes: []RouteConfig{{Match: "*", Keys: []string{key}, Backend: "b"}}},
+			got:  func(c *Config) interface{} { return []string{c.Routes[0].Keys[0], c.Routes[0].Backend} },
+			want: []string{redactedValue, "b"},
+		},
+		{
+			name: "priority map keys",
+			cfg:  Config{Auth: AuthConfig{Priorities: map[string]int{key: 5, "short": 1}}},
+			got:  func(c *Config) interface{} { return c.Auth.Priorities },
+			want: map[string]int{masked: 5, "****": 1},
+		},
+		{
+			name: "tool policy map keys",
+			cfg:  Config{Auth: AuthConfig{ToolPolicies: map[string]ToolPolicyConfig{key: {Mode: "allow_list", Allow: []string{"read"}}}}},
+			got:  func(c *Config) interface{} { return c.Auth.ToolPolicies },
+			want: map[string]ToolPolicyConfig{masked: {Mode: "allow_list", Allow: []string{"read"}}},
+		},
+		{
+			name: "budget map keys",
+			cfg:  Config{Budget: BudgetConfig{Keys: map[string]float64{key: 10}}},
+			got:  func(c *Config) interface{} { return c.Budget.Keys },
+			want: map[string]float64{masked: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Printed, maps are sorted, so this is a stable deep copy.
+			before := fmt.Sprintf("%#v", tt.got(&tt.cfg))
+
+			if got := tt.got(tt.cfg.Redacted()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redacted = %#v, want %#v", got, tt.want)
+			}
+			if after := fmt.Sprintf("%#v", tt.got(&tt.cfg)); after != before {
+				t.Errorf("Redacted changed the original config: %s", after)
+			}
+		})
+	}
+}
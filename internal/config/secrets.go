@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Fields tagged secret:"true" may hold a reference instead of the value
+// itself: "file:/run/secrets/name" reads the file (trailing newlines are
+// dropped) and "env:NAME" reads an environment variable. References are
+// resolved when the config is loaded, so a reload picks up rotated secrets.
+const (
+	secretFilePrefix = "file:"
+	secretEnvPrefix  = "env:"
+)
+
+// resolveSecrets replaces secret references in every tagged field of the
+// struct that v points to. It returns the files that were read, so that
+// callers can watch them for changes.
+func resolveSecrets(v interface{}) ([]string, error) {
+	var files []string
+	err := walkSecrets(reflect.ValueOf(v).Elem(), "", func(field string, value string) (string, error) {
+		secret, file, err := resolveSecret(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", field, err)
+		}
+		if file != "" {
+			files = append(files, file)
+		}
+		return secret, nil
+	})
+	return files, err
+}
+
+func resolveSecret(value string) (secret, file string, err error) {
+	switch {
+	case strings.HasPrefix(value, secretFilePrefix):
+		file = strings.TrimPrefix(value, secretFilePrefix)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", "", fmt.Errorf("reading secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), file, nil
+	case strings.HasPrefix(value, secretEnvPrefix):
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return secret, "", nil
+	}
+	return value, "", nil
+}
+
+// walkSecrets calls fn for every string, or string in a list, held by a
+// field tagged secret:"true", and stores what fn returns. Maps and slices
+// of structs are walked too.
+func walkSecrets(v reflect.Value, path string, fn func(field, value string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			field := name
+			if path != "" {
+				field = path + "." + name
+			}
+
+			if f.Tag.Get("secret") == "true" {
+				if err := setSecret(v.Field(i), field, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := walkSecrets(v.Field(i), field, fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			if err := walkSecrets(elem, fmt.Sprintf("%s.%v", path, iter.Key()), fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkSecrets(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return walkSecrets(v.Elem(), path, fn)
+		}
+	}
+	return nil
+}
+
+func setSecret(v reflect.Value, field string, fn func(field, value string) (string, error)) error {
+	switch {
+	case v.Kind() == reflect.String:
+		if v.String() == "" {
+			return nil
+		}
+		secret, err := fn(field, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(secret)
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < v.Len(); i++ {
+			if err := setSecret(v.Index(i), fmt.Sprintf("%s[%d]", field, i), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
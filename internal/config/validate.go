@@ -0,0 +1,294 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var backendTypes = map[string]bool{
+	"opencode": true,
+	"openai":   true,
+	"ollama":   true,
+}
+
+// Validate checks the config for mistakes that decoding cannot catch and
+// reports all of them at once.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	checkPort := func(field string, port int) {
+		if port < 1 || port > 65535 {
+			fail(field, "port %d is out of range 1-65535", port)
+		}
+	}
+
+	checkPort("server.port", c.Server.Port)
+	if c.Server.ReadTimeout <= 0 {
+		fail("server.read_timeout", "must be positive")
+	}
+	if c.Server.WriteTimeout <= 0 {
+		fail("server.write_timeout", "must be positive")
+	}
+	if c.Server.HeartbeatInterval < 0 {
+		fail("server.heartbeat_interval", "must not be negative")
+	}
+
+	if c.RateLimit.Enabled && c.RateLimit.RequestsPerMinute <= 0 {
+		fail("rate_limit.requests_per_minute", "must be positive when rate limiting is enabled")
+	}
+	if c.Responses.StoreTTL <= 0 {
+		fail("responses.store_ttl", "must be positive")
+	}
+	if c.Batch.Concurrency <= 0 {
+		fail("batch.concurrency", "must be positive")
+	}
+	if c.Batch.MaxFileSize <= 0 {
+		fail("batch.max_file_size", "must be positive")
+	}
+	if c.Structured.MaxRetries < 0 {
+		fail("structured_output.max_retries", "must not be negative")
+	}
+	if p := c.Sampling.UnsupportedParams; p != ParamPolicyLenient && p != ParamPolicyStrict {
+		fail("sampling.unsupported_params", "must be %q or %q, got %q", ParamPolicyLenient, ParamPolicyStrict, p)
+	}
+	if c.Reload.PollInterval < 0 {
+		fail("reload.poll_interval", "must not be negative")
+	}
+
+	if c.Budget.Monthly < 0 {
+		fail("budget.monthly", "must not be negative")
+	}
+	if c.Budget.PerKey < 0 {
+		fail("budget.per_key", "must not be negative")
+	}
+	for key, budget := range c.Budget.Keys {
+		if budget < 0 {
+			fail(fmt.Sprintf("budget.keys[%s]", maskKey(key)), "must not be negative")
+		}
+	}
+	if c.Budget.File == "" && (c.Budget.Monthly > 0 || c.Budget.PerKey > 0 || len(c.Budget.Keys) > 0) {
+		fail("budget.file", "must be set when a budget is configured")
+	}
+
+	for key, policy := range c.Auth.ToolPolicies {
+		checkToolPolicy(fmt.Sprintf("auth.tool_policies[%s]", maskKey(key)), policy, fail)
+	}
+
+	if c.DefaultBackend != "" {
+		if b, ok := c.Backends[c.DefaultBackend]; !ok {
+			fail("default_backend", "backend %q is not defined", c.DefaultBackend)
+		} else if !b.Enabled {
+			fail("default_backend", "backend %q is disabled", c.DefaultBackend)
+		}
+	}
+
+	for _, id := range sortedBackends(c.Backends) {
+		b := c.Backends[id]
+		field := "backends." + id
+
+		checkModels(field, b.Models, fail)
+		if !b.Enabled {
+			continue
+		}
+
+		if !backendTypes[b.Type] {
+			fail(field+".type", "unsupported type %q", b.Type)
+		}
+		if b.BaseURL == "" && (b.Type != "ollama" || b.Port != 0) {
+			checkPort(field+".port", b.Port)
+		}
+		if b.Timeout <= 0 {
+			fail(field+".timeout", "must be positive")
+		}
+		if b.Streaming.FirstTokenTimeout < 0 {
+			fail(field+".streaming.first_token_timeout", "must not be negative")
+		}
+		if b.Streaming.IdleTimeout < 0 {
+			fail(field+".streaming.idle_timeout", "must not be negative")
+		}
+		if b.Discovery.Interval < 0 {
+			fail(field+".discovery.interval", "must not be negative")
+		}
+		if b.Concurrency.MaxConcurrent < 0 {
+			fail(field+".concurrency.max_concurrent", "must not be negative")
+		}
+		for model := range b.Concurrency.Models {
+			if len(b.Models) > 0 && !hasModel(b.Models, model) {
+				fail(field+".concurrency.models", "model %q is not configured on this backend", model)
+			}
+		}
+		if j := b.Retry.Jitter; j != nil && (*j < 0 || *j > 1) {
+			fail(field+".retry.jitter", "must be between 0 and 1")
+		}
+		checkToolPolicy(field+".tools", b.Tools, fail)
+	}
+
+	for i, route := range c.Routes {
+		field := fmt.Sprintf("routes[%d]", i)
+		if (route.Match == "") == (route.Regex == "") {
+			fail(field, "exactly one of match and regex must be set")
+		} else if _, err := route.Pattern(); err != nil {
+			fail(field+".regex", "%v", err)
+		}
+		if route.Backend == "" && route.Model == "" {
+			fail(field, "backend or model must be set")
+		}
+		if route.Backend != "" {
+			if b, ok := c.Backends[route.Backend]; !ok {
+				fail(field+".backend", "backend %q is not defined", route.Backend)
+			} else if !b.Enabled {
+				fail(field+".backend", "backend %q is disabled", route.Backend)
+			}
+		}
+	}
+
+	switch c.ModelConflicts {
+	case ModelConflictsWarn:
+	case ModelConflictsError:
+		for _, conflict := range c.modelConflicts() {
+			fail("model_conflicts", "%s", conflict)
+		}
+	default:
+		fail("model_conflicts", "must be %q or %q, got %q", ModelConflictsWarn, ModelConflictsError, c.ModelConflicts)
+	}
+
+	virtual := make(map[string]bool)
+	for i, vm := range c.VirtualModels {
+		field := fmt.Sprintf("virtual_models[%d]", i)
+		switch {
+		case vm.ID == "":
+			fail(field+".id", "must be set")
+		case strings.Contains(vm.ID, "/"):
+			fail(field+".id", "%q must not contain '/'", vm.ID)
+		case virtual[vm.ID]:
+			fail(field+".id", "virtual model %q is defined twice", vm.ID)
+		}
+		virtual[vm.ID] = true
+		if vm.Model == "" {
+			fail(field+".model", "must be set")
+		}
+		if t := vm.Defaults.Temperature; t != nil && (*t < 0 || *t > 2) {
+			fail(field+".defaults.temperature", "must be between 0 and 2")
+		}
+		if p := vm.Defaults.TopP; p != nil && (*p < 0 || *p > 1) {
+			fail(field+".defaults.top_p", "must be between 0 and 1")
+		}
+		if m := vm.Defaults.MaxTokens; m != nil && *m <= 0 {
+			fail(field+".defaults.max_tokens", "must be positive")
+		}
+		checkToolPolicy(field+".tools", vm.Tools, fail)
+	}
+	for _, vm := range c.VirtualModels {
+		if virtual[vm.Model] {
+			fail("virtual_models", "%q refers to virtual model %q", vm.ID, vm.Model)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// modelConflicts finds configured model IDs and aliases that more than one
+// enabled backend claims, or that a virtual model shadows. Conflicts with
+// discovered models are only known once the backends are running.
+func (c *Config) modelConflicts() []string {
+	claims := make(map[string][]string)
+	for _, id := range sortedBackends(c.Backends) {
+		if !c.Backends[id].Enabled {
+			continue
+		}
+		for _, m := range c.Backends[id].Models {
+			for _, name := range append([]string{m.ID}, m.Aliases...) {
+				if n := len(claims[name]); n == 0 || claims[name][n-1] != id {
+					claims[name] = append(claims[name], id)
+				}
+			}
+		}
+	}
+	for _, vm := range c.VirtualModels {
+		if backends, ok := claims[vm.ID]; ok {
+			claims[vm.ID] = append([]string{"virtual"}, backends...)
+		}
+	}
+
+	var conflicts []string
+	for name, backends := range claims {
+		if len(backends) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("model %q is offered by %s", name, strings.Join(backends, ", ")))
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+func sortedBackends(backends map[string]BackendConfig) []string {
+	ids := make([]string, 0, len(backends))
+	for id := range backends {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// checkModels rejects model IDs and aliases that are used twice within a
+// backend, since only one of them could ever be reached.
+func checkModels(field string, list []ModelConfig, fail func(string, string, ...interface{})) {
+	owner := make(map[string]string)
+	for _, m := range list {
+		if m.ID == "" {
+			fail(field+".models", "model without an id")
+			continue
+		}
+		if _, ok := owner[m.ID]; ok {
+			fail(field+".models", "model %q is defined twice", m.ID)
+			continue
+		}
+		owner[m.ID] = m.ID
+
+		if m.ContextWindow < 0 || m.MaxOutputTokens < 0 || m.Created < 0 {
+			fail(field+".models", "model %q: created, context_window and max_output_tokens must not be negative", m.ID)
+		}
+		if m.ContextWindow > 0 && m.MaxOutputTokens > m.ContextWindow {
+			fail(field+".models", "model %q: max_output_tokens exceeds context_window", m.ID)
+		}
+		if m.Pricing != nil && (m.Pricing.Input < 0 || m.Pricing.Output < 0) {
+			fail(field+".models", "model %q: pricing must not be negative", m.ID)
+		}
+		if m.DeprecationDate != "" {
+			if _, err := time.Parse("2006-01-02", m.DeprecationDate); err != nil {
+				fail(field+".models", "model %q: deprecation_date must be YYYY-MM-DD", m.ID)
+			}
+		}
+	}
+	for _, m := range list {
+		for _, alias := range m.Aliases {
+			if prev, ok := owner[alias]; ok && prev != m.ID {
+				fail(field+".models", "alias %q of %q is already used by %q", alias, m.ID, prev)
+				continue
+			}
+			owner[alias] = m.ID
+		}
+	}
+}
+
+func hasModel(list []ModelConfig, id string) bool {
+	for _, m := range list {
+		if m.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func checkToolPolicy(field string, policy ToolPolicyConfig, fail func(string, string, ...interface{})) {
+	switch policy.Mode {
+	case "", ToolModeAllowAll, ToolModeDisabled, ToolModeAllowList:
+	default:
+		fail(field+".mode", "must be %q, %q or %q, got %q", ToolModeAllowAll, ToolModeDisabled, ToolModeAllowList, policy.Mode)
+	}
+}
@@ -0,0 +1,95 @@
+package conversation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const (
+	DefaultTTL        = 24 * time.Hour
+	DefaultMaxEntries = 1000
+)
+
+type entry struct {
+	owner    string
+	messages []models.Message
+	expires  time.Time
+}
+
+// Store keeps the message history behind stored responses in memory, so that
+// a follow-up request can continue from a previous response ID. Each history
+// belongs to the API key, known by its hash, that created it.
+type Store struct {
+	mu         sync.Mutex
+	entries    map[string]entry
+	order      []string
+	ttl        time.Duration
+	maxEntries int
+}
+
+func NewStore(ttl time.Duration, maxEntries int) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Store{
+		entries:    make(map[string]entry),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+func (s *Store) Save(owner, id string, messages []models.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict(time.Now())
+
+	if _, exists := s.entries[id]; !exists {
+		s.order = append(s.order, id)
+	}
+	s.entries[id] = entry{
+		owner:    owner,
+		messages: append([]models.Message(nil), messages...),
+		expires:  time.Now().Add(s.ttl),
+	}
+}
+
+// Load returns the history of a response, unless it has expired or belongs
+// to another owner.
+func (s *Store) Load(owner, id string) ([]models.Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok || e.owner != owner || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return append([]models.Message(nil), e.messages...), true
+}
+
+// evict drops expired entries and, if the store is full, the oldest ones.
+func (s *Store) evict(now time.Time) {
+	kept := s.order[:0]
+	for _, id := range s.order {
+		e, ok := s.entries[id]
+		if !ok {
+			continue
+		}
+		if now.After(e.expires) {
+			delete(s.entries, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+
+	for len(s.order) >= s.maxEntries {
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+}
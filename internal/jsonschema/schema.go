@@ -0,0 +1,446 @@
+// Package jsonschema validates JSON documents against the subset of JSON
+// Schema that structured outputs use: types, properties, items, enums,
+// combinators, local $refs and the common string, number and array bounds.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type Schema struct {
+	root     interface{}
+	patterns map[string]*regexp.Regexp
+}
+
+// ValidationError describes the first place where a document does not match
+// its schema.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Compile parses a schema and checks that its keywords are well formed.
+func Compile(raw json.RawMessage) (*Schema, error) {
+	var root interface{}
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+
+	s := &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	if err := s.check(root, ""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) check(node interface{}, path string) error {
+	switch n := node.(type) {
+	case bool:
+		return nil
+	case map[string]interface{}:
+		if ref, ok := n["$ref"].(string); ok {
+			if _, err := s.resolve(ref); err != nil {
+				return fmt.Errorf("%s: %w", pathOrRoot(path), err)
+			}
+		}
+		if pattern, ok := n["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern: %w", pathOrRoot(path), err)
+			}
+			s.patterns[pattern] = re
+		}
+		for _, key := range []string{"properties", "$defs", "definitions"} {
+			if children, ok := n[key].(map[string]interface{}); ok {
+				for name, child := range children {
+					if err := s.check(child, path+"/"+key+"/"+name); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for _, key := range []string{"items", "additionalProperties", "not"} {
+			if child, ok := n[key]; ok {
+				if err := s.check(child, path+"/"+key); err != nil {
+					return err
+				}
+			}
+		}
+		for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+			if list, ok := n[key].([]interface{}); ok {
+				for i, child := range list {
+					if err := s.check(child, fmt.Sprintf("%s/%s/%d", path, key, i)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: schema must be an object or a boolean", pathOrRoot(path))
+}
+
+// resolve looks up a local reference such as "#/$defs/address".
+func (s *Schema) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q: only local references are allowed", ref)
+	}
+
+	node := s.root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		if node, ok = obj[part]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return node, nil
+}
+
+// Validate checks a decoded JSON document, as produced by json.Unmarshal
+// into an interface{}.
+func (s *Schema) Validate(doc interface{}) error {
+	return s.validate(s.root, doc, "", 0)
+}
+
+// ValidateJSON decodes data and validates it.
+func (s *Schema) ValidateJSON(data []byte) error {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return &ValidationError{Message: "output is not valid JSON: " + err.Error()}
+	}
+	return s.Validate(doc)
+}
+
+// maxRefDepth guards against schemas whose $refs loop without consuming any
+// of the document.
+const maxRefDepth = 64
+
+func (s *Schema) validate(node, doc interface{}, path string, refDepth int) error {
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	switch n := node.(type) {
+	case bool:
+		if !n {
+			return fail("no value is allowed here")
+		}
+		return nil
+	case map[string]interface{}:
+		return s.validateObject(n, doc, path, refDepth, fail)
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(n map[string]interface{}, doc interface{}, path string, refDepth int, fail func(string, ...interface{}) error) error {
+	if ref, ok := n["$ref"].(string); ok {
+		if refDepth >= maxRefDepth {
+			return fail("$ref nesting too deep")
+		}
+		target, err := s.resolve(ref)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if err := s.validate(target, doc, path, refDepth+1); err != nil {
+			return err
+		}
+	}
+
+	if t, ok := n["type"]; ok && !matchesType(t, doc) {
+		return fail("expected %s, got %s", describeType(t), typeOf(doc))
+	}
+
+	if enum, ok := n["enum"].([]interface{}); ok {
+		found := false
+		for _, v := range enum {
+			if reflect.DeepEqual(v, doc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("value is not one of the allowed values")
+		}
+	}
+	if c, ok := n["const"]; ok && !reflect.DeepEqual(c, doc) {
+		return fail("value must be %s", compact(c))
+	}
+
+	switch v := doc.(type) {
+	case string:
+		if err := s.validateString(n, v, fail); err != nil {
+			return err
+		}
+	case float64:
+		if err := validateNumber(n, v, fail); err != nil {
+			return err
+		}
+	case []interface{}:
+		if err := s.validateArray(n, v, path, refDepth, fail); err != nil {
+			return err
+		}
+	case map[string]interface{}:
+		if err := s.validateProperties(n, v, path, refDepth, fail); err != nil {
+			return err
+		}
+	}
+
+	if list, ok := n["allOf"].([]interface{}); ok {
+		for _, sub := range list {
+			if err := s.validate(sub, doc, path, refDepth); err != nil {
+				return err
+			}
+		}
+	}
+	if list, ok := n["anyOf"].([]interface{}); ok {
+		var firstErr error
+		matched := false
+		for _, sub := range list {
+			err := s.validate(sub, doc, path, refDepth)
+			if err == nil {
+				matched = true
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if !matched {
+			if firstErr != nil {
+				return firstErr
+			}
+			return fail("value does not match any allowed schema")
+		}
+	}
+	if list, ok := n["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, sub := range list {
+			if s.validate(sub, doc, path, refDepth) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fail("value must match exactly one schema, matched %d", matches)
+		}
+	}
+	if not, ok := n["not"]; ok && s.validate(not, doc, path, refDepth) == nil {
+		return fail("value matches a disallowed schema")
+	}
+
+	return nil
+}
+
+func (s *Schema) validateString(n map[string]interface{}, v string, fail func(string, ...interface{}) error) error {
+	length := utf8.RuneCountInString(v)
+	if min, ok := number(n["minLength"]); ok && float64(length) < min {
+		return fail("string is shorter than %v characters", min)
+	}
+	if max, ok := number(n["maxLength"]); ok && float64(length) > max {
+		return fail("string is longer than %v characters", max)
+	}
+	if pattern, ok := n["pattern"].(string); ok {
+		if re := s.pattern(pattern); re != nil && !re.MatchString(v) {
+			return fail("string does not match pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// pattern returns the compiled pattern. Patterns that Compile did not reach,
+// in parts of the schema only a $ref points to, are compiled on use.
+func (s *Schema) pattern(pattern string) *regexp.Regexp {
+	if re, ok := s.patterns[pattern]; ok {
+		return re
+	}
+	re, _ := regexp.Compile(pattern)
+	return re
+}
+
+func validateNumber(n map[string]interface{}, v float64, fail func(string, ...interface{}) error) error {
+	if min, ok := number(n["minimum"]); ok && v < min {
+		return fail("value must be >= %v", min)
+	}
+	if max, ok := number(n["maximum"]); ok && v > max {
+		return fail("value must be <= %v", max)
+	}
+	if min, ok := number(n["exclusiveMinimum"]); ok && v <= min {
+		return fail("value must be > %v", min)
+	}
+	if max, ok := number(n["exclusiveMaximum"]); ok && v >= max {
+		return fail("value must be < %v", max)
+	}
+	if m, ok := number(n["multipleOf"]); ok && m > 0 {
+		if q := v / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			return fail("value must be a multiple of %v", m)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(n map[string]interface{}, v []interface{}, path string, refDepth int, fail func(string, ...interface{}) error) error {
+	if min, ok := number(n["minItems"]); ok && float64(len(v)) < min {
+		return fail("array has fewer than %v items", min)
+	}
+	if max, ok := number(n["maxItems"]); ok && float64(len(v)) > max {
+		return fail("array has more than %v items", max)
+	}
+	if unique, _ := n["uniqueItems"].(bool); unique {
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if reflect.DeepEqual(v[i], v[j]) {
+					return fail("array items must be unique")
+				}
+			}
+		}
+	}
+	if items, ok := n["items"]; ok {
+		for i, item := range v {
+			if err := s.validate(items, item, path+"/"+strconv.Itoa(i), refDepth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateProperties(n map[string]interface{}, v map[string]interface{}, path string, refDepth int, fail func(string, ...interface{}) error) error {
+	if required, ok := n["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, present := v[name]; !present {
+				return fail("missing required property %q", name)
+			}
+		}
+	}
+	if min, ok := number(n["minProperties"]); ok && float64(len(v)) < min {
+		return fail("object has fewer than %v properties", min)
+	}
+	if max, ok := number(n["maxProperties"]); ok && float64(len(v)) > max {
+		return fail("object has more than %v properties", max)
+	}
+
+	props, _ := n["properties"].(map[string]interface{})
+	additional, hasAdditional := n["additionalProperties"]
+
+	// Sorted so that the reported error is deterministic.
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		childPath := path + "/" + name
+		if sub, ok := props[name]; ok {
+			if err := s.validate(sub, v[name], childPath, refDepth); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			return fail("unexpected property %q", name)
+		}
+		if err := s.validate(additional, v[name], childPath, refDepth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchesType(t interface{}, doc interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		return isType(t, doc)
+	case []interface{}:
+		for _, name := range t {
+			if s, ok := name.(string); ok && isType(s, doc) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, doc interface{}) bool {
+	switch name {
+	case "integer":
+		f, ok := doc.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := doc.(float64)
+		return ok
+	}
+	return typeOf(doc) == name
+}
+
+func typeOf(doc interface{}) string {
+	switch doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func describeType(t interface{}) string {
+	if list, ok := t.([]interface{}); ok {
+		names := make([]string, 0, len(list))
+		for _, name := range list {
+			names = append(names, fmt.Sprint(name))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func number(v interface{}) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func compact(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "schema"
+	}
+	return "schema" + path
+}
@@ -0,0 +1,105 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "object", schema: `{"type":"object","properties":{"a":{"type":"string"}}}`},
+		{name: "boolean", schema: `true`},
+		{name: "local ref", schema: `{"$defs":{"x":{"type":"string"}},"$ref":"#/$defs/x"}`},
+		{name: "not JSON", schema: `{`, wantErr: "not valid JSON"},
+		{name: "not a schema", schema: `"string"`, wantErr: "must be an object or a boolean"},
+		{name: "bad pattern", schema: `{"properties":{"a":{"pattern":"("}}}`, wantErr: "/properties/a: invalid pattern"},
+		{name: "remote ref", schema: `{"$ref":"https://example.com/schema.json"}`, wantErr: "only local references"},
+		{name: "dangling ref", schema: `{"$ref":"#/$defs/missing"}`, wantErr: "unresolvable $ref"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(json.RawMessage(tt.schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Compile: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		doc      string
+		wantPath string
+		wantErr  string
+	}{
+		{name: "type", schema: `{"type":"string"}`, doc: `"x"`},
+		{name: "wrong type", schema: `{"type":"string"}`, doc: `1`, wantErr: "expected string, got number"},
+		{name: "type list", schema: `{"type":["string","null"]}`, doc: `null`},
+		{name: "integer", schema: `{"type":"integer"}`, doc: `2.5`, wantErr: "expected integer"},
+		{name: "enum", schema: `{"enum":["a","b"]}`, doc: `"c"`, wantErr: "not one of the allowed values"},
+		{name: "const", schema: `{"const":3}`, doc: `3`},
+		{name: "required", schema: `{"type":"object","required":["a"]}`, doc: `{}`, wantErr: `missing required property "a"`},
+		{name: "nested path", schema: `{"properties":{"a":{"items":{"type":"number"}}}}`, doc: `{"a":[1,"x"]}`, wantPath: "/a/1"},
+		{name: "additional properties", schema: `{"properties":{"a":{}},"additionalProperties":false}`, doc: `{"a":1,"b":2}`, wantErr: `unexpected property "b"`},
+		{name: "additional properties schema", schema: `{"additionalProperties":{"type":"number"}}`, doc: `{"b":"x"}`, wantPath: "/b"},
+		{name: "pattern", schema: `{"pattern":"^[a-z]+$"}`, doc: `"abc"`},
+		{name: "pattern mismatch", schema: `{"pattern":"^[a-z]+$"}`, doc: `"ABC"`, wantErr: "does not match pattern"},
+		{name: "pattern under ref only", schema: `{"$ref":"#/x","x":{"pattern":"^a"}}`, doc: `"b"`, wantErr: "does not match pattern"},
+		{name: "string length in runes", schema: `{"maxLength":2}`, doc: `"éé"`},
+		{name: "minimum", schema: `{"minimum":1}`, doc: `0`, wantErr: "must be >= 1"},
+		{name: "exclusive maximum", schema: `{"exclusiveMaximum":1}`, doc: `1`, wantErr: "must be < 1"},
+		{name: "multiple of", schema: `{"multipleOf":0.1}`, doc: `0.3`},
+		{name: "unique items", schema: `{"uniqueItems":true}`, doc: `[1,2,1]`, wantErr: "must be unique"},
+		{name: "min items", schema: `{"minItems":2}`, doc: `[1]`, wantErr: "fewer than 2 items"},
+		{name: "any of", schema: `{"anyOf":[{"type":"string"},{"type":"number"}]}`, doc: `1`},
+		{name: "any of mismatch", schema: `{"anyOf":[{"type":"string"},{"type":"number"}]}`, doc: `true`, wantErr: "expected string"},
+		{name: "one of matches two", schema: `{"oneOf":[{"type":"number"},{"minimum":0}]}`, doc: `1`, wantErr: "matched 2"},
+		{name: "not", schema: `{"not":{"type":"null"}}`, doc: `null`, wantErr: "disallowed schema"},
+		{name: "false schema", schema: `{"properties":{"a":false}}`, doc: `{"a":1}`, wantErr: "no value is allowed"},
+		{name: "recursive ref", schema: `{"$defs":{"node":{"type":"object","properties":{"next":{"$ref":"#/$defs/node"}}}},"$ref":"#/$defs/node"}`, doc: `{"next":{"next":{"next":1}}}`, wantPath: "/next/next/next"},
+		{name: "ref loop", schema: `{"$defs":{"a":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`, doc: `1`, wantErr: "too deep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Compile(json.RawMessage(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+
+			err = schema.ValidateJSON([]byte(tt.doc))
+			if tt.wantErr == "" && tt.wantPath == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("err = %v, want a ValidationError", err)
+			}
+			if tt.wantErr != "" && !strings.Contains(verr.Message, tt.wantErr) {
+				t.Errorf("message = %q, want it to contain %q", verr.Message, tt.wantErr)
+			}
+			if tt.wantPath != "" && verr.Path != tt.wantPath {
+				t.Errorf("path = %q, want %q", verr.Path, tt.wantPath)
+			}
+		})
+	}
+}
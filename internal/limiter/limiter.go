@@ -0,0 +1,269 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+const (
+	DefaultMaxQueue     = 100
+	DefaultQueueTimeout = 30 * time.Second
+)
+
+var (
+	ErrQueueFull    = errors.New("request queue is full")
+	ErrQueueTimeout = errors.New("timed out waiting in request queue")
+)
+
+type waiter struct {
+	priority int
+	ready    chan struct{}
+}
+
+// Pool bounds the number of concurrent holders and parks the rest in a
+// priority-ordered FIFO queue.
+type Pool struct {
+	mu           sync.Mutex
+	max          int
+	maxQueue     int
+	queueTimeout time.Duration
+	active       int
+	queue        []*waiter
+}
+
+func NewPool(max, maxQueue int, queueTimeout time.Duration) *Pool {
+	if maxQueue <= 0 {
+		maxQueue = DefaultMaxQueue
+	}
+	if queueTimeout <= 0 {
+		queueTimeout = DefaultQueueTimeout
+	}
+	return &Pool{
+		max:          max,
+		maxQueue:     maxQueue,
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Acquire takes a slot, waiting in the queue for at most the pool's queue
+// timeout.
+func (p *Pool) Acquire(ctx context.Context, priority int) (func(), error) {
+	return p.acquire(ctx, priority, time.Now().Add(p.queueTimeout))
+}
+
+func (p *Pool) acquire(ctx context.Context, priority int, deadline time.Time) (func(), error) {
+	p.mu.Lock()
+	if p.active < p.max && len(p.queue) == 0 {
+		p.active++
+		p.mu.Unlock()
+		return p.releaseFunc(), nil
+	}
+
+	if len(p.queue) >= p.maxQueue {
+		p.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	w := &waiter{priority: priority, ready: make(chan struct{})}
+	idx := sort.Search(len(p.queue), func(i int) bool {
+		return p.queue[i].priority < priority
+	})
+	p.queue = append(p.queue, nil)
+	copy(p.queue[idx+1:], p.queue[idx:])
+	p.queue[idx] = w
+	p.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-w.ready:
+		return p.releaseFunc(), nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	p.mu.Lock()
+	for i, q := range p.queue {
+		if q == w {
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			p.mu.Unlock()
+			return nil, err
+		}
+	}
+	p.mu.Unlock()
+
+	// The slot was handed to us while we were giving up, so pass it on.
+	p.release()
+	return nil, err
+}
+
+func (p *Pool) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(p.release)
+	}
+}
+
+func (p *Pool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.queue) > 0 {
+		next := p.queue[0]
+		p.queue = p.queue[1:]
+		close(next.ready)
+		return
+	}
+	p.active--
+}
+
+func (p *Pool) QueueTimeout() time.Duration {
+	return p.queueTimeout
+}
+
+func (p *Pool) Stats() models.QueueStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return models.QueueStats{
+		Active:    p.active,
+		Queued:    len(p.queue),
+		MaxActive: p.max,
+		MaxQueued: p.maxQueue,
+	}
+}
+
+// Limiter holds the concurrency pools for every backend and for any models
+// that have their own limit.
+type Limiter struct {
+	mu         sync.RWMutex
+	configs    map[string]config.ConcurrencyConfig
+	backends   map[string]*Pool
+	models     map[string]*Pool
+	priorities map[string]int
+}
+
+func New(backends map[string]config.BackendConfig, priorities map[string]int) *Limiter {
+	l := &Limiter{}
+	l.Update(backends, priorities)
+	return l
+}
+
+// Update applies new limits, e.g. after a config reload. Pools of backends
+// whose limits are unchanged are kept; the others are replaced, and requests
+// holding or waiting for a slot in a replaced pool finish against it.
+func (l *Limiter) Update(backends map[string]config.BackendConfig, priorities map[string]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	configs := make(map[string]config.ConcurrencyConfig, len(backends))
+	backendPools := make(map[string]*Pool)
+	modelPools := make(map[string]*Pool)
+
+	for id, b := range backends {
+		c := b.Concurrency
+		configs[id] = c
+
+		if old, ok := l.configs[id]; ok && reflect.DeepEqual(old, c) {
+			if pool, ok := l.backends[id]; ok {
+				backendPools[id] = pool
+			}
+			for model := range c.Models {
+				if pool, ok := l.models[modelKey(id, model)]; ok {
+					modelPools[modelKey(id, model)] = pool
+				}
+			}
+			continue
+		}
+
+		if c.MaxConcurrent > 0 {
+			backendPools[id] = NewPool(c.MaxConcurrent, c.MaxQueue, c.QueueTimeout)
+		}
+		for model, max := range c.Models {
+			if max > 0 {
+				modelPools[modelKey(id, model)] = NewPool(max, c.MaxQueue, c.QueueTimeout)
+			}
+		}
+	}
+
+	l.configs = configs
+	l.backends = backendPools
+	l.models = modelPools
+	l.priorities = priorities
+}
+
+func modelKey(backend, model string) string {
+	return backend + "/" + model
+}
+
+func (l *Limiter) Priority(apiKey string) int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.priorities[apiKey]
+}
+
+// Acquire reserves a slot on the model pool and then the backend pool. Both
+// waits share one queue timeout, the shortest of the pools'. The returned
+// release func must be called once the upstream work is finished. On failure
+// the returned duration is a hint for the Retry-After header.
+func (l *Limiter) Acquire(ctx context.Context, backend, model, apiKey string) (func(), time.Duration, error) {
+	priority := l.Priority(apiKey)
+	var releases []func()
+	releaseAll := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	l.mu.RLock()
+	var pools []*Pool
+	for _, pool := range []*Pool{l.models[modelKey(backend, model)], l.backends[backend]} {
+		if pool != nil {
+			pools = append(pools, pool)
+		}
+	}
+	l.mu.RUnlock()
+
+	var queueTimeout time.Duration
+	for _, pool := range pools {
+		if queueTimeout == 0 || pool.QueueTimeout() < queueTimeout {
+			queueTimeout = pool.QueueTimeout()
+		}
+	}
+	deadline := time.Now().Add(queueTimeout)
+
+	for _, pool := range pools {
+		release, err := pool.acquire(ctx, priority, deadline)
+		if err != nil {
+			releaseAll()
+			return nil, queueTimeout, err
+		}
+		releases = append(releases, release)
+	}
+
+	return releaseAll, 0, nil
+}
+
+func (l *Limiter) Stats() map[string]models.QueueStats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := make(map[string]models.QueueStats, len(l.backends)+len(l.models))
+	for id, pool := range l.backends {
+		stats[id] = pool.Stats()
+	}
+	for id, pool := range l.models {
+		stats[id] = pool.Stats()
+	}
+	return stats
+}
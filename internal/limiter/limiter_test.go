@@ -0,0 +1,183 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+)
+
+// waitQueued waits until pool has n requests queued.
+func waitQueued(t *testing.T, pool *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued = %d, want %d", pool.Stats().Queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolAcquireOrder(t *testing.T) {
+	type waiter struct {
+		name     string
+		priority int
+	}
+	tests := []struct {
+		name    string
+		waiters []waiter
+		want    []string
+	}{
+		{
+			name:    "fifo",
+			waiters: []waiter{{"a", 0}, {"b", 0}, {"c", 0}},
+			want:    []string{"a", "b", "c"},
+		},
+		{
+			name:    "higher priority first",
+			waiters: []waiter{{"low", 0}, {"high", 5}},
+			want:    []string{"high", "low"},
+		},
+		{
+			name:    "fifo within a priority",
+			waiters: []waiter{{"a", 0}, {"b", 5}, {"c", 0}, {"d", 5}, {"e", 1}},
+			want:    []string{"b", "d", "e", "a", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool(1, 10, time.Second)
+			hold, err := pool.Acquire(context.Background(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			var order []string
+			var wg sync.WaitGroup
+			for i, w := range tt.waiters {
+				wg.Add(1)
+				go func(w waiter) {
+					defer wg.Done()
+					release, err := pool.Acquire(context.Background(), w.priority)
+					if err != nil {
+						t.Errorf("%s: %v", w.name, err)
+						return
+					}
+					mu.Lock()
+					order = append(order, w.name)
+					mu.Unlock()
+					release()
+				}(w)
+				waitQueued(t, pool, i+1)
+			}
+
+			hold()
+			wg.Wait()
+
+			if !reflect.DeepEqual(order, tt.want) {
+				t.Errorf("order = %v, want %v", order, tt.want)
+			}
+			if stats := pool.Stats(); stats.Active != 0 || stats.Queued != 0 {
+				t.Errorf("stats after release = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestPoolAcquireFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxQueue int
+		timeout  time.Duration
+		cancel   time.Duration
+		want     error
+	}{
+		{name: "queue timeout", maxQueue: 1, timeout: 20 * time.Millisecond, want: ErrQueueTimeout},
+		{name: "queue full", maxQueue: 1, timeout: time.Second, want: ErrQueueFull},
+		{name: "client gone", maxQueue: 1, timeout: time.Second, cancel: 20 * time.Millisecond, want: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool(1, tt.maxQueue, tt.timeout)
+			hold, err := pool.Acquire(context.Background(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer hold()
+
+			if tt.want == ErrQueueFull {
+				go pool.Acquire(context.Background(), 0)
+				waitQueued(t, pool, 1)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel > 0 {
+				time.AfterFunc(tt.cancel, cancel)
+			}
+
+			release, err := pool.Acquire(ctx, 0)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if release != nil {
+				t.Error("release func returned with an error")
+			}
+			if tt.want != ErrQueueFull {
+				if queued := pool.Stats().Queued; queued != 0 {
+					t.Errorf("queued = %d after giving up, want 0", queued)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterAcquireSharesDeadline(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	l := New(map[string]config.BackendConfig{
+		"b": {Concurrency: config.ConcurrencyConfig{
+			MaxConcurrent: 1,
+			QueueTimeout:  timeout,
+			Models:        map[string]int{"m": 1},
+		}},
+	}, nil)
+
+	// Hold the backend slot for good, and the model slot for most of the
+	// queue timeout.
+	holdBackend, _, err := l.Acquire(context.Background(), "b", "other", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holdBackend()
+	l.mu.RLock()
+	modelPool := l.models[modelKey("b", "m")]
+	l.mu.RUnlock()
+	holdModel, err := modelPool.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(timeout*3/4, holdModel)
+
+	start := time.Now()
+	_, retryAfter, err := l.Acquire(context.Background(), "b", "m", "")
+	waited := time.Since(start)
+
+	if !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("err = %v, want %v", err, ErrQueueTimeout)
+	}
+	if waited > timeout*3/2 {
+		t.Errorf("waited %s for both pools, want about %s", waited, timeout)
+	}
+	if retryAfter != timeout {
+		t.Errorf("retry after = %s, want %s", retryAfter, timeout)
+	}
+}
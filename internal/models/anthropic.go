@@ -0,0 +1,134 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type AnthropicRequest struct {
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	System        json.RawMessage    `json:"system,omitempty"`
+	Messages      []AnthropicMessage `json:"messages"`
+	Stream        bool               `json:"stream"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+}
+
+// AnthropicMessage content is either a string or a list of content blocks.
+type AnthropicMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type AnthropicContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+}
+
+// AnthropicText flattens a string or a list of content blocks into text.
+// Blocks other than text, such as images, are dropped.
+func AnthropicText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, block := range blocks {
+		if block.Type == "text" {
+			b.WriteString(block.Text)
+		}
+	}
+	return b.String()
+}
+
+type AnthropicTextBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type AnthropicResponse struct {
+	ID           string               `json:"id"`
+	Type         string               `json:"type"`
+	Role         string               `json:"role"`
+	Model        string               `json:"model"`
+	Content      []AnthropicTextBlock `json:"content"`
+	StopReason   *string              `json:"stop_reason"`
+	StopSequence *string              `json:"stop_sequence"`
+	Usage        AnthropicUsage       `json:"usage"`
+}
+
+type AnthropicStreamDelta struct {
+	Type         string  `json:"type,omitempty"`
+	Text         string  `json:"text,omitempty"`
+	StopReason   *string `json:"stop_reason,omitempty"`
+	StopSequence *string `json:"stop_sequence,omitempty"`
+}
+
+type AnthropicStreamEvent struct {
+	Type         string                `json:"type"`
+	Message      *AnthropicResponse    `json:"message,omitempty"`
+	Index        *int                  `json:"index,omitempty"`
+	ContentBlock *AnthropicTextBlock   `json:"content_block,omitempty"`
+	Delta        *AnthropicStreamDelta `json:"delta,omitempty"`
+	Usage        *AnthropicUsage       `json:"usage,omitempty"`
+	Error        *AnthropicErrorDetail `json:"error,omitempty"`
+}
+
+type AnthropicErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type AnthropicError struct {
+	Type  string               `json:"type"`
+	Error AnthropicErrorDetail `json:"error"`
+}
+
+// ToAnthropicError converts a gateway error into Anthropic's error shape,
+// keeping the HTTP status.
+func ToAnthropicError(apiErr *APIError) *AnthropicError {
+	errType := "api_error"
+	switch apiErr.GetStatus() {
+	case 400:
+		errType = "invalid_request_error"
+	case 401:
+		errType = "authentication_error"
+	case 403:
+		errType = "permission_error"
+	case 404:
+		errType = "not_found_error"
+	case 413:
+		errType = "request_too_large"
+	case 429:
+		errType = "rate_limit_error"
+	case 503:
+		errType = "overloaded_error"
+	}
+
+	return &AnthropicError{
+		Type: "error",
+		Error: AnthropicErrorDetail{
+			Type:    errType,
+			Message: apiErr.ErrorInfo.Message,
+		},
+	}
+}
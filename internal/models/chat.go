@@ -1,10 +1,13 @@
 package models
 
+import "encoding/json"
+
 type Message struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Role             string     `json:"role"`
+	Content          string     `json:"content"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID       string     `json:"tool_call_id,omitempty"`
 }
 
 type ChatRequest struct {
@@ -16,6 +19,171 @@ type ChatRequest struct {
 	TopP        *float64  `json:"top_p,omitempty"`
 	Backend     string    `json:"backend,omitempty"`
 	SessionID   string    `json:"session_id,omitempty"`
+
+	Stop                StopSequences      `json:"stop,omitempty"`
+	N                   *int               `json:"n,omitempty"`
+	Seed                *int               `json:"seed,omitempty"`
+	PresencePenalty     *float64           `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64           `json:"frequency_penalty,omitempty"`
+	LogitBias           map[string]float64 `json:"logit_bias,omitempty"`
+	Logprobs            *bool              `json:"logprobs,omitempty"`
+	TopLogprobs         *int               `json:"top_logprobs,omitempty"`
+	User                string             `json:"user,omitempty"`
+	MaxCompletionTokens *int               `json:"max_completion_tokens,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	StreamOptions  *StreamOptions  `json:"stream_options,omitempty"`
+
+	// IncludeReasoning asks adapters to return the model's reasoning trace
+	// as reasoning_content, for backends that expose one.
+	IncludeReasoning bool `json:"include_reasoning,omitempty"`
+
+	// IncludeToolEvents asks agent backends to report the tools they ran
+	// on the gateway side as tool_calls marked gateway_executed.
+	IncludeToolEvents bool `json:"include_tool_events,omitempty"`
+
+	// Set by the gateway, never by clients
+	ToolPolicy *ToolPolicy `json:"-"`
+	Caller     string      `json:"-"`
+
+	// MatchStop makes the gateway apply Stop itself even where the upstream
+	// could, so that choices report the sequence that ended them.
+	MatchStop bool `json:"-"`
+}
+
+// Sampling parameter names, as used in requests and adapter capability
+// checks.
+const (
+	ParamTemperature      = "temperature"
+	ParamTopP             = "top_p"
+	ParamMaxTokens        = "max_tokens"
+	ParamStop             = "stop"
+	ParamN                = "n"
+	ParamSeed             = "seed"
+	ParamPresencePenalty  = "presence_penalty"
+	ParamFrequencyPenalty = "frequency_penalty"
+	ParamLogitBias        = "logit_bias"
+	ParamLogprobs         = "logprobs"
+	ParamUser             = "user"
+)
+
+// SamplingParams returns the names of the optional sampling parameters set
+// on r. max_completion_tokens is reported as max_tokens.
+func (r *ChatRequest) SamplingParams() []string {
+	var params []string
+	add := func(name string, set bool) {
+		if set {
+			params = append(params, name)
+		}
+	}
+	add(ParamTemperature, r.Temperature != nil)
+	add(ParamTopP, r.TopP != nil)
+	add(ParamMaxTokens, r.MaxTokens != nil || r.MaxCompletionTokens != nil)
+	add(ParamStop, len(r.Stop) > 0)
+	add(ParamN, r.N != nil && *r.N > 1)
+	add(ParamSeed, r.Seed != nil)
+	add(ParamPresencePenalty, r.PresencePenalty != nil)
+	add(ParamFrequencyPenalty, r.FrequencyPenalty != nil)
+	add(ParamLogitBias, len(r.LogitBias) > 0)
+	add(ParamLogprobs, (r.Logprobs != nil && *r.Logprobs) || r.TopLogprobs != nil)
+	add(ParamUser, r.User != "")
+	return params
+}
+
+// ClearParam unsets a sampling parameter so that adapters never see it.
+func (r *ChatRequest) ClearParam(name string) {
+	switch name {
+	case ParamTemperature:
+		r.Temperature = nil
+	case ParamTopP:
+		r.TopP = nil
+	case ParamMaxTokens:
+		r.MaxTokens = nil
+		r.MaxCompletionTokens = nil
+	case ParamStop:
+		r.Stop = nil
+	case ParamN:
+		r.N = nil
+	case ParamSeed:
+		r.Seed = nil
+	case ParamPresencePenalty:
+		r.PresencePenalty = nil
+	case ParamFrequencyPenalty:
+		r.FrequencyPenalty = nil
+	case ParamLogitBias:
+		r.LogitBias = nil
+	case ParamLogprobs:
+		r.Logprobs = nil
+		r.TopLogprobs = nil
+	case ParamUser:
+		r.User = ""
+	}
+}
+
+// StopSequences accepts either a single string or a list of strings.
+type StopSequences []string
+
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = StopSequences{one}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+type JSONSchemaFormat struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// WantsJSON reports whether the format asks for JSON output.
+func (f *ResponseFormat) WantsJSON() bool {
+	return f != nil && (f.Type == ResponseFormatJSONObject || f.Type == ResponseFormatJSONSchema)
+}
+
+// ToolPolicy restricts which agent tools a backend may run for a request.
+// A nil policy, or a nil Allow list, permits every tool.
+type ToolPolicy struct {
+	Disabled bool
+	Allow    []string
+}
+
+func (p *ToolPolicy) Allows(tool string) bool {
+	if p == nil {
+		return true
+	}
+	if p.Disabled {
+		return false
+	}
+	if p.Allow == nil {
+		return true
+	}
+	for _, t := range p.Allow {
+		if t == tool {
+			return true
+		}
+	}
+	return false
 }
 
 type ChatResponse struct {
@@ -28,9 +196,19 @@ type ChatResponse struct {
 }
 
 type Choice struct {
-	Index        int     `json:"index"`
-	Message      Message `json:"message"`
-	FinishReason string  `json:"finish_reason"`
+	Index        int             `json:"index"`
+	Message      Message         `json:"message"`
+	Logprobs     json.RawMessage `json:"logprobs,omitempty"`
+	FinishReason string          `json:"finish_reason"`
+
+	// StopSequence is the stop sequence the gateway cut the choice at.
+	StopSequence string `json:"-"`
+}
+
+// StreamOptions asks for a final stream chunk with the usage of the whole
+// request and no choices.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type StreamChunk struct {
@@ -39,18 +217,24 @@ type StreamChunk struct {
 	Created int64         `json:"created"`
 	Model   string        `json:"model"`
 	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
 }
 
 type ChunkChoice struct {
-	Index        int    `json:"index"`
-	Delta        Delta  `json:"delta"`
-	FinishReason string `json:"finish_reason,omitempty"`
+	Index        int             `json:"index"`
+	Delta        Delta           `json:"delta"`
+	Logprobs     json.RawMessage `json:"logprobs,omitempty"`
+	FinishReason string          `json:"finish_reason,omitempty"`
+
+	// StopSequence is the stop sequence the gateway cut the choice at.
+	StopSequence string `json:"-"`
 }
 
 type Delta struct {
-	Role      string     `json:"role,omitempty"`
-	Content   string     `json:"content,omitempty"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Role             string     `json:"role,omitempty"`
+	Content          string     `json:"content,omitempty"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
 }
 
 type Usage struct {
@@ -71,14 +255,25 @@ type Function struct {
 }
 
 type ToolCall struct {
-	ID       string       `json:"id"`
-	Type     string       `json:"type"`
+	// Index identifies the call across stream deltas, which clients merge
+	// by index. It is only set in streams.
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
 	Function FunctionCall `json:"function"`
+
+	// Set for tools the backend agent already ran; clients must not execute
+	// these again. In streams, later deltas of a call carry only its index
+	// and the new status, output or error.
+	GatewayExecuted bool   `json:"gateway_executed,omitempty"`
+	Status          string `json:"status,omitempty"`
+	Output          string `json:"output,omitempty"`
+	Error           string `json:"error,omitempty"`
 }
 
 type FunctionCall struct {
-	Name      string `json:"name"`
-	Arguments string `json:"arguments"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 }
 
 type Model struct {
@@ -88,6 +283,36 @@ type Model struct {
 	OwnedBy string `json:"owned_by"`
 	Backend string `json:"backend,omitempty"`
 	Free    bool   `json:"free,omitempty"`
+
+	Aliases         []string           `json:"aliases,omitempty"`
+	ContextWindow   int                `json:"context_window,omitempty"`
+	MaxOutputTokens int                `json:"max_output_tokens,omitempty"`
+	Capabilities    *ModelCapabilities `json:"capabilities,omitempty"`
+	Pricing         *ModelPricing      `json:"pricing,omitempty"`
+	Deprecated      bool               `json:"deprecated,omitempty"`
+	DeprecationDate string             `json:"deprecation_date,omitempty"`
+	ReplacedBy      string             `json:"replaced_by,omitempty"`
+
+	// Set on virtual models, which stand for VirtualOf with a fixed prompt
+	// and settings.
+	Description string `json:"description,omitempty"`
+	VirtualOf   string `json:"virtual_of,omitempty"`
+
+	// Set on the alias entries that /v1/models?include_aliases=true adds.
+	AliasFor string `json:"alias_for,omitempty"`
+}
+
+type ModelCapabilities struct {
+	Tools     bool `json:"tools"`
+	Vision    bool `json:"vision"`
+	Streaming bool `json:"streaming"`
+	Reasoning bool `json:"reasoning"`
+}
+
+// ModelPricing is in US dollars per million tokens.
+type ModelPricing struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
 }
 
 type ModelsResponse struct {
@@ -108,9 +333,44 @@ type BackendsResponse struct {
 	Data   []Backend `json:"data"`
 }
 
+// Route is one entry of the routing table as shown by /v1/routes. Keys are
+// masked.
+type Route struct {
+	Index   int               `json:"index"`
+	Name    string            `json:"name,omitempty"`
+	Match   string            `json:"match,omitempty"`
+	Regex   string            `json:"regex,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Keys    []string          `json:"keys,omitempty"`
+	Backend string            `json:"backend,omitempty"`
+	Model   string            `json:"model,omitempty"`
+}
+
+type RoutesResponse struct {
+	Object string  `json:"object"`
+	Data   []Route `json:"data"`
+}
+
+// RouteResolution is the dry-run answer of /v1/routes/resolve: the route
+// that matched, if any, and where the request would be sent.
+type RouteResolution struct {
+	Model         string `json:"model"`
+	Route         *Route `json:"route"`
+	Backend       string `json:"backend"`
+	ResolvedModel string `json:"resolved_model"`
+}
+
 type HealthResponse struct {
-	Status   string            `json:"status"`
-	Version  string            `json:"version"`
-	Backends map[string]string `json:"backends"`
-	Uptime   int64             `json:"uptime"`
+	Status   string                `json:"status"`
+	Version  string                `json:"version"`
+	Backends map[string]string     `json:"backends"`
+	Queues   map[string]QueueStats `json:"queues,omitempty"`
+	Uptime   int64                 `json:"uptime"`
+}
+
+type QueueStats struct {
+	Active    int `json:"active"`
+	Queued    int `json:"queued"`
+	MaxActive int `json:"max_active"`
+	MaxQueued int `json:"max_queued"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import "encoding/json"
+
+// EmbeddingRequest is an OpenAI embeddings request. Input is a string or a
+// list of strings; the gateway decodes it into Inputs before calling the
+// adapter.
+type EmbeddingRequest struct {
+	Model          string          `json:"model"`
+	Input          json.RawMessage `json:"input"`
+	EncodingFormat string          `json:"encoding_format,omitempty"`
+	Dimensions     *int            `json:"dimensions,omitempty"`
+	User           string          `json:"user,omitempty"`
+	Backend        string          `json:"backend,omitempty"`
+
+	// Set by the gateway, never by clients
+	Inputs []string `json:"-"`
+}
+
+// EmbeddingData holds one vector. Adapters return []float64; the gateway
+// replaces it with a base64 string when the client asks for that encoding.
+type EmbeddingData struct {
+	Object    string      `json:"object"`
+	Index     int         `json:"index"`
+	Embedding interface{} `json:"embedding"`
+}
+
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  EmbeddingUsage  `json:"usage"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type APIError struct {
 	ErrorInfo ErrorDetail `json:"error"`
@@ -19,6 +22,7 @@ const (
 	ErrorTypeRateLimit      = "rate_limit_error"
 	ErrorTypeBackend        = "backend_error"
 	ErrorTypeService        = "service_error"
+	ErrorTypePermission     = "permission_error"
 )
 
 const (
@@ -30,6 +34,25 @@ const (
 	ErrorCodeBackendUnavailable = "backend_unavailable"
 	ErrorCodeBackendTimeout     = "backend_timeout"
 	ErrorCodeServiceUnavailable = "service_unavailable"
+	ErrorCodeQueueFull          = "queue_full"
+	ErrorCodeQueueTimeout       = "queue_timeout"
+	ErrorCodeContextLength      = "context_length_exceeded"
+	ErrorCodeContentFilter      = "content_filter"
+	ErrorCodeUpstreamRateLimit  = "upstream_rate_limit_exceeded"
+	ErrorCodeUpstreamAuth       = "upstream_authentication_failed"
+	ErrorCodeModelNotFound      = "model_not_found"
+	ErrorCodeBackendError       = "backend_error"
+	ErrorCodeToolNotAllowed     = "tool_not_allowed"
+
+	ErrorCodePreviousResponseNotFound = "previous_response_not_found"
+	ErrorCodeInvalidInput             = "invalid_input"
+	ErrorCodeEmbeddingsNotSupported   = "embeddings_not_supported"
+	ErrorCodeFileNotFound             = "file_not_found"
+	ErrorCodeBatchNotFound            = "batch_not_found"
+	ErrorCodeInvalidResponseFormat    = "invalid_response_format"
+	ErrorCodeStructuredOutput         = "invalid_structured_output"
+	ErrorCodeUnsupportedParameter     = "unsupported_parameter"
+	ErrorCodeBudgetExceeded           = "budget_exceeded"
 )
 
 func NewAPIError(message, errorType, code string, status int) *APIError {
@@ -113,3 +136,158 @@ func ErrBackendTimeout(backend string) *APIError {
 		504,
 	)
 }
+
+func ErrQueueFull(backend string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("Backend '%s' is at capacity and its queue is full", backend),
+		ErrorTypeService,
+		ErrorCodeQueueFull,
+		503,
+	)
+}
+
+func ErrQueueTimeout(backend string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("Timed out waiting for capacity on backend '%s'", backend),
+		ErrorTypeService,
+		ErrorCodeQueueTimeout,
+		503,
+	)
+}
+
+func ErrContextLengthExceeded(message string) *APIError {
+	return NewAPIError(
+		message,
+		ErrorTypeInvalidRequest,
+		ErrorCodeContextLength,
+		400,
+	)
+}
+
+func ErrContentFiltered(message string) *APIError {
+	return NewAPIError(
+		message,
+		ErrorTypeInvalidRequest,
+		ErrorCodeContentFilter,
+		400,
+	)
+}
+
+func ErrUpstreamRateLimit(backend string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("Backend '%s' is rate limited upstream. Please retry later", backend),
+		ErrorTypeRateLimit,
+		ErrorCodeUpstreamRateLimit,
+		429,
+	)
+}
+
+func ErrUpstreamAuth(backend string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("Backend '%s' failed to authenticate with its upstream provider", backend),
+		ErrorTypeBackend,
+		ErrorCodeUpstreamAuth,
+		502,
+	)
+}
+
+func ErrModelNotFound(model string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("Model '%s' does not exist upstream", model),
+		ErrorTypeInvalidRequest,
+		ErrorCodeModelNotFound,
+		404,
+	)
+}
+
+func ErrBackend(message string) *APIError {
+	return NewAPIError(
+		message,
+		ErrorTypeBackend,
+		ErrorCodeBackendError,
+		502,
+	)
+}
+
+func ErrToolNotAllowed(message string) *APIError {
+	return NewAPIError(
+		message,
+		ErrorTypePermission,
+		ErrorCodeToolNotAllowed,
+		403,
+	)
+}
+
+func ErrInvalidInput(message string) *APIError {
+	return NewAPIError(
+		message,
+		ErrorTypeInvalidRequest,
+		ErrorCodeInvalidInput,
+		400,
+	)
+}
+
+func ErrEmbeddingsNotSupported(model string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("Model '%s' does not support embeddings", model),
+		ErrorTypeInvalidRequest,
+		ErrorCodeEmbeddingsNotSupported,
+		400,
+	)
+}
+
+func ErrFileNotFound(id string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("File '%s' not found", id),
+		ErrorTypeInvalidRequest,
+		ErrorCodeFileNotFound,
+		404,
+	)
+}
+
+func ErrBatchNotFound(id string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("Batch '%s' not found", id),
+		ErrorTypeInvalidRequest,
+		ErrorCodeBatchNotFound,
+		404,
+	)
+}
+
+func ErrUnsupportedParameters(params []string, backend string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("Parameters not supported by backend '%s': %s", backend, strings.Join(params, ", ")),
+		ErrorTypeInvalidRequest,
+		ErrorCodeUnsupportedParameter,
+		400,
+	)
+}
+
+func ErrInvalidResponseFormat(message string) *APIError {
+	return NewAPIError(
+		message,
+		ErrorTypeInvalidRequest,
+		ErrorCodeInvalidResponseFormat,
+		400,
+	)
+}
+
+func ErrStructuredOutput(message string) *APIError {
+	return NewAPIError(
+		message,
+		ErrorTypeBackend,
+		ErrorCodeStructuredOutput,
+		502,
+	)
+}
+
+// ErrBudgetExceeded is returned for paid models once the monthly budget
+// named by scope ("global" or "key") is spent. Free models keep working.
+func ErrBudgetExceeded(scope string) *APIError {
+	return NewAPIError(
+		fmt.Sprintf("The %s monthly budget is exhausted. Only free models are available until next month", scope),
+		ErrorTypeRateLimit,
+		ErrorCodeBudgetExceeded,
+		429,
+	)
+}
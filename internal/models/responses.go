@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type ResponsesRequest struct {
+	Model              string          `json:"model"`
+	Input              json.RawMessage `json:"input"`
+	Instructions       string          `json:"instructions,omitempty"`
+	Stream             bool            `json:"stream"`
+	PreviousResponseID string          `json:"previous_response_id,omitempty"`
+	Store              *bool           `json:"store,omitempty"`
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               *float64        `json:"top_p,omitempty"`
+	MaxOutputTokens    *int            `json:"max_output_tokens,omitempty"`
+	Backend            string          `json:"backend,omitempty"`
+}
+
+// ResponseInputItem is one entry of a Responses API input list. Content is
+// either a string or a list of typed content parts.
+type ResponseInputItem struct {
+	Type    string          `json:"type,omitempty"`
+	Role    string          `json:"role,omitempty"`
+	Content json.RawMessage `json:"content,omitempty"`
+	CallID  string          `json:"call_id,omitempty"`
+	Output  string          `json:"output,omitempty"`
+}
+
+type ResponseContent struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+// Text flattens the item's content into plain text.
+func (i *ResponseInputItem) Text() string {
+	var s string
+	if err := json.Unmarshal(i.Content, &s); err == nil {
+		return s
+	}
+
+	var parts []ResponseContent
+	if err := json.Unmarshal(i.Content, &parts); err != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, p := range parts {
+		switch p.Type {
+		case "input_text", "output_text", "text":
+			b.WriteString(p.Text)
+		}
+	}
+	return b.String()
+}
+
+type ResponseOutputItem struct {
+	Type    string            `json:"type"`
+	ID      string            `json:"id"`
+	Status  string            `json:"status"`
+	Role    string            `json:"role"`
+	Content []ResponseContent `json:"content"`
+}
+
+type ResponseUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type Response struct {
+	ID                 string               `json:"id"`
+	Object             string               `json:"object"`
+	CreatedAt          int64                `json:"created_at"`
+	Status             string               `json:"status"`
+	Model              string               `json:"model"`
+	Instructions       string               `json:"instructions,omitempty"`
+	PreviousResponseID string               `json:"previous_response_id,omitempty"`
+	Output             []ResponseOutputItem `json:"output"`
+	OutputText         string               `json:"output_text,omitempty"`
+	Error              *ErrorDetail         `json:"error"`
+	Usage              *ResponseUsage       `json:"usage,omitempty"`
+}
+
+// ResponseStreamEvent is the payload of every Responses API SSE event; only
+// the fields relevant to its type are set.
+type ResponseStreamEvent struct {
+	Type           string              `json:"type"`
+	SequenceNumber int                 `json:"sequence_number"`
+	Response       *Response           `json:"response,omitempty"`
+	OutputIndex    *int                `json:"output_index,omitempty"`
+	ContentIndex   *int                `json:"content_index,omitempty"`
+	ItemID         string              `json:"item_id,omitempty"`
+	Item           *ResponseOutputItem `json:"item,omitempty"`
+	Part           *ResponseContent    `json:"part,omitempty"`
+	Delta          string              `json:"delta,omitempty"`
+	Text           string              `json:"text,omitempty"`
+	Error          *ErrorDetail        `json:"error,omitempty"`
+}
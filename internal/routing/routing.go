@@ -0,0 +1,149 @@
+package routing
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// Request is what routes are matched against. KeyHash is auth.HashKey of
+// the caller's API key, so that batch jobs, which keep only the hash, are
+// routed like live requests. Batch jobs have no headers, so routes with
+// header conditions never match them.
+type Request struct {
+	Model   string
+	Header  http.Header
+	KeyHash string
+}
+
+// Table is the ordered routing table from the config.
+type Table struct {
+	mu    sync.RWMutex
+	rules []rule
+}
+
+type rule struct {
+	view    models.Route
+	pattern *regexp.Regexp
+	headers map[string]*regexp.Regexp
+	keys    map[string]bool
+}
+
+func New(routes []config.RouteConfig) *Table {
+	t := &Table{}
+	t.Update(routes)
+	return t
+}
+
+// Update replaces the routes, e.g. after a config reload. Routes that do not
+// compile are skipped; Config.Validate reports them.
+func (t *Table) Update(routes []config.RouteConfig) {
+	rules := make([]rule, 0, len(routes))
+	for i, route := range routes {
+		pattern, err := route.Pattern()
+		if err != nil {
+			continue
+		}
+
+		r := rule{
+			view: models.Route{
+				Index:   i,
+				Name:    route.Name,
+				Match:   route.Match,
+				Regex:   route.Regex,
+				Headers: route.Headers,
+				Backend: route.Backend,
+				Model:   route.Model,
+			},
+			pattern: pattern,
+		}
+		if len(route.Headers) > 0 {
+			r.headers = make(map[string]*regexp.Regexp, len(route.Headers))
+			for name, value := range route.Headers {
+				r.headers[name] = config.Glob(value)
+			}
+		}
+		if len(route.Keys) > 0 {
+			r.keys = make(map[string]bool, len(route.Keys))
+			for _, key := range route.Keys {
+				r.keys[auth.HashKey(key)] = true
+				r.view.Keys = append(r.view.Keys, auth.MaskKey(key))
+			}
+		}
+		rules = append(rules, r)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = rules
+}
+
+// Routes lists the table in order as the caller with keyHash may see it:
+// key and header conditions, which can identify other tenants, are only
+// shown on routes that name the caller's key.
+func (t *Table) Routes(keyHash string) []models.Route {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	routes := make([]models.Route, 0, len(t.rules))
+	for _, r := range t.rules {
+		view := r.view
+		if !r.keys[keyHash] {
+			view.Keys = nil
+			view.Headers = nil
+		}
+		routes = append(routes, view)
+	}
+	return routes
+}
+
+// Route finds the first route that matches req and returns it with the
+// backend and model the request should be sent to. The backend is empty when
+// the route only rewrites the model.
+func (t *Table) Route(req Request) (*models.Route, string, string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for i := range t.rules {
+		r := &t.rules[i]
+		match := r.pattern.FindStringSubmatchIndex(req.Model)
+		if match == nil || !r.matchesCaller(req) {
+			continue
+		}
+
+		model := req.Model
+		if r.view.Model != "" {
+			model = string(r.pattern.ExpandString(nil, r.view.Model, req.Model, match))
+		}
+		view := r.view
+		return &view, r.view.Backend, model, true
+	}
+	return nil, "", "", false
+}
+
+func (r *rule) matchesCaller(req Request) bool {
+	if r.keys != nil && !r.keys[req.KeyHash] {
+		return false
+	}
+
+	for name, pattern := range r.headers {
+		values, ok := req.Header[http.CanonicalHeaderKey(name)]
+		if !ok || !matchesAny(pattern, values) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(pattern *regexp.Regexp, values []string) bool {
+	for _, value := range values {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package routing
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/auth"
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+var testRoutes = []config.RouteConfig{
+	{Name: "broken", Regex: "(", Backend: "never"},
+	{Name: "tenant", Match: "gpt-*", Keys: []string{"alice-key-123"}, Backend: "azure"},
+	{Name: "canary", Match: "gpt-4o", Headers: map[string]string{"X-Canary": "on*"}, Backend: "canary"},
+	{Name: "gpt", Match: "gpt-*", Backend: "openai"},
+	{Name: "local", Regex: `llama-(\d+)b`, Backend: "ollama", Model: "llama3:${1}b"},
+	{Name: "rename", Match: "fast", Model: "grok-code-fast-1"},
+}
+
+func TestTableRoute(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         Request
+		wantRoute   string
+		wantBackend string
+		wantModel   string
+	}{
+		{name: "glob", req: Request{Model: "gpt-4o-mini"}, wantRoute: "gpt", wantBackend: "openai", wantModel: "gpt-4o-mini"},
+		{name: "key condition", req: Request{Model: "gpt-4o", KeyHash: auth.HashKey("alice-key-123")}, wantRoute: "tenant", wantBackend: "azure", wantModel: "gpt-4o"},
+		{name: "header condition", req: Request{Model: "gpt-4o", Header: http.Header{"X-Canary": {"only-me"}}}, wantRoute: "canary", wantBackend: "canary", wantModel: "gpt-4o"},
+		{name: "header not matching", req: Request{Model: "gpt-4o", Header: http.Header{"X-Canary": {"off"}}}, wantRoute: "gpt", wantBackend: "openai", wantModel: "gpt-4o"},
+		{name: "regex rewrite", req: Request{Model: "llama-70b"}, wantRoute: "local", wantBackend: "ollama", wantModel: "llama3:70b"},
+		{name: "regex matches the whole model", req: Request{Model: "llama-70b-instruct"}},
+		{name: "model only", req: Request{Model: "fast"}, wantRoute: "rename", wantModel: "grok-code-fast-1"},
+		{name: "no match", req: Request{Model: "claude-x"}},
+	}
+
+	table := New(testRoutes)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, backend, model, ok := table.Route(tt.req)
+			if ok != (tt.wantRoute != "") {
+				t.Fatalf("matched = %v, want %v", ok, tt.wantRoute != "")
+			}
+			if !ok {
+				return
+			}
+			if route.Name != tt.wantRoute || backend != tt.wantBackend || model != tt.wantModel {
+				t.Errorf("Route = %s, %s, %s; want %s, %s, %s", route.Name, backend, model, tt.wantRoute, tt.wantBackend, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestTableRoutes(t *testing.T) {
+	routes := []config.RouteConfig{
+		{Name: "tenant", Match: "*", Headers: map[string]string{"X-Team": "a"}, Keys: []string{"alice-key-123"}, Backend: "azure"},
+	}
+	table := New(routes)
+
+	tests := []struct {
+		name    string
+		keyHash string
+		want    models.Route
+	}{
+		{
+			name:    "named key",
+			keyHash: auth.HashKey("alice-key-123"),
+			want:    models.Route{Name: "tenant", Match: "*", Headers: map[string]string{"X-Team": "a"}, Keys: []string{"alic...-123"}, Backend: "azure"},
+		},
+		{
+			name:    "other key",
+			keyHash: auth.HashKey("bob-key-456"),
+			want:    models.Route{Name: "tenant", Match: "*", Backend: "azure"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := table.Routes(tt.keyHash)
+			if len(got) != 1 || !reflect.DeepEqual(got[0], tt.want) {
+				t.Errorf("Routes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableUpdate(t *testing.T) {
+	table := New(testRoutes)
+	table.Update([]config.RouteConfig{{Name: "all", Match: "*", Backend: "openai"}})
+
+	if got := table.Routes(""); len(got) != 1 || got[0].Name != "all" {
+		t.Errorf("Routes = %+v, want only all", got)
+	}
+	if route, backend, _, ok := table.Route(Request{Model: "llama-70b"}); !ok || route.Name != "all" || backend != "openai" {
+		t.Errorf("Route = %+v, %s, want all to openai", route, backend)
+	}
+}
@@ -0,0 +1,90 @@
+package sandbox
+
+import (
+	"sync"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+// Policies resolves the effective agent tool policy for a backend and API
+// key. When both define one, the stricter combination wins.
+type Policies struct {
+	mu       sync.RWMutex
+	backends map[string]config.ToolPolicyConfig
+	keys     map[string]config.ToolPolicyConfig
+}
+
+func New(backends map[string]config.BackendConfig, keys map[string]config.ToolPolicyConfig) *Policies {
+	p := &Policies{}
+	p.Update(backends, keys)
+	return p
+}
+
+// Update replaces the backend and key policies, e.g. after a config reload.
+func (p *Policies) Update(backends map[string]config.BackendConfig, keys map[string]config.ToolPolicyConfig) {
+	backendPolicies := make(map[string]config.ToolPolicyConfig, len(backends))
+	for id, b := range backends {
+		backendPolicies[id] = b.Tools
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.backends = backendPolicies
+	p.keys = keys
+}
+
+// KeyPolicy returns the API key's own policy, without any backend policy
+// applied. Background jobs snapshot it so they do not need to keep the key.
+func (p *Policies) KeyPolicy(apiKey string) *models.ToolPolicy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return fromConfig(p.keys[apiKey])
+}
+
+// Restrict combines a backend's policy with keyPolicy.
+func (p *Policies) Restrict(backend string, keyPolicy *models.ToolPolicy) *models.ToolPolicy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return merge(fromConfig(p.backends[backend]), keyPolicy)
+}
+
+// Narrow combines policy with one more policy from the config, e.g. a
+// virtual model's.
+func Narrow(policy *models.ToolPolicy, cfg config.ToolPolicyConfig) *models.ToolPolicy {
+	return merge(policy, fromConfig(cfg))
+}
+
+// fromConfig turns a configured policy into a request policy. An unknown
+// mode disables all tools rather than allowing them.
+func fromConfig(cfg config.ToolPolicyConfig) *models.ToolPolicy {
+	switch cfg.Mode {
+	case "", config.ToolModeAllowAll:
+		return nil
+	case config.ToolModeAllowList:
+		allow := make([]string, len(cfg.Allow))
+		copy(allow, cfg.Allow)
+		return &models.ToolPolicy{Allow: allow}
+	}
+	return &models.ToolPolicy{Disabled: true}
+}
+
+func merge(a, b *models.ToolPolicy) *models.ToolPolicy {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.Disabled || b.Disabled {
+		return &models.ToolPolicy{Disabled: true}
+	}
+
+	allow := []string{}
+	for _, tool := range a.Allow {
+		if b.Allows(tool) {
+			allow = append(allow, tool)
+		}
+	}
+	return &models.ToolPolicy{Allow: allow}
+}
@@ -0,0 +1,59 @@
+package sandbox
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kashifkhan/ai-gateway/internal/config"
+	"github.com/kashifkhan/ai-gateway/internal/models"
+)
+
+func TestPoliciesRestrict(t *testing.T) {
+	allowList := func(tools ...string) config.ToolPolicyConfig {
+		return config.ToolPolicyConfig{Mode: config.ToolModeAllowList, Allow: tools}
+	}
+
+	tests := []struct {
+		name    string
+		backend config.ToolPolicyConfig
+		key     config.ToolPolicyConfig
+		virtual config.ToolPolicyConfig
+		want    *models.ToolPolicy
+	}{
+		{name: "no policies", want: nil},
+		{name: "allow all", backend: config.ToolPolicyConfig{Mode: config.ToolModeAllowAll}, want: nil},
+		{name: "backend only", backend: allowList("read", "grep"), want: &models.ToolPolicy{Allow: []string{"read", "grep"}}},
+		{name: "key only", key: allowList("read"), want: &models.ToolPolicy{Allow: []string{"read"}}},
+		{name: "intersection", backend: allowList("read", "grep", "bash"), key: allowList("bash", "read", "edit"), want: &models.ToolPolicy{Allow: []string{"read", "bash"}}},
+		{name: "nothing in common", backend: allowList("read"), key: allowList("bash"), want: &models.ToolPolicy{Allow: []string{}}},
+		{name: "key disables", backend: allowList("read"), key: config.ToolPolicyConfig{Mode: config.ToolModeDisabled}, want: &models.ToolPolicy{Disabled: true}},
+		{name: "unknown mode disables", backend: config.ToolPolicyConfig{Mode: "allow-list"}, want: &models.ToolPolicy{Disabled: true}},
+		{name: "virtual model narrows", backend: allowList("read", "grep"), virtual: allowList("grep"), want: &models.ToolPolicy{Allow: []string{"grep"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(
+				map[string]config.BackendConfig{"opencode": {Tools: tt.backend}},
+				map[string]config.ToolPolicyConfig{"key": tt.key},
+			)
+
+			got := Narrow(p.Restrict("opencode", p.KeyPolicy("key")), tt.virtual)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("policy = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoliciesUpdate(t *testing.T) {
+	p := New(map[string]config.BackendConfig{"opencode": {Tools: config.ToolPolicyConfig{Mode: config.ToolModeDisabled}}}, nil)
+	if got := p.Restrict("opencode", nil); got == nil || !got.Disabled {
+		t.Fatalf("policy = %+v, want disabled", got)
+	}
+
+	p.Update(map[string]config.BackendConfig{"opencode": {}}, nil)
+	if got := p.Restrict("opencode", nil); got != nil {
+		t.Errorf("policy after update = %+v, want none", got)
+	}
+}